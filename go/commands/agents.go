@@ -0,0 +1,205 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/dirt-rain/code-editor-agent/config"
+	"github.com/dirt-rain/code-editor-agent/models"
+	"gopkg.in/yaml.v3"
+)
+
+// ClaudeAgentsDir is where Claude Code looks for project agent definitions
+const ClaudeAgentsDir = ".claude/agents"
+
+// Markers around the part of a generated agent definition that is kept on sync
+const (
+	claudeAgentUserStart = "<!-- BEGIN USER SECTION: kept by code-editor-agent cmd sync-agents -->"
+	claudeAgentUserEnd   = "<!-- END USER SECTION -->"
+)
+
+// defaultClaudeAgentTools are the tools of a generated agent unless configured
+var defaultClaudeAgentTools = []string{"Bash", "Read", "Edit", "Write", "Grep", "Glob"}
+
+// SyncAgents writes a .claude/agents/<name>.md definition for every agent.
+// Files not generated by sync-agents are skipped unless force is set, in
+// which case their body is kept in the user section.
+func SyncAgents(force bool) error {
+	cfg, err := config.Load(projectFS)
+	if err != nil {
+		return err
+	}
+	return syncClaudeAgents(cfg, false, force, projectRuntime())
+}
+
+// syncClaudeAgents writes the agent definitions of every agent, or only of
+// the agents with a claudeAgent block if onlyConfigured is set, running the
+// CLI installed with runtime
+func syncClaudeAgents(cfg *models.Config, onlyConfigured, force bool, runtime string) error {
+	agentNames := make([]string, 0, len(cfg.Agents))
+	for agentName, agentConfig := range cfg.Agents {
+		if agentConfig.ClaudeAgent != nil || !onlyConfigured {
+			agentNames = append(agentNames, agentName)
+		}
+	}
+	sort.Strings(agentNames)
+
+	// Check every name before writing anything
+	owners := make(map[string]string)
+	for _, agentName := range agentNames {
+		name := claudeAgentName(agentName, cfg.Agents[agentName])
+		if owner, ok := owners[name]; ok {
+			return fmt.Errorf("Agents '%s' and '%s' both generate the Claude agent '%s'. Set a different 'claudeAgent.name'.", owner, agentName, name)
+		}
+		owners[name] = agentName
+	}
+
+	if len(agentNames) > 0 {
+		if err := projectFS.MkdirAll(ClaudeAgentsDir, 0755); err != nil {
+			return fmt.Errorf("failed to create claude agents directory: %w", err)
+		}
+	}
+
+	for _, agentName := range agentNames {
+		agentConfig := cfg.Agents[agentName]
+		agentPath := path.Join(ClaudeAgentsDir, claudeAgentName(agentName, agentConfig)+".md")
+
+		existing, err := readFile(agentPath)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", agentPath, err)
+		}
+
+		userSection := ""
+		if existing != nil {
+			section, managed := claudeAgentUserSection(string(existing))
+			if !managed && !force {
+				fmt.Fprintf(os.Stderr, "Warning: Skipping %s: not generated by sync-agents. Use `cmd sync-agents --force` to take it over, keeping its body.\n", agentPath)
+				continue
+			}
+			if managed {
+				userSection = section
+			} else {
+				body, err := extractBody(agentPath)
+				if err != nil {
+					return fmt.Errorf("failed to read %s: %w", agentPath, err)
+				}
+				// The instruction written by init, for any runtime, is generated anyway
+				for _, invocationRuntime := range []string{RuntimeGo, RuntimeNode} {
+					body = strings.Replace(body, claudeAgentInstruction(agentConfig, invocationRuntime), "", 1)
+				}
+				userSection = strings.TrimSpace(body)
+			}
+		}
+
+		content := formatClaudeAgent(agentName, agentConfig, userSection, runtime)
+		if existing != nil && string(existing) == content {
+			continue
+		}
+		if err := writeFile(agentPath, []byte(content)); err != nil {
+			return fmt.Errorf("failed to write %s: %w", agentPath, err)
+		}
+		fmt.Printf("Wrote %s\n", agentPath)
+	}
+	return nil
+}
+
+// claudeAgentName returns the name of the generated Claude agent
+func claudeAgentName(agentName string, agentConfig *models.AgentConfig) string {
+	if agentConfig.ClaudeAgent != nil && agentConfig.ClaudeAgent.Name != "" {
+		return agentConfig.ClaudeAgent.Name
+	}
+	return agentName
+}
+
+// claudeAgentUserSection returns the text between the user section markers,
+// and whether the content has them
+func claudeAgentUserSection(content string) (string, bool) {
+	start := strings.Index(content, claudeAgentUserStart)
+	if start == -1 {
+		return "", false
+	}
+	rest := content[start+len(claudeAgentUserStart):]
+	end := strings.Index(rest, claudeAgentUserEnd)
+	if end == -1 {
+		return "", false
+	}
+	return strings.TrimSpace(rest[:end]), true
+}
+
+// formatClaudeAgent formats the agent definition of an agent running the CLI
+// installed with runtime
+func formatClaudeAgent(agentName string, agentConfig *models.AgentConfig, userSection, runtime string) string {
+	claudeAgent := agentConfig.ClaudeAgent
+	if claudeAgent == nil {
+		claudeAgent = &models.ClaudeAgentConfig{}
+	}
+
+	description := claudeAgent.Description
+	if description == "" {
+		if agentConfig.CommandGroup == nil {
+			description = "For every code editing"
+		} else {
+			description = fmt.Sprintf("For every task covered by the %s rules", agentName)
+		}
+	}
+	tools := claudeAgent.Tools
+	if len(tools) == 0 {
+		tools = defaultClaudeAgentTools
+	}
+
+	model, color := claudeAgent.Model, claudeAgent.Color
+	if model == "" {
+		model = "sonnet"
+	}
+	if color == "" && agentConfig.CommandGroup == nil {
+		color = "orange"
+	}
+
+	var sb strings.Builder
+	sb.WriteString("---\n")
+	fmt.Fprintf(&sb, "name: %s\n", claudeAgentName(agentName, agentConfig))
+	fmt.Fprintf(&sb, "description: %s\n", yamlScalar(description))
+	fmt.Fprintf(&sb, "tools: %s\n", yamlScalar(strings.Join(tools, ", ")))
+	fmt.Fprintf(&sb, "model: %s\n", yamlScalar(model))
+	if color != "" {
+		fmt.Fprintf(&sb, "color: %s\n", yamlScalar(color))
+	}
+	sb.WriteString("---\n\n")
+	sb.WriteString(claudeAgentInstruction(agentConfig, runtime) + "\n")
+	if instructions := strings.TrimSpace(claudeAgent.Instructions); instructions != "" {
+		sb.WriteString("\n" + instructions + "\n")
+	}
+	sb.WriteString("\n" + claudeAgentUserStart + "\n")
+	if userSection != "" {
+		sb.WriteString(userSection + "\n")
+	}
+	sb.WriteString(claudeAgentUserEnd + "\n")
+	return sb.String()
+}
+
+// claudeAgentInstruction returns the instruction to load the rules of an
+// agent with the CLI installed with runtime, as written by init for the
+// default agent
+func claudeAgentInstruction(agentConfig *models.AgentConfig, runtime string) string {
+	invocation := runtimeInvocation(runtime) + " "
+	if agentConfig.CommandGroup != nil {
+		invocation += *agentConfig.CommandGroup + " "
+	}
+	return "You must read full output of `" + invocation + "\"${RELATIVE_PATH_OF_FILE_TO_EDIT_FROM_PROJECT_ROOT_EXCLUDING_LEADING_DOT_SLASH}\"` before create/update/delete any file, even if file does not exist yet."
+}
+
+// yamlScalar quotes s if it can't be written as a plain YAML scalar, or
+// would be read back as something else than the string s, like true or 1
+func yamlScalar(s string) string {
+	if s == "" || strings.ContainsAny(s[:1], "!&*-?[]{}|>'\"%@`#,:") || strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.ContainsAny(s, "\n\t") {
+		return jsonString(s)
+	}
+	var decoded interface{}
+	if err := yaml.Unmarshal([]byte(s), &decoded); err != nil || decoded != s {
+		return jsonString(s)
+	}
+	return s
+}
@@ -0,0 +1,118 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/dirt-rain/code-editor-agent/models"
+)
+
+const agentsTestConfig = `{
+  "agents": {
+    "code-editor": {"ruleFilePattern": "**/*.code-editor-agent.md", "commandGroup": null},
+    "code-reviewer": {
+      "ruleFilePattern": "**/*.code-reviewer.md",
+      "commandGroup": "reviewer",
+      "claudeAgent": {"description": "Reviews changes", "tools": ["Read", "Grep"]},
+    },
+  },
+}`
+
+func TestSyncAgents(t *testing.T) {
+	const agentPath = ".claude/agents/code-reviewer.md"
+	managed := "---\nname: code-reviewer\ndescription: Old\n---\n\n" + claudeAgentUserStart + "\nMy notes\n" + claudeAgentUserEnd + "\n"
+	handWritten := "---\nname: code-reviewer\n---\n\nReview carefully.\n"
+
+	tests := []struct {
+		name     string
+		existing string
+		force    bool
+		// want are the parts of the written file, in order; nil if unchanged
+		want []string
+	}{
+		{
+			name: "create",
+			want: []string{"description: Reviews changes", "tools: Read, Grep", "code-editor-agent reviewer \"", claudeAgentUserStart + "\n" + claudeAgentUserEnd},
+		},
+		{
+			name:     "update keeps the user section",
+			existing: managed,
+			want:     []string{"description: Reviews changes", claudeAgentUserStart + "\nMy notes\n" + claudeAgentUserEnd},
+		},
+		{
+			name:     "skip a hand-written file",
+			existing: handWritten,
+		},
+		{
+			name:     "force takes over a hand-written file",
+			existing: handWritten,
+			force:    true,
+			want:     []string{"description: Reviews changes", claudeAgentUserStart + "\nReview carefully.\n" + claudeAgentUserEnd},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			project := memFS{fstest.MapFS{models.ConfigFilePath: {Data: []byte(agentsTestConfig)}}}
+			if test.existing != "" {
+				project.MapFS[agentPath] = &fstest.MapFile{Data: []byte(test.existing)}
+			}
+			useProject(t, project)
+
+			if _, err := captureStdout(t, func() error { return SyncAgents(test.force) }); err != nil {
+				t.Fatal(err)
+			}
+			content := string(project.MapFS[agentPath].Data)
+			if test.want == nil {
+				if content != test.existing {
+					t.Errorf("%s = %q, want it unchanged", agentPath, content)
+				}
+				return
+			}
+			rest := content
+			for _, part := range test.want {
+				i := strings.Index(rest, part)
+				if i == -1 {
+					t.Fatalf("%s = %q, want %q after the previous parts", agentPath, content, part)
+				}
+				rest = rest[i+len(part):]
+			}
+			if strings.Count(content, "You must read full output") != 1 {
+				t.Errorf("%s = %q, want a single instruction", agentPath, content)
+			}
+
+			// A second sync changes nothing
+			output, err := captureStdout(t, func() error { return SyncAgents(false) })
+			if err != nil {
+				t.Fatal(err)
+			}
+			if output != "" || string(project.MapFS[agentPath].Data) != content {
+				t.Errorf("second sync printed %q and rewrote %s, want no change", output, agentPath)
+			}
+		})
+	}
+}
+
+func TestSyncAgentsAfterInit(t *testing.T) {
+	const agentPath = ".claude/agents/code-editor.md"
+	project := memFS{fstest.MapFS{}}
+	useProject(t, project)
+	if _, err := captureStdout(t, func() error { return Init(InitOptions{}) }); err != nil {
+		t.Fatal(err)
+	}
+	if _, managed := claudeAgentUserSection(string(project.MapFS[agentPath].Data)); !managed {
+		t.Fatalf("%s = %q, want the user section markers", agentPath, project.MapFS[agentPath].Data)
+	}
+
+	// The agent written by init follows configuration changes without --force
+	config := strings.Replace(configFileContent, `"commandGroup": null,`, `"commandGroup": null, "claudeAgent": {"model": "opus"},`, 1)
+	project.MapFS[models.ConfigFilePath] = &fstest.MapFile{Data: []byte(config)}
+	output, err := captureStdout(t, func() error { return SyncAgents(false) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(output, "Wrote "+agentPath) || !strings.Contains(string(project.MapFS[agentPath].Data), "model: opus") {
+		t.Errorf("sync-agents after init printed %q and wrote %q, want the updated agent", output, project.MapFS[agentPath].Data)
+	}
+}
@@ -0,0 +1,88 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/dirt-rain/code-editor-agent/config"
+)
+
+// ConfigActions are the actions of `cmd config`
+var ConfigActions = []string{"convert"}
+
+// ConfigConvertOptions holds the options of `cmd config convert`
+type ConfigConvertOptions struct {
+	To     string // format to convert to, one of config.Formats
+	DryRun bool   // print the converted file without writing anything
+}
+
+// ConfigConvert converts the configuration file to another format, replacing
+// it with the file of that format. Comments are not kept.
+func ConfigConvert(opts ConfigConvertOptions) error {
+	if !containsString(config.Formats, opts.To) {
+		return fmt.Errorf("Unknown configuration format '%s'. Expected one of: %s.", opts.To, strings.Join(config.Formats, ", "))
+	}
+
+	source, err := config.Resolve(projectFS)
+	if err != nil {
+		return err
+	}
+	raw, err := readFile(source)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", source, err)
+	}
+	if raw == nil {
+		return fmt.Errorf("Configuration file `%s` not found. Run `code-editor-agent cmd init` first.", source)
+	}
+	if config.FormatOf(source) == opts.To {
+		fmt.Printf("%s is already in %s format\n", source, opts.To)
+		return nil
+	}
+
+	cfg, err := config.Parse(raw, source)
+	if err != nil {
+		return err
+	}
+	target := config.FilePathOf(opts.To)
+	content, err := config.Convert(raw, source, opts.To)
+	if err != nil {
+		return fmt.Errorf("failed to convert %s: %w", source, err)
+	}
+	converted, err := config.Parse(content, target)
+	if err != nil {
+		return fmt.Errorf("failed to convert %s: %w", source, err)
+	}
+	if !reflect.DeepEqual(cfg, converted) {
+		return fmt.Errorf("failed to convert %s: the converted configuration differs", source)
+	}
+
+	if opts.DryRun {
+		fmt.Printf("==> %s\n", target)
+		fmt.Print(string(content))
+		return nil
+	}
+	if fileExists(target) {
+		return fmt.Errorf("File %s already exists. Remove it or convert to another format.", target)
+	}
+	if hasComments(raw, source) {
+		fmt.Fprintf(os.Stderr, "Warning: comments in %s are not carried over to %s\n", source, target)
+	}
+	if err := writeFile(target, content); err != nil {
+		return fmt.Errorf("failed to write %s: %w", target, err)
+	}
+	if err := removeFile(source); err != nil {
+		return fmt.Errorf("failed to remove %s: %w", source, err)
+	}
+	fmt.Printf("Converted %s to %s\n", source, target)
+	return nil
+}
+
+// hasComments reports whether the configuration file name may have comments
+func hasComments(raw []byte, name string) bool {
+	if config.FormatOf(name) == config.FormatJSONC {
+		return strings.Contains(string(raw), "//") || strings.Contains(string(raw), "/*")
+	}
+	return strings.Contains(string(raw), "#")
+}
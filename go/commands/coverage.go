@@ -0,0 +1,326 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/dirt-rain/code-editor-agent/config"
+	"github.com/dirt-rain/code-editor-agent/matcher"
+	"github.com/dirt-rain/code-editor-agent/models"
+	"github.com/dirt-rain/code-editor-agent/resolver"
+)
+
+// CoverageOptions holds the options of `cmd coverage`
+type CoverageOptions struct {
+	Agent  string // defaults to the agent with commandGroup: null
+	Format string
+	// Limit is the number of files in each file list, 0 for all
+	Limit int
+}
+
+// coverageReport is the outcome of resolving the rules of every project file
+type coverageReport struct {
+	Agent                 string               `json:"agent"`
+	Files                 int                  `json:"files"`
+	FilesWithoutRules     int                  `json:"filesWithoutRules"`
+	FilesWithDroppedRules int                  `json:"filesWithDroppedRules"`
+	Directories           []*directoryCoverage `json:"directories"`
+	WithoutRules          []string             `json:"withoutRules"`
+	DroppedRules          []droppedRules       `json:"droppedRules"`
+	Heaviest              []fileWeight         `json:"heaviest"`
+}
+
+// directoryCoverage sums up the files directly in a directory
+type directoryCoverage struct {
+	Directory             string `json:"directory"`
+	Files                 int    `json:"files"`
+	FilesWithoutRules     int    `json:"filesWithoutRules"`
+	FilesWithDroppedRules int    `json:"filesWithDroppedRules"`
+	// MatchedRules is the number of printed rules, summed over the files
+	MatchedRules int `json:"matchedRules"`
+	RuleBytes    int `json:"ruleBytes"`
+}
+
+// droppedRules lists the rules the priority filter drops for a file
+type droppedRules struct {
+	File  string   `json:"file"`
+	Rules []string `json:"rules"`
+}
+
+// fileWeight is the size of the rules printed for a file
+type fileWeight struct {
+	File   string `json:"file"`
+	Rules  int    `json:"rules"`
+	Bytes  int    `json:"bytes"`
+	Tokens int    `json:"estimatedTokens"`
+}
+
+// Coverage resolves the rules of the agent for every project file, skipping
+// excluded and gitignored files, and reports which files have no rules, where
+// rules are dropped by priority and which files get the most rules
+func Coverage(opts CoverageOptions) error {
+	cfg, err := config.Load(projectFS)
+	if err != nil {
+		return err
+	}
+	agentName := opts.Agent
+	if agentName == "" {
+		if agentName, err = config.FindAgentByCommandGroup(cfg, nil); err != nil {
+			return err
+		}
+	}
+
+	allAgentRules, err := loadRuleCache()
+	if err != nil {
+		return err
+	}
+	r := newResolver(cfg, allAgentRules)
+	agents, _, err := r.AgentRules(agentName)
+	if err != nil {
+		return err
+	}
+	// Agents missing from the cache were reported once above, not per file
+	for _, agent := range agents {
+		if _, ok := allAgentRules[agent]; !ok {
+			allAgentRules[agent] = nil
+		}
+	}
+
+	files, err := projectFiles(cfg.Exclude)
+	if err != nil {
+		return fmt.Errorf("failed to list project files: %w", err)
+	}
+
+	report := &coverageReport{Agent: agentName, WithoutRules: []string{}, DroppedRules: []droppedRules{}, Heaviest: []fileWeight{}}
+	directories := make(map[string]*directoryCoverage)
+	sizes := make(map[string]int)
+	for _, file := range files {
+		res, err := r.Explain(agentName, file)
+		if err != nil {
+			return err
+		}
+
+		dir := path.Dir(file)
+		if directories[dir] == nil {
+			directories[dir] = &directoryCoverage{Directory: dir}
+		}
+		directory := directories[dir]
+		directory.Files++
+		report.Files++
+
+		if len(res.Final) == 0 {
+			directory.FilesWithoutRules++
+			report.FilesWithoutRules++
+			report.WithoutRules = append(report.WithoutRules, file)
+		}
+
+		weight := fileWeight{File: file, Rules: len(res.Final)}
+		for _, rule := range res.Final {
+			size, ok := sizes[resolver.DisplayName(rule)]
+			if !ok {
+				size, err = ruleSize(r, rule)
+				if err != nil {
+					return err
+				}
+				sizes[resolver.DisplayName(rule)] = size
+			}
+			weight.Bytes += size
+		}
+		weight.Tokens = estimateTokens(weight.Bytes)
+		directory.MatchedRules += weight.Rules
+		directory.RuleBytes += weight.Bytes
+		if weight.Rules > 0 {
+			report.Heaviest = append(report.Heaviest, weight)
+		}
+
+		dropped := []string{}
+		for _, rule := range res.Dropped() {
+			dropped = append(dropped, resolver.DisplayName(rule))
+		}
+		if len(dropped) > 0 {
+			directory.FilesWithDroppedRules++
+			report.FilesWithDroppedRules++
+			report.DroppedRules = append(report.DroppedRules, droppedRules{File: file, Rules: dropped})
+		}
+	}
+
+	for _, directory := range directories {
+		report.Directories = append(report.Directories, directory)
+	}
+	sort.Slice(report.Directories, func(i, j int) bool {
+		return report.Directories[i].Directory < report.Directories[j].Directory
+	})
+	sort.SliceStable(report.Heaviest, func(i, j int) bool {
+		return report.Heaviest[i].Bytes > report.Heaviest[j].Bytes
+	})
+	if opts.Limit > 0 {
+		report.WithoutRules = report.WithoutRules[:min(opts.Limit, len(report.WithoutRules))]
+		report.DroppedRules = report.DroppedRules[:min(opts.Limit, len(report.DroppedRules))]
+		report.Heaviest = report.Heaviest[:min(opts.Limit, len(report.Heaviest))]
+	}
+
+	switch opts.Format {
+	case FormatJSON:
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	case FormatHTML:
+		return coverageHTML.Execute(os.Stdout, report)
+	default:
+		return writeCoverageTable(os.Stdout, report)
+	}
+}
+
+// ruleSize returns the size of the body printed for a rule, unrendered
+func ruleSize(r *resolver.Resolver, rule models.RuleWithDepth) (int, error) {
+	body, ok, err := r.Body(rule)
+	if err != nil || !ok {
+		return 0, err
+	}
+	return len(body), nil
+}
+
+// projectFiles lists the files of the project, skipping the .git directory,
+// excluded files and files ignored by .gitignore files
+func projectFiles(exclude []string) ([]string, error) {
+	gitignore := &matcher.Gitignore{}
+	files := []string{}
+	err := fs.WalkDir(projectFS, ".", func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if filePath == "." {
+				filePath = ""
+			} else if entry.Name() == ".git" || resolver.IsExcluded(filePath, exclude) || gitignore.Ignored(filePath, true) {
+				return fs.SkipDir
+			}
+			content, err := fs.ReadFile(projectFS, path.Join(filePath, ".gitignore"))
+			if err == nil {
+				gitignore.Add(filePath, string(content))
+			} else if !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+			return nil
+		}
+		if entry.Type().IsRegular() && !resolver.IsExcluded(filePath, exclude) && !gitignore.Ignored(filePath, false) {
+			files = append(files, filePath)
+		}
+		return nil
+	})
+	return files, err
+}
+
+func writeCoverageTable(out io.Writer, report *coverageReport) error {
+	fmt.Fprintf(out, "Agent: %s\n", report.Agent)
+	fmt.Fprintf(out, "%d files, %d without rules, %d with rules dropped by priority\n\n", report.Files, report.FilesWithoutRules, report.FilesWithDroppedRules)
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "DIRECTORY\tFILES\tNO RULES\tDROPPED\tRULES/FILE\tRULE BYTES")
+	for _, directory := range report.Directories {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%.1f\t%d\n", directory.Directory, directory.Files, directory.FilesWithoutRules, directory.FilesWithDroppedRules,
+			float64(directory.MatchedRules)/float64(directory.Files), directory.RuleBytes)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if len(report.WithoutRules) > 0 {
+		fmt.Fprintf(out, "\nFiles without rules (%d):\n", report.FilesWithoutRules)
+		for _, file := range report.WithoutRules {
+			fmt.Fprintf(out, "  %s\n", file)
+		}
+		writeMore(out, report.FilesWithoutRules, len(report.WithoutRules))
+	}
+
+	if len(report.DroppedRules) > 0 {
+		fmt.Fprintf(out, "\nFiles with rules dropped by priority (%d):\n", report.FilesWithDroppedRules)
+		for _, dropped := range report.DroppedRules {
+			fmt.Fprintf(out, "  %s: %s\n", dropped.File, strings.Join(dropped.Rules, ", "))
+		}
+		writeMore(out, report.FilesWithDroppedRules, len(report.DroppedRules))
+	}
+
+	if len(report.Heaviest) > 0 {
+		fmt.Fprintln(out, "\nHeaviest files:")
+		w = tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "FILE\tRULES\tBYTES\t~TOKENS")
+		for _, weight := range report.Heaviest {
+			fmt.Fprintf(w, "%s\t%d\t%d\t%d\n", weight.File, weight.Rules, weight.Bytes, weight.Tokens)
+		}
+		return w.Flush()
+	}
+	return nil
+}
+
+// writeMore notes how many entries of a truncated list are not shown
+func writeMore(out io.Writer, total, shown int) {
+	if total > shown {
+		fmt.Fprintf(out, "  ... and %d more\n", total-shown)
+	}
+}
+
+var coverageHTML = template.Must(template.New("coverage").Funcs(template.FuncMap{
+	"perFile": func(directory *directoryCoverage) string {
+		return fmt.Sprintf("%.1f", float64(directory.MatchedRules)/float64(directory.Files))
+	},
+	"join": strings.Join,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Rule coverage of {{.Agent}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; }
+td.number { text-align: right; }
+tr.uncovered td { background: #fdecea; }
+</style>
+</head>
+<body>
+<h1>Rule coverage of {{.Agent}}</h1>
+<p>{{.Files}} files, {{.FilesWithoutRules}} without rules, {{.FilesWithDroppedRules}} with rules dropped by priority.</p>
+
+<h2>Directories</h2>
+<table>
+<tr><th>Directory</th><th>Files</th><th>No rules</th><th>Dropped</th><th>Rules/file</th><th>Rule bytes</th></tr>
+{{- range .Directories}}
+<tr{{if eq .FilesWithoutRules .Files}} class="uncovered"{{end}}><td>{{.Directory}}</td><td class="number">{{.Files}}</td><td class="number">{{.FilesWithoutRules}}</td><td class="number">{{.FilesWithDroppedRules}}</td><td class="number">{{perFile .}}</td><td class="number">{{.RuleBytes}}</td></tr>
+{{- end}}
+</table>
+
+<h2>Files without rules ({{.FilesWithoutRules}})</h2>
+<ul>
+{{- range .WithoutRules}}
+<li>{{.}}</li>
+{{- end}}
+</ul>
+
+<h2>Files with rules dropped by priority ({{.FilesWithDroppedRules}})</h2>
+<table>
+<tr><th>File</th><th>Dropped rules</th></tr>
+{{- range .DroppedRules}}
+<tr><td>{{.File}}</td><td>{{join .Rules ", "}}</td></tr>
+{{- end}}
+</table>
+
+<h2>Heaviest files</h2>
+<table>
+<tr><th>File</th><th>Rules</th><th>Bytes</th><th>~Tokens</th></tr>
+{{- range .Heaviest}}
+<tr><td>{{.File}}</td><td class="number">{{.Rules}}</td><td class="number">{{.Bytes}}</td><td class="number">{{.Tokens}}</td></tr>
+{{- end}}
+</table>
+</body>
+</html>
+`))
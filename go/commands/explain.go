@@ -0,0 +1,92 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/dirt-rain/code-editor-agent/config"
+	"github.com/dirt-rain/code-editor-agent/resolver"
+)
+
+// Explain prints why each rule is or isn't loaded for a given file path, in
+// the given format
+func Explain(agentName, filePath, format string) error {
+	cfg, err := config.Load(projectFS)
+	if err != nil {
+		return err
+	}
+
+	allAgentRules, err := loadRuleCache()
+	if err != nil {
+		return err
+	}
+
+	res, err := newResolver(cfg, allAgentRules).Explain(agentName, filePath)
+	if err != nil {
+		return err
+	}
+
+	if format == FormatJSON {
+		return writeExplanationJSON(os.Stdout, res)
+	}
+	writeExplanation(os.Stdout, res)
+	return nil
+}
+
+// writeExplanation writes the status and reason of every rule in res
+func writeExplanation(w io.Writer, res *resolver.Resolution) {
+	fmt.Fprintf(w, "Agent: %s\n", res.AgentName)
+	if len(res.Agents) > 1 {
+		fmt.Fprintf(w, "Referenced agents: %s\n", strings.Join(res.Agents[1:], ", "))
+	}
+	fmt.Fprintf(w, "File: %s\n\n", res.FilePath)
+
+	for _, result := range res.Results {
+		fmt.Fprintf(w, "[%s] %s", result.Status, resolver.DisplayName(result.Rule))
+		if result.Rule.AgentDepth > 0 {
+			fmt.Fprintf(w, " (agent: %s)", res.Agents[result.Rule.AgentDepth])
+		}
+		fmt.Fprintln(w)
+		if result.Reason != "" {
+			fmt.Fprintf(w, "    %s\n", result.Reason)
+		}
+	}
+
+	fmt.Fprintf(w, "\n%d of %d rules would be printed.\n", len(res.Final), len(res.Results))
+}
+
+// explainedRule is a rule in the JSON output of explain
+type explainedRule struct {
+	Path    string `json:"path"`
+	Section string `json:"section,omitempty"`
+	Agent   string `json:"agent"`
+	Status  string `json:"status"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// writeExplanationJSON writes the status and reason of every rule in res as JSON
+func writeExplanationJSON(w io.Writer, res *resolver.Resolution) error {
+	rules := make([]explainedRule, 0, len(res.Results))
+	for _, result := range res.Results {
+		rules = append(rules, explainedRule{
+			Path:    result.Rule.Path,
+			Section: result.Rule.Section,
+			Agent:   res.Agents[result.Rule.AgentDepth],
+			Status:  result.Status,
+			Reason:  result.Reason,
+		})
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(struct {
+		Agent            string          `json:"agent"`
+		ReferencedAgents []string        `json:"referencedAgents"`
+		File             string          `json:"file"`
+		Printed          int             `json:"printed"`
+		Rules            []explainedRule `json:"rules"`
+	}{res.AgentName, res.Agents[1:], res.FilePath, len(res.Final), rules})
+}
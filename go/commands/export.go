@@ -0,0 +1,468 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dirt-rain/code-editor-agent/config"
+	"github.com/dirt-rain/code-editor-agent/matcher"
+	"github.com/dirt-rain/code-editor-agent/models"
+	"github.com/dirt-rain/code-editor-agent/resolver"
+)
+
+// Export targets
+const (
+	ExportCursor   = "cursor"
+	ExportCopilot  = "copilot"
+	ExportAgentsMD = "agents-md"
+	ExportWindsurf = "windsurf"
+)
+
+// ExportTargets lists the supported export targets
+var ExportTargets = []string{ExportCursor, ExportCopilot, ExportAgentsMD, ExportWindsurf}
+
+// exportMarker marks files written by export, so they can be updated and
+// removed without touching hand-written ones
+const exportMarker = "<!-- Generated by code-editor-agent cmd export. Do not edit. -->"
+
+// ExportOptions holds the options of `cmd export`
+type ExportOptions struct {
+	To    string
+	Agent string // defaults to the agent with commandGroup: null
+	Force bool   // overwrite files that were not generated by export
+}
+
+// exportedRule is a top-level rule with its references flattened into its body
+type exportedRule struct {
+	Slug  string
+	Title string
+	Globs []string // ["**"] for rules that apply to every file
+	Body  string
+}
+
+// alwaysApplies reports whether the rule applies to every file
+func (r *exportedRule) alwaysApplies() bool {
+	return len(r.Globs) == 1 && r.Globs[0] == "**"
+}
+
+// exportOutput is the set of files written by a target, plus where stale
+// generated files of a previous export may be found
+type exportOutput struct {
+	Files map[string]string
+	// ManagedDirs maps directories owned by the target to the suffix of its files
+	ManagedDirs  map[string]string
+	ManagedFiles []string
+}
+
+// Export converts the rules of an agent (and the agents it references) into
+// the rule format of another assistant
+func Export(opts ExportOptions) error {
+	cfg, err := config.Load(projectFS)
+	if err != nil {
+		return err
+	}
+
+	agentName := opts.Agent
+	if agentName == "" {
+		if agentName, err = config.FindAgentByCommandGroup(cfg, nil); err != nil {
+			return err
+		}
+	}
+
+	allAgentRules, err := loadRuleCache()
+	if err != nil {
+		return err
+	}
+
+	rules, err := exportRules(cfg, allAgentRules, agentName)
+	if err != nil {
+		return err
+	}
+
+	var output *exportOutput
+	switch opts.To {
+	case ExportCursor:
+		output = exportCursor(rules)
+	case ExportCopilot:
+		output = exportCopilot(rules)
+	case ExportAgentsMD:
+		output = exportAgentsMD(rules)
+	case ExportWindsurf:
+		output = exportWindsurf(rules)
+	default:
+		return fmt.Errorf("Unknown export target '%s'. Expected one of: %s.", opts.To, strings.Join(ExportTargets, ", "))
+	}
+
+	return writeExportOutput(output, opts.Force)
+}
+
+// exportRules builds one exported rule per rule that can be selected by a
+// file path. Rules without patterns are only reachable through references
+// and end up flattened into the bodies of the rules referencing them.
+func exportRules(cfg *models.Config, allAgentRules map[string][]models.RuleCacheEntry, agentName string) ([]*exportedRule, error) {
+	r := newResolver(cfg, allAgentRules)
+	_, allRules, err := r.AgentRules(agentName)
+	if err != nil {
+		return nil, err
+	}
+	tagMap := resolver.BuildTagMap(allRules)
+
+	warned := make(map[string]bool)
+	warn := func(format string, args ...interface{}) {
+		message := fmt.Sprintf(format, args...)
+		if !warned[message] {
+			warned[message] = true
+			fmt.Fprintf(os.Stderr, "Warning: %s\n", message)
+		}
+	}
+
+	exported := []*exportedRule{}
+	slugs := make(map[string]int)
+	for _, rule := range allRules {
+		globs := exportGlobs(rule, warn)
+		if len(globs) == 0 {
+			continue
+		}
+
+		// Flatten references the way load would for this rule as a top-level rule
+		flattened := resolver.Flatten(tagMap, rule)
+
+		bodies := []string{}
+		title := ""
+		for _, flatRule := range flattened {
+			if flatRule.When != nil {
+				warn("Rule file %s has a `when` condition, which can't be exported. It is exported unconditionally.", flatRule.Path)
+			}
+			if flatRule.Template {
+				warn("Rule file %s is a template, which can't be exported. It is exported unrendered.", flatRule.Path)
+			}
+			body, ok, err := r.Body(flatRule)
+			if err != nil {
+				return nil, err
+			}
+			if ok && strings.TrimSpace(body) != "" {
+				bodies = append(bodies, strings.TrimSpace(body))
+			}
+			if resolver.DisplayName(flatRule) == resolver.DisplayName(rule) && flatRule.AgentDepth == rule.AgentDepth {
+				title = firstHeading(body)
+			}
+		}
+		if rule.Priority != nil {
+			warn("Rule file %s has a priority, which can't be exported. It is ignored.", rule.Path)
+		}
+
+		slug := exportSlug(rule.Path)
+		slugs[slug]++
+		if slugs[slug] > 1 {
+			slug = fmt.Sprintf("%s-%d", slug, slugs[slug])
+		}
+
+		if title == "" {
+			title = slug
+		}
+
+		exported = append(exported, &exportedRule{Slug: slug, Title: title, Globs: globs, Body: strings.Join(bodies, "\n\n")})
+	}
+	return exported, nil
+}
+
+// firstHeading returns the text of the first heading of a markdown body
+func firstHeading(body string) string {
+	inFence := false
+	for _, line := range strings.Split(body, "\n") {
+		if resolver.IsFence(line) {
+			inFence = !inFence
+			continue
+		}
+		if _, text, ok := resolver.ParseHeading(line); ok && !inFence {
+			return text
+		}
+	}
+	return ""
+}
+
+// demoteHeadings moves every heading of a markdown body down by levels, so
+// the body can be nested under a heading of its own
+func demoteHeadings(body string, levels int) string {
+	lines := strings.Split(body, "\n")
+	inFence := false
+	for i, line := range lines {
+		if resolver.IsFence(line) {
+			inFence = !inFence
+			continue
+		}
+		if level, text, ok := resolver.ParseHeading(line); ok && !inFence {
+			lines[i] = strings.Repeat("#", min(level+levels, 6)) + " " + text
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// exportGlobs returns the globs a rule applies to in the target formats,
+// which have neither negation nor ignore patterns
+func exportGlobs(rule models.RuleWithDepth, warn func(string, ...interface{})) []string {
+	if rule.Scope == models.ScopeDirectory {
+		if dir := resolver.RuleDir(rule); dir != "." {
+			return []string{dir + "/**"}
+		}
+		return []string{"**"}
+	}
+
+	globs := []string{}
+	for _, raw := range rule.GetPatterns() {
+		pattern := matcher.ParsePattern(raw)
+		if pattern.Negate {
+			warn("Rule file %s has the negated pattern '%s', which can't be exported. It is dropped.", rule.Path, raw)
+			continue
+		}
+		globs = append(globs, pattern.Glob)
+	}
+	if len(rule.IgnorePatterns) > 0 && len(globs) > 0 {
+		warn("Rule file %s has ignorePatterns, which can't be exported. They are dropped.", rule.Path)
+	}
+	return globs
+}
+
+// joinGlobs joins globs with commas for the target formats, expanding {...}
+// alternatives first so the commas only separate globs
+func joinGlobs(globs []string) string {
+	expanded := []string{}
+	for _, glob := range globs {
+		for _, alternative := range matcher.ExpandBraces(glob) {
+			if !containsString(expanded, alternative) {
+				expanded = append(expanded, alternative)
+			}
+		}
+	}
+	return strings.Join(expanded, ",")
+}
+
+// exportSlug derives a file name from a rule path, e.g.
+// "src/api/handlers.code-editor-agent.md" becomes "src-api-handlers"
+func exportSlug(rulePath string) string {
+	rulePath = filepath.ToSlash(rulePath)
+	parts := []string{}
+	if dir := path.Dir(rulePath); dir != "." {
+		parts = append(parts, strings.Split(dir, "/")...)
+	}
+	name := path.Base(rulePath)
+	if idx := strings.Index(name, "."); idx >= 0 {
+		name = name[:idx]
+	}
+	if name != "" {
+		parts = append(parts, name)
+	}
+
+	var sb strings.Builder
+	for _, r := range strings.ToLower(strings.Join(parts, "-")) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-' || r == '_' {
+			sb.WriteRune(r)
+		} else {
+			sb.WriteRune('-')
+		}
+	}
+	slug := strings.Trim(sb.String(), "-")
+	if slug == "" {
+		return "root"
+	}
+	return slug
+}
+
+// exportCursor writes one .cursor/rules/<slug>.mdc file per rule
+func exportCursor(rules []*exportedRule) *exportOutput {
+	output := &exportOutput{
+		Files:       make(map[string]string),
+		ManagedDirs: map[string]string{".cursor/rules": ".mdc"},
+	}
+	for _, rule := range rules {
+		var sb strings.Builder
+		sb.WriteString("---\n")
+		fmt.Fprintf(&sb, "description: %s\n", yamlScalar(rule.Title))
+		if rule.alwaysApplies() {
+			sb.WriteString("globs:\nalwaysApply: true\n")
+		} else {
+			fmt.Fprintf(&sb, "globs: %s\nalwaysApply: false\n", joinGlobs(rule.Globs))
+		}
+		sb.WriteString("---\n")
+		writeExportBody(&sb, rule.Body)
+		output.Files[".cursor/rules/"+rule.Slug+".mdc"] = sb.String()
+	}
+	return output
+}
+
+// exportCopilot writes rules applying to every file to
+// .github/copilot-instructions.md and the others to
+// .github/instructions/<slug>.instructions.md
+func exportCopilot(rules []*exportedRule) *exportOutput {
+	const instructionsPath = ".github/copilot-instructions.md"
+	output := &exportOutput{
+		Files:        make(map[string]string),
+		ManagedDirs:  map[string]string{".github/instructions": ".instructions.md"},
+		ManagedFiles: []string{instructionsPath},
+	}
+
+	globalBodies := []string{}
+	for _, rule := range rules {
+		if rule.alwaysApplies() {
+			globalBodies = append(globalBodies, rule.Body)
+			continue
+		}
+		var sb strings.Builder
+		sb.WriteString("---\n")
+		fmt.Fprintf(&sb, "applyTo: %q\n", joinGlobs(rule.Globs))
+		sb.WriteString("---\n")
+		writeExportBody(&sb, rule.Body)
+		output.Files[".github/instructions/"+rule.Slug+".instructions.md"] = sb.String()
+	}
+
+	if len(globalBodies) > 0 {
+		var sb strings.Builder
+		sb.WriteString(exportMarker + "\n\n")
+		sb.WriteString(strings.Join(globalBodies, "\n\n"))
+		sb.WriteString("\n")
+		output.Files[instructionsPath] = sb.String()
+	}
+	return output
+}
+
+// exportAgentsMD writes every rule as a section of a single AGENTS.md
+func exportAgentsMD(rules []*exportedRule) *exportOutput {
+	const agentsPath = "AGENTS.md"
+	var sb strings.Builder
+	sb.WriteString(exportMarker + "\n\n")
+	sb.WriteString("# AGENTS.md\n\n")
+	sb.WriteString("Follow the rules of every section that applies to the files you create, update or delete.\n")
+	for _, rule := range rules {
+		fmt.Fprintf(&sb, "\n## %s\n\n", rule.Title)
+		if rule.alwaysApplies() {
+			sb.WriteString("Applies to all files.\n")
+		} else {
+			globs := make([]string, len(rule.Globs))
+			for i, glob := range rule.Globs {
+				globs[i] = "`" + glob + "`"
+			}
+			fmt.Fprintf(&sb, "Applies to: %s\n", strings.Join(globs, ", "))
+		}
+		if rule.Body != "" {
+			fmt.Fprintf(&sb, "\n%s\n", demoteHeadings(rule.Body, 2))
+		}
+	}
+	return &exportOutput{
+		Files:        map[string]string{agentsPath: sb.String()},
+		ManagedFiles: []string{agentsPath},
+	}
+}
+
+// exportWindsurf writes one .windsurf/rules/<slug>.md file per rule
+func exportWindsurf(rules []*exportedRule) *exportOutput {
+	output := &exportOutput{
+		Files:       make(map[string]string),
+		ManagedDirs: map[string]string{".windsurf/rules": ".md"},
+	}
+	for _, rule := range rules {
+		var sb strings.Builder
+		sb.WriteString("---\n")
+		if rule.alwaysApplies() {
+			sb.WriteString("trigger: always_on\n")
+		} else {
+			fmt.Fprintf(&sb, "trigger: glob\nglobs: %s\n", joinGlobs(rule.Globs))
+		}
+		sb.WriteString("---\n")
+		writeExportBody(&sb, rule.Body)
+		output.Files[".windsurf/rules/"+rule.Slug+".md"] = sb.String()
+	}
+	return output
+}
+
+// writeExportBody writes the marker and body following a file's front matter
+func writeExportBody(sb *strings.Builder, body string) {
+	sb.WriteString("\n" + exportMarker + "\n")
+	if body != "" {
+		sb.WriteString("\n" + body + "\n")
+	}
+}
+
+// writeExportOutput writes the exported files and removes files left over
+// from a previous export. Files not generated by export are never removed,
+// and only overwritten with force.
+func writeExportOutput(output *exportOutput, force bool) error {
+	paths := make([]string, 0, len(output.Files))
+	for filePath := range output.Files {
+		paths = append(paths, filePath)
+	}
+	sort.Strings(paths)
+
+	// Check everything before writing anything
+	for _, filePath := range paths {
+		generated, exists, err := isExportedFile(filePath)
+		if err != nil {
+			return err
+		}
+		if exists && !generated && !force {
+			return fmt.Errorf("File %s exists and was not generated by export. Use --force to overwrite it.", filePath)
+		}
+	}
+
+	// Find stale files from a previous export
+	stale := []string{}
+	candidates := append([]string{}, output.ManagedFiles...)
+	for dir, suffix := range output.ManagedDirs {
+		entries, err := fs.ReadDir(projectFS, dir)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to read %s: %w", dir, err)
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() && strings.HasSuffix(entry.Name(), suffix) {
+				candidates = append(candidates, dir+"/"+entry.Name())
+			}
+		}
+	}
+	for _, filePath := range candidates {
+		if _, ok := output.Files[filePath]; ok {
+			continue
+		}
+		if generated, _, err := isExportedFile(filePath); err != nil {
+			return err
+		} else if generated {
+			stale = append(stale, filePath)
+		}
+	}
+	sort.Strings(stale)
+
+	for _, filePath := range paths {
+		if err := writeFile(filePath, []byte(output.Files[filePath])); err != nil {
+			return fmt.Errorf("failed to write %s: %w", filePath, err)
+		}
+		fmt.Printf("Wrote %s\n", filePath)
+	}
+	for _, filePath := range stale {
+		if err := removeFile(filePath); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", filePath, err)
+		}
+		fmt.Printf("Removed %s\n", filePath)
+	}
+
+	if len(paths) == 0 {
+		fmt.Println("No rules to export.")
+	}
+	return nil
+}
+
+// isExportedFile reports whether a file was generated by export, and whether it exists
+func isExportedFile(filePath string) (bool, bool, error) {
+	content, err := readFile(filePath)
+	if err != nil {
+		return false, false, fmt.Errorf("failed to read %s: %w", filePath, err)
+	}
+	if content == nil {
+		return false, false, nil
+	}
+	return bytes.Contains(content, []byte(exportMarker)), true, nil
+}
@@ -0,0 +1,24 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestExportCursorDescription(t *testing.T) {
+	for _, title := range []string{"Go: error handling", "- list", "true", "Plain title", "#1 rule"} {
+		output := exportCursor([]*exportedRule{{Slug: "rule", Title: title, Globs: []string{"**/*.go"}}})
+		// Cursor writes globs unquoted, which is not YAML: only read the description
+		content := output.Files[".cursor/rules/rule.mdc"]
+		line := content[strings.Index(content, "description: "):]
+		line = line[:strings.Index(line, "\n")+1]
+		var fields struct {
+			Description string `yaml:"description"`
+		}
+		if err := yaml.Unmarshal([]byte(line), &fields); err != nil || fields.Description != title {
+			t.Errorf("description of %q read back as %q, %v", title, fields.Description, err)
+		}
+	}
+}
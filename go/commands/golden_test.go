@@ -0,0 +1,244 @@
+package commands
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/dirt-rain/code-editor-agent/config"
+	"github.com/dirt-rain/code-editor-agent/models"
+	"github.com/dirt-rain/code-editor-agent/utils"
+)
+
+var update = flag.Bool("update", false, "regenerate the test-snapshots files from the actual output")
+
+// loadsFileName lists the loads of a scenario, one per line with the arguments
+// given to code-editor-agent: an optional command group, then the path
+const loadsFileName = "loads.txt"
+
+// templateConfigFileName is copied to the configuration file instead of tmp/
+const templateConfigFileName = "config.json"
+
+var scenarioNamePattern = regexp.MustCompile(`^\d\d-`)
+
+// scenarioLoad is a rule load whose output goes to output.txt
+type scenarioLoad struct {
+	group *string
+	path  string
+}
+
+// scenario is a test-templates directory and the snapshot of the same name
+type scenario struct {
+	name string
+	// files are test-templates files copied into the project after init, by project path
+	files map[string]string
+	// keepMetaRule keeps the rule file written by init, when the snapshot has it
+	keepMetaRule bool
+	loads        []scenarioLoad
+	// snapshotFiles are project files compared with the snapshot, besides output.txt
+	snapshotFiles []string
+}
+
+// discoverScenarios reads the test-templates/NN-* directories, as test/test.sh runs them
+func discoverScenarios(t *testing.T) []scenario {
+	t.Helper()
+	entries, err := os.ReadDir(filepath.Join(testdataRoot, "test-templates"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scenarios := []scenario{}
+	for _, entry := range entries {
+		if !entry.IsDir() || !scenarioNamePattern.MatchString(entry.Name()) {
+			continue
+		}
+		s := scenario{name: entry.Name(), files: make(map[string]string)}
+
+		templates, err := os.ReadDir(filepath.Join(testdataRoot, "test-templates", s.name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, template := range templates {
+			source := s.name + "/" + template.Name()
+			switch template.Name() {
+			case loadsFileName:
+				s.loads = parseLoads(t, readTestdata(t, "test-templates/"+source))
+			case templateConfigFileName:
+				s.files[models.ConfigFilePath] = source
+			default:
+				s.files["tmp/"+template.Name()] = source
+			}
+		}
+		if len(s.loads) == 0 {
+			t.Fatalf("test-templates/%s: no loads in %s", s.name, loadsFileName)
+		}
+
+		snapshotDir := filepath.Join(testdataRoot, "test-snapshots", s.name)
+		err = filepath.WalkDir(snapshotDir, func(filePath string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			rel, err := filepath.Rel(snapshotDir, filePath)
+			if err != nil {
+				return err
+			}
+			rel = filepath.ToSlash(rel)
+			if rel == "output.txt" {
+				return nil
+			}
+			if rel == metaRuleFilePath {
+				s.keepMetaRule = true
+			}
+			s.snapshotFiles = append(s.snapshotFiles, rel)
+			return nil
+		})
+		if err != nil && !(*update && os.IsNotExist(err)) {
+			t.Fatal(err)
+		}
+		scenarios = append(scenarios, s)
+	}
+	if len(scenarios) == 0 {
+		t.Fatal("no scenarios found in test-templates")
+	}
+	return scenarios
+}
+
+func parseLoads(t *testing.T, content []byte) []scenarioLoad {
+	t.Helper()
+	loads := []scenarioLoad{}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		switch len(fields) {
+		case 0:
+		case 1:
+			loads = append(loads, scenarioLoad{path: fields[0]})
+		case 2:
+			group := fields[0]
+			loads = append(loads, scenarioLoad{group: &group, path: fields[1]})
+		default:
+			t.Fatalf("invalid load %q in %s", scanner.Text(), loadsFileName)
+		}
+	}
+	return loads
+}
+
+// runScenario runs init, generate and the loads of s on the project in
+// projectFS and returns the load output
+func runScenario(t *testing.T, s scenario) string {
+	t.Helper()
+	if _, err := captureStdout(t, func() error { return Init(InitOptions{}) }); err != nil {
+		t.Fatalf("init: %v", err)
+	}
+	if !s.keepMetaRule {
+		if err := projectFS.Remove(metaRuleFilePath); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for target, source := range s.files {
+		if err := projectFS.MkdirAll(path.Dir(target), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := projectFS.WriteFile(target, readTestdata(t, "test-templates/"+source), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := captureStdout(t, func() error { return Generate(false) }); err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+
+	cfg, err := config.Load(projectFS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := ""
+	for _, load := range s.loads {
+		agentName, err := config.FindAgentByCommandGroup(cfg, load.group)
+		if err != nil {
+			t.Fatalf("load %s: %v", load.path, err)
+		}
+		loaded, err := captureStdout(t, func() error { return Load(agentName, load.path, FormatText) })
+		if err != nil {
+			t.Fatalf("load %s: %v", load.path, err)
+		}
+		output += loaded
+	}
+	return output
+}
+
+// compareSnapshot compares the output and the project files of s with its snapshot
+func compareSnapshot(t *testing.T, s scenario, output string) {
+	t.Helper()
+	snapshot := "test-snapshots/" + s.name + "/"
+	if expected := string(readTestdata(t, snapshot+"output.txt")); output != expected {
+		t.Errorf("output.txt differs from the snapshot\ngot:\n%s\nwant:\n%s", output, expected)
+	}
+	for _, file := range s.snapshotFiles {
+		actual, err := fs.ReadFile(projectFS, file)
+		if err != nil {
+			t.Errorf("%s: %v", file, err)
+			continue
+		}
+		if !bytes.Equal(actual, readTestdata(t, snapshot+file)) {
+			t.Errorf("%s differs from the snapshot", file)
+		}
+	}
+}
+
+// updateSnapshot writes the output and the project files of s to its snapshot
+func updateSnapshot(t *testing.T, s scenario, output string) {
+	t.Helper()
+	snapshotDir := filepath.Join(testdataRoot, "test-snapshots", s.name)
+	write := func(name string, content []byte) {
+		target := filepath.Join(snapshotDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(target, content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("output.txt", []byte(output))
+	for _, file := range s.snapshotFiles {
+		content, err := fs.ReadFile(projectFS, file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		write(file, content)
+	}
+}
+
+// TestGolden runs each scenario with the real commands in a temporary
+// directory. Run with -update to regenerate the snapshots.
+func TestGolden(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range discoverScenarios(t) {
+		t.Run(s.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.Chdir(dir); err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { os.Chdir(wd) })
+			useProject(t, utils.DirFS("."))
+
+			output := runScenario(t, s)
+			if *update {
+				updateSnapshot(t, s, output)
+				return
+			}
+			compareSnapshot(t, s, output)
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package commands
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/dirt-rain/code-editor-agent/config"
+	"github.com/dirt-rain/code-editor-agent/models"
+)
+
+// Hook modes
+const (
+	HookModeContext = "context"
+	HookModeBlock   = "block"
+)
+
+// hookCommand is the command registered in .claude/settings.json by
+// `cmd init --hook`. The hook is Go-only, so it always runs the Go binary,
+// whatever the runtime.
+var hookCommand = runtimeInvocations[RuntimeGo] + " cmd hook"
+
+// hookToolMatcher matches the Claude Code tools that edit files
+const hookToolMatcher = "Edit|Write|MultiEdit"
+
+// hookInput is the subset of the Claude Code PreToolUse hook input we use
+type hookInput struct {
+	SessionID     string `json:"session_id"`
+	Cwd           string `json:"cwd"`
+	HookEventName string `json:"hook_event_name"`
+	ToolName      string `json:"tool_name"`
+	ToolInput     struct {
+		FilePath string `json:"file_path"`
+	} `json:"tool_input"`
+}
+
+// hookOutput is the Claude Code hook response
+type hookOutput struct {
+	HookSpecificOutput hookSpecificOutput `json:"hookSpecificOutput"`
+}
+
+type hookSpecificOutput struct {
+	HookEventName            string `json:"hookEventName"`
+	PermissionDecision       string `json:"permissionDecision,omitempty"`
+	PermissionDecisionReason string `json:"permissionDecisionReason,omitempty"`
+	AdditionalContext        string `json:"additionalContext,omitempty"`
+}
+
+// Hook handles a Claude Code PreToolUse hook call. It reads the hook input
+// from stdin and, if rules apply to the edited file, writes a hook response
+// to stdout that either adds the rules as context or, in block mode, denies
+// the first edit of each file per session with the rules as the reason. With
+// daemon set, the rules come from a running daemon if one is reachable.
+func Hook(agentName, mode string, daemon bool, stdin io.Reader, stdout io.Writer) error {
+	if mode != HookModeContext && mode != HookModeBlock {
+		return fmt.Errorf("Unknown hook mode: %s", mode)
+	}
+
+	var input hookInput
+	if err := json.NewDecoder(stdin).Decode(&input); err != nil {
+		return fmt.Errorf("failed to parse hook input: %w", err)
+	}
+
+	switch input.ToolName {
+	case "Edit", "Write", "MultiEdit":
+	default:
+		return nil
+	}
+	if input.ToolInput.FilePath == "" {
+		return nil
+	}
+
+	filePath, ok := projectRelativePath(input.Cwd, input.ToolInput.FilePath)
+	if !ok {
+		// Files outside the project have no rules
+		return nil
+	}
+
+	rules, err := hookRules(agentName, filePath, daemon)
+	if err != nil || rules == "" {
+		return err
+	}
+
+	eventName := input.HookEventName
+	if eventName == "" {
+		eventName = "PreToolUse"
+	}
+	output := hookOutput{HookSpecificOutput: hookSpecificOutput{HookEventName: eventName}}
+
+	if mode == HookModeBlock {
+		seen, err := markHookFileSeen(input.SessionID, filePath)
+		if err != nil {
+			return err
+		}
+		if seen {
+			return nil
+		}
+		output.HookSpecificOutput.PermissionDecision = "deny"
+		output.HookSpecificOutput.PermissionDecisionReason = fmt.Sprintf(
+			"Read the following rules for %s first, then retry the same edit following them.\n\n%s", filePath, rules)
+	} else {
+		output.HookSpecificOutput.AdditionalContext = fmt.Sprintf(
+			"Rules for %s from code-editor-agent:\n\n%s", filePath, rules)
+	}
+
+	encoder := json.NewEncoder(stdout)
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(output)
+}
+
+// hookRules returns the rules of the agent for filePath, or "" if none apply
+func hookRules(agentName, filePath string, daemon bool) (string, error) {
+	if daemon {
+		if rules, ok := requestDaemon(daemonRequest{Command: "rules", Agent: agentName, Path: filePath}); ok {
+			return rules, nil
+		}
+	}
+
+	cfg, err := config.Load(projectFS)
+	if err != nil {
+		return "", err
+	}
+	allAgentRules, err := loadRuleCache()
+	if err != nil {
+		return "", err
+	}
+
+	r := newResolver(cfg, allAgentRules)
+	res, err := r.Explain(agentName, filePath)
+	if err != nil || len(res.Candidates) == 0 {
+		return "", err
+	}
+
+	var sb strings.Builder
+	if err := writeRules(&sb, r, res); err != nil {
+		return "", err
+	}
+	logUsage(cfg, res)
+	return sb.String(), nil
+}
+
+// projectRelativePath converts the tool's file path to a slash-separated
+// path relative to the project root (the current directory)
+func projectRelativePath(cwd, filePath string) (string, bool) {
+	if !filepath.IsAbs(filePath) {
+		if cwd == "" {
+			return filepath.ToSlash(filepath.Clean(filePath)), true
+		}
+		filePath = filepath.Join(cwd, filePath)
+	}
+
+	root, err := os.Getwd()
+	if err != nil {
+		return "", false
+	}
+	rel, err := filepath.Rel(root, filePath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// markHookFileSeen records that the rules for filePath were shown in the
+// session and reports whether they had already been shown before. The state
+// is kept inside the project, so it is private to whoever can edit it.
+func markHookFileSeen(sessionID, filePath string) (bool, error) {
+	sum := sha256.Sum256([]byte(sessionID))
+	statePath := path.Join(models.HookStateDirPath, hex.EncodeToString(sum[:8])+".json")
+
+	seen := map[string]bool{}
+	if content, err := fs.ReadFile(projectFS, statePath); err == nil {
+		_ = json.Unmarshal(content, &seen)
+	}
+	if seen[filePath] {
+		return true, nil
+	}
+	seen[filePath] = true
+
+	if err := projectFS.MkdirAll(models.HookStateDirPath, 0755); err != nil {
+		return false, fmt.Errorf("failed to create hook state directory: %w", err)
+	}
+	content, err := json.Marshal(seen)
+	if err != nil {
+		return false, err
+	}
+	if err := projectFS.WriteFile(statePath, content, 0644); err != nil {
+		return false, fmt.Errorf("failed to write hook state: %w", err)
+	}
+	return false, nil
+}
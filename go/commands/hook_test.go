@@ -0,0 +1,143 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dirt-rain/code-editor-agent/models"
+)
+
+// runHook runs the hook without a daemon and returns its decoded response,
+// or nil if it printed nothing
+func runHook(t *testing.T, mode, input string) *hookSpecificOutput {
+	t.Helper()
+	var stdout bytes.Buffer
+	if err := Hook("code-editor", mode, false, strings.NewReader(input), &stdout); err != nil {
+		t.Fatalf("Hook() error = %v", err)
+	}
+	if stdout.Len() == 0 {
+		return nil
+	}
+	var output hookOutput
+	if err := json.Unmarshal(stdout.Bytes(), &output); err != nil {
+		t.Fatalf("invalid hook output %q: %v", stdout.String(), err)
+	}
+	return &output.HookSpecificOutput
+}
+
+// hookInputFor returns the hook input of a tool call
+func hookInputFor(session, cwd, tool, filePath string) string {
+	input := map[string]interface{}{
+		"session_id":      session,
+		"cwd":             cwd,
+		"hook_event_name": "PreToolUse",
+		"tool_name":       tool,
+		"tool_input":      map[string]string{"file_path": filePath},
+	}
+	content, _ := json.Marshal(input)
+	return string(content)
+}
+
+func TestHookContext(t *testing.T) {
+	newMemProject(t, map[string]string{
+		"src.code-editor-agent.md": "---\npatterns: \"src/**\"\n---\n# Source rules\n",
+	})
+	root, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		input string
+		want  bool
+	}{
+		{name: "edit", input: hookInputFor("s", root, "Edit", "src/a.go"), want: true},
+		{name: "write with an absolute path", input: hookInputFor("s", root, "Write", filepath.Join(root, "src", "a.go")), want: true},
+		{name: "path relative to the tool's directory", input: hookInputFor("s", filepath.Join(root, "src"), "MultiEdit", "a.go"), want: true},
+		{name: "other tool", input: hookInputFor("s", root, "Read", "src/a.go")},
+		{name: "file without rules", input: hookInputFor("s", root, "Edit", "README.md")},
+		{name: "file outside the project", input: hookInputFor("s", root, "Edit", filepath.Join(filepath.Dir(root), "other", "src", "a.go"))},
+		{name: "no file path", input: hookInputFor("s", root, "Edit", "")},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			output := runHook(t, HookModeContext, test.input)
+			if !test.want {
+				if output != nil {
+					t.Errorf("Hook() = %+v, want no output", output)
+				}
+				return
+			}
+			if output == nil {
+				t.Fatal("Hook() printed nothing, want the rules as context")
+			}
+			if output.HookEventName != "PreToolUse" || output.PermissionDecision != "" {
+				t.Errorf("Hook() = %+v, want a PreToolUse response without decision", output)
+			}
+			if !strings.HasPrefix(output.AdditionalContext, "Rules for src/a.go from code-editor-agent:") || !strings.Contains(output.AdditionalContext, "# Source rules") {
+				t.Errorf("additionalContext = %q, want the rules of src/a.go", output.AdditionalContext)
+			}
+		})
+	}
+}
+
+func TestHookBlock(t *testing.T) {
+	project := newMemProject(t, map[string]string{
+		"src.code-editor-agent.md": "---\npatterns: \"src/**\"\n---\n# Source rules\n",
+	})
+
+	calls := []struct {
+		session  string
+		filePath string
+		deny     bool
+	}{
+		{session: "one", filePath: "src/a.go", deny: true},
+		{session: "one", filePath: "src/a.go", deny: false},
+		{session: "one", filePath: "src/b.go", deny: true},
+		{session: "two", filePath: "src/a.go", deny: true},
+		{session: "one", filePath: "README.md", deny: false},
+	}
+	for _, call := range calls {
+		output := runHook(t, HookModeBlock, hookInputFor(call.session, "", "Edit", call.filePath))
+		if !call.deny {
+			if output != nil {
+				t.Errorf("Hook(%s, %s) = %+v, want no output", call.session, call.filePath, output)
+			}
+			continue
+		}
+		if output == nil || output.PermissionDecision != "deny" || output.AdditionalContext != "" {
+			t.Fatalf("Hook(%s, %s) = %+v, want a denial", call.session, call.filePath, output)
+		}
+		if !strings.Contains(output.PermissionDecisionReason, "rules for "+call.filePath) || !strings.Contains(output.PermissionDecisionReason, "# Source rules") {
+			t.Errorf("permissionDecisionReason = %q, want the rules of %s", output.PermissionDecisionReason, call.filePath)
+		}
+	}
+
+	// the state of both sessions is kept inside the project
+	states := 0
+	for name := range project.MapFS {
+		if strings.HasPrefix(name, models.HookStateDirPath+"/") {
+			states++
+		}
+	}
+	if states != 2 {
+		t.Errorf("found %d hook state files in %s, want 2", states, models.HookStateDirPath)
+	}
+}
+
+func TestHookErrors(t *testing.T) {
+	newMemProject(t, map[string]string{})
+	var stdout bytes.Buffer
+	if err := Hook("code-editor", "warn", false, strings.NewReader("{}"), &stdout); err == nil {
+		t.Error("Hook() with an unknown mode succeeded, want an error")
+	}
+	if err := Hook("code-editor", HookModeContext, false, strings.NewReader("not json"), &stdout); err == nil {
+		t.Error("Hook() with invalid input succeeded, want an error")
+	}
+}
@@ -0,0 +1,382 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/dirt-rain/code-editor-agent/config"
+	"github.com/dirt-rain/code-editor-agent/frontmatter"
+	"github.com/dirt-rain/code-editor-agent/matcher"
+)
+
+// Import sources
+const (
+	ImportCursor   = "cursor"
+	ImportCopilot  = "copilot"
+	ImportAgentsMD = "agents-md"
+)
+
+// ImportSources lists the supported import sources
+var ImportSources = []string{ImportCursor, ImportCopilot, ImportAgentsMD}
+
+// ImportOptions holds the options of `cmd import`
+type ImportOptions struct {
+	From   string
+	Agent  string // agent whose ruleFilePattern names the files, defaults to the agent with commandGroup: null
+	DryRun bool   // print the files instead of writing them
+	Force  bool   // overwrite existing rule files
+}
+
+// importedRule is a rule converted from another assistant's rule file
+type importedRule struct {
+	Source   string
+	Name     string
+	Patterns []string
+	Tags     []string
+	Priority *int
+	Body     string
+	// Unmapped lists front matter fields (and semantics) that have no equivalent
+	Unmapped []string
+}
+
+// Import converts rule files of another assistant into rule files of this project
+func Import(opts ImportOptions) error {
+	cfg, err := config.Load(projectFS)
+	if err != nil {
+		return err
+	}
+
+	agentName := opts.Agent
+	if agentName == "" {
+		if agentName, err = config.FindAgentByCommandGroup(cfg, nil); err != nil {
+			return err
+		}
+	}
+	agentConfig, ok := cfg.Agents[agentName]
+	if !ok {
+		return fmt.Errorf("Agent '%s' not found in configuration.", agentName)
+	}
+	suffix, err := ruleFileSuffix(agentConfig.RuleFilePattern)
+	if err != nil {
+		return err
+	}
+
+	var sources []string
+	var convert func(sourcePath string, content string) (*importedRule, error)
+	switch opts.From {
+	case ImportCursor:
+		sources, err = findFiles(".cursor/rules/**/*.mdc", cfg.Exclude)
+		convert = importCursorRule
+	case ImportCopilot:
+		sources, err = findFiles(".github/instructions/**/*.instructions.md", cfg.Exclude)
+		if fileExists(".github/copilot-instructions.md") {
+			sources = append([]string{".github/copilot-instructions.md"}, sources...)
+		}
+		convert = importCopilotRule
+	case ImportAgentsMD:
+		sources, err = findFiles("**/AGENTS.md", cfg.Exclude)
+		convert = importAgentsMDRule
+	default:
+		return fmt.Errorf("Unknown import source '%s'. Expected one of: %s.", opts.From, strings.Join(ImportSources, ", "))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to find files to import: %w", err)
+	}
+
+	rules := []*importedRule{}
+	names := make(map[string]int)
+	for _, sourcePath := range sources {
+		content, err := fs.ReadFile(projectFS, sourcePath)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", sourcePath, err)
+		}
+		// Files written by `cmd export` already have a source of truth
+		if strings.Contains(string(content), exportMarker) {
+			fmt.Fprintf(os.Stderr, "Skipping %s: generated by cmd export\n", sourcePath)
+			continue
+		}
+		rule, err := convert(sourcePath, string(content))
+		if err == nil {
+			err = matcher.ValidatePatterns(rule.Patterns)
+		}
+		if err != nil {
+			return fmt.Errorf("Failed to import %s: %v.", sourcePath, err)
+		}
+		names[rule.Name]++
+		if names[rule.Name] > 1 {
+			rule.Name = fmt.Sprintf("%s-%d", rule.Name, names[rule.Name])
+		}
+		rules = append(rules, rule)
+	}
+
+	if len(rules) == 0 {
+		fmt.Println("No rules to import.")
+		return nil
+	}
+
+	// Check everything before writing anything
+	if !opts.DryRun && !opts.Force {
+		for _, rule := range rules {
+			if fileExists(rule.Name + suffix) {
+				return fmt.Errorf("File %s already exists. Use --force to overwrite it.", rule.Name+suffix)
+			}
+		}
+	}
+
+	for _, rule := range rules {
+		targetPath := rule.Name + suffix
+		content := formatImportedRule(rule)
+		if opts.DryRun {
+			fmt.Printf("==> %s (from %s) <==\n%s\n", targetPath, rule.Source, content)
+			continue
+		}
+		if err := writeFile(targetPath, []byte(content)); err != nil {
+			return fmt.Errorf("failed to write %s: %w", targetPath, err)
+		}
+		fmt.Printf("Wrote %s (from %s)\n", targetPath, rule.Source)
+	}
+
+	// Report what could not be mapped
+	reported := false
+	for _, rule := range rules {
+		if len(rule.Unmapped) == 0 {
+			continue
+		}
+		if !reported {
+			fmt.Println("\nNot mapped:")
+			reported = true
+		}
+		fmt.Printf("  %s:\n", rule.Source)
+		for _, unmapped := range rule.Unmapped {
+			fmt.Printf("    - %s\n", unmapped)
+		}
+	}
+
+	if !opts.DryRun {
+		fmt.Println("\nRun `code-editor-agent cmd generate` to update the rule cache.")
+	}
+	return nil
+}
+
+// ruleFileSuffix returns the file name suffix of rule files matched by a
+// ruleFilePattern like "**/*.code-editor-agent.md", or by the first such
+// alternative of "**/{*.code-editor-agent.md,code-editor-agent.md}"
+func ruleFileSuffix(ruleFilePattern string) (string, error) {
+	for _, alternative := range matcher.ExpandBraces(ruleFilePattern) {
+		base := path.Base(alternative)
+		if strings.HasPrefix(base, "*") && !strings.ContainsAny(base[1:], "*?[{}\\") {
+			return base[1:], nil
+		}
+	}
+	return "", fmt.Errorf("Can't derive a rule file name from ruleFilePattern '%s'. Expected a pattern like '**/*.code-editor-agent.md'.", ruleFilePattern)
+}
+
+// importCursorRule converts a .cursor/rules/*.mdc file. Rules that apply
+// neither always nor to globs are requested by the model from their
+// description; they become rules without patterns, tagged to be referenced.
+func importCursorRule(sourcePath, content string) (*importedRule, error) {
+	fields, body, err := splitImportFrontMatter(content)
+	if err != nil {
+		return nil, err
+	}
+	rule := &importedRule{Source: sourcePath, Name: importName(sourcePath, ".mdc"), Body: body}
+
+	globs := importGlobs(fields["globs"])
+	alwaysApply, _ := fields["alwaysApply"].(bool)
+	switch {
+	case alwaysApply:
+		rule.Patterns = []string{"**"}
+		if len(globs) > 0 {
+			rule.Unmapped = append(rule.Unmapped, fmt.Sprintf("globs: %s (superseded by alwaysApply: true)", strings.Join(globs, ",")))
+		}
+	case len(globs) > 0:
+		rule.Patterns = globs
+	default:
+		rule.Tags = []string{rule.Name}
+		rule.Unmapped = append(rule.Unmapped, fmt.Sprintf("agent-requested rule: imported without patterns, reference it with the tag '%s'", rule.Name))
+	}
+	delete(fields, "globs")
+	delete(fields, "alwaysApply")
+
+	importCommonFields(rule, fields)
+	return rule, nil
+}
+
+// importCopilotRule converts .github/copilot-instructions.md (applying to
+// every file) or a .github/instructions/*.instructions.md file
+func importCopilotRule(sourcePath, content string) (*importedRule, error) {
+	if filepath.ToSlash(sourcePath) == ".github/copilot-instructions.md" {
+		return &importedRule{Source: sourcePath, Name: "copilot-instructions", Patterns: []string{"**"}, Body: strings.TrimSpace(content)}, nil
+	}
+
+	fields, body, err := splitImportFrontMatter(content)
+	if err != nil {
+		return nil, err
+	}
+	rule := &importedRule{Source: sourcePath, Name: importName(sourcePath, ".instructions.md"), Body: body}
+
+	if applyTo := importGlobs(fields["applyTo"]); len(applyTo) > 0 {
+		rule.Patterns = applyTo
+	} else {
+		rule.Tags = []string{rule.Name}
+		rule.Unmapped = append(rule.Unmapped, fmt.Sprintf("no applyTo: imported without patterns, reference it with the tag '%s'", rule.Name))
+	}
+	delete(fields, "applyTo")
+
+	importCommonFields(rule, fields)
+	return rule, nil
+}
+
+// importAgentsMDRule converts an AGENTS.md file, which applies to the files
+// in its directory
+func importAgentsMDRule(sourcePath, content string) (*importedRule, error) {
+	dir := path.Dir(filepath.ToSlash(sourcePath))
+	rule := &importedRule{Source: sourcePath, Name: "agents", Patterns: []string{"**"}, Body: strings.TrimSpace(content)}
+	if dir != "." {
+		rule.Name = strings.ReplaceAll(dir, "/", "-") + "-agents"
+		rule.Patterns = []string{dir + "/**"}
+		rule.Unmapped = append(rule.Unmapped, "nested AGENTS.md precedence: the rules of every enclosing AGENTS.md apply as well")
+	}
+	return rule, nil
+}
+
+// importCommonFields maps front matter fields this project shares with the
+// source format, and reports the rest
+func importCommonFields(rule *importedRule, fields map[string]interface{}) {
+	if priority, ok := fields["priority"].(int); ok {
+		rule.Priority = &priority
+		delete(fields, "priority")
+	}
+	if tags := importGlobs(fields["tags"]); len(tags) > 0 {
+		rule.Tags = append(rule.Tags, tags...)
+		delete(fields, "tags")
+	}
+
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		rule.Unmapped = append(rule.Unmapped, fmt.Sprintf("%s: %v", key, fields[key]))
+	}
+}
+
+// importName derives a rule name from a source path without its suffix
+func importName(sourcePath, suffix string) string {
+	return exportSlug(strings.TrimSuffix(path.Base(filepath.ToSlash(sourcePath)), suffix))
+}
+
+// importGlobs normalizes a comma-separated string or a list of globs
+func importGlobs(value interface{}) []string {
+	var raw []string
+	switch v := value.(type) {
+	case string:
+		raw = matcher.SplitGlobs(v)
+	case []interface{}:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				raw = append(raw, s)
+			}
+		}
+	}
+	globs := []string{}
+	for _, glob := range raw {
+		if glob = strings.TrimSpace(glob); glob != "" {
+			globs = append(globs, glob)
+		}
+	}
+	return globs
+}
+
+// splitImportFrontMatter returns the front matter fields and the body of a
+// rule file. Other assistants often write unquoted globs like `globs: **/*.ts`,
+// which is not valid YAML, so plain `key: value` lines are accepted as a fallback.
+func splitImportFrontMatter(content string) (map[string]interface{}, string, error) {
+	doc, err := frontmatter.Split([]byte(content))
+	if err != nil {
+		return nil, "", err
+	}
+	fields := map[string]interface{}{}
+	switch doc.Format {
+	case "":
+		return fields, strings.TrimSpace(doc.Body), nil
+	case frontmatter.TOML, frontmatter.JSON:
+		if err := doc.Decode(&fields); err != nil {
+			return nil, "", err
+		}
+		return fields, strings.TrimSpace(doc.Body), nil
+	}
+	frontMatter := strings.ReplaceAll(doc.Data, "\r\n", "\n")
+
+	if err := yaml.Unmarshal([]byte(frontMatter), &fields); err != nil {
+		fields = map[string]interface{}{}
+		for _, line := range strings.Split(frontMatter, "\n") {
+			key, value, ok := strings.Cut(line, ":")
+			if !ok || strings.TrimSpace(key) == "" {
+				continue
+			}
+			value = strings.Trim(strings.TrimSpace(value), `"'`)
+			switch value {
+			case "true":
+				fields[strings.TrimSpace(key)] = true
+			case "false":
+				fields[strings.TrimSpace(key)] = false
+			default:
+				fields[strings.TrimSpace(key)] = value
+			}
+		}
+	}
+	return fields, strings.TrimSpace(doc.Body), nil
+}
+
+// formatImportedRule formats an imported rule as a rule file
+func formatImportedRule(rule *importedRule) string {
+	var sb strings.Builder
+	sb.WriteString("---\n")
+	switch len(rule.Patterns) {
+	case 0:
+		sb.WriteString("patterns: []\n")
+	case 1:
+		fmt.Fprintf(&sb, "patterns: %s\n", jsonString(rule.Patterns[0]))
+	default:
+		fmt.Fprintf(&sb, "patterns: %s\n", jsonList(rule.Patterns))
+	}
+	if rule.Priority != nil {
+		fmt.Fprintf(&sb, "priority: %d\n", *rule.Priority)
+	}
+	if len(rule.Tags) > 0 {
+		fmt.Fprintf(&sb, "tags: %s\n", jsonList(rule.Tags))
+	}
+	sb.WriteString("---\n")
+	if rule.Body != "" {
+		sb.WriteString("\n" + rule.Body + "\n")
+	}
+	return sb.String()
+}
+
+// jsonString quotes s; JSON strings are valid YAML flow scalars
+func jsonString(s string) string {
+	var sb strings.Builder
+	encoder := json.NewEncoder(&sb)
+	encoder.SetEscapeHTML(false)
+	_ = encoder.Encode(s)
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// jsonList formats values as a YAML flow sequence like ["a", "b"]
+func jsonList(values []string) string {
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = jsonString(value)
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
@@ -0,0 +1,69 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestImportBraceGlobs(t *testing.T) {
+	project := memFS{fstest.MapFS{
+		".github/instructions/ts.instructions.md": {Data: []byte("---\napplyTo: \"src/**/*.{ts,tsx}, test/**\"\n---\n# TypeScript\n")},
+		".cursor/rules/web.mdc":                   {Data: []byte("---\nglobs: web/*.{js,jsx},web/[a,b]/**\n---\n# Web\n")},
+	}}
+	useProject(t, project)
+
+	tests := []struct {
+		from, file, want string
+	}{
+		{ImportCopilot, "ts.code-editor-agent.md", `patterns: ["src/**/*.{ts,tsx}", "test/**"]`},
+		{ImportCursor, "web.code-editor-agent.md", `patterns: ["web/*.{js,jsx}", "web/[a,b]/**"]`},
+	}
+	for _, test := range tests {
+		if _, err := captureStdout(t, func() error { return Import(ImportOptions{From: test.from}) }); err != nil {
+			t.Fatalf("Import(%s) error = %v", test.from, err)
+		}
+		content := string(project.MapFS[test.file].Data)
+		if !strings.Contains(content, test.want+"\n") {
+			t.Errorf("%s = %q, want it to contain %q", test.file, content, test.want)
+		}
+	}
+}
+
+func TestImportInvalidGlob(t *testing.T) {
+	useProject(t, memFS{fstest.MapFS{
+		".cursor/rules/bad.mdc": {Data: []byte("---\nglobs: \"src/{a\"\n---\n")},
+	}})
+	_, err := captureStdout(t, func() error { return Import(ImportOptions{From: ImportCursor}) })
+	if err == nil || !strings.Contains(err.Error(), "invalid glob pattern") {
+		t.Errorf("Import() error = %v, want an invalid glob pattern", err)
+	}
+}
+
+func TestJoinGlobs(t *testing.T) {
+	got := joinGlobs([]string{"src/**/*.{ts,tsx}", "src/**/*.ts", "docs/**"})
+	if want := "src/**/*.ts,src/**/*.tsx,docs/**"; got != want {
+		t.Errorf("joinGlobs() = %q, want %q", got, want)
+	}
+}
+
+func TestRuleFileSuffix(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    string
+		wantErr bool
+	}{
+		{pattern: "**/*.code-editor-agent.md", want: ".code-editor-agent.md"},
+		{pattern: "**/{*.code-editor-agent.md,code-editor-agent.md}", want: ".code-editor-agent.md"},
+		{pattern: "**/{code-reviewer.md,*.code-reviewer.md}", want: ".code-reviewer.md"},
+		{pattern: "rules/*.md", want: ".md"},
+		{pattern: "**/rules.md", wantErr: true},
+		{pattern: "**/*.{a,b}*.md", wantErr: true},
+	}
+	for _, test := range tests {
+		got, err := ruleFileSuffix(test.pattern)
+		if (err != nil) != test.wantErr || got != test.want {
+			t.Errorf("ruleFileSuffix(%q) = %q, %v, want %q (error %v)", test.pattern, got, err, test.want, test.wantErr)
+		}
+	}
+}
@@ -2,19 +2,21 @@ package commands
 
 import (
 	"fmt"
-	"os"
-	"path/filepath"
+	"path"
 
+	"github.com/dirt-rain/code-editor-agent/config"
 	"github.com/dirt-rain/code-editor-agent/models"
-	"github.com/dirt-rain/code-editor-agent/utils"
 )
 
+// defaultRuleFilePattern is the ruleFilePattern of the default agent
+const defaultRuleFilePattern = "**/{*.code-editor-agent.md,code-editor-agent.md}"
+
 const configFileContent = `{
   "exclude": ["./node_modules/**"],
   "agents": {
     // Default agent: code-editor
     "code-editor": {
-      "ruleFilePattern": "**/*.code-editor-agent.md",
+      "ruleFilePattern": "` + defaultRuleFilePattern + `",
       "commandGroup": null,
     },
     // You can add more agents like this:
@@ -88,52 +90,147 @@ And agent is going to edit ` + "`src/lib/Button.tsx`" + ` file, then:
 - Only modify this file if absolutely necessary, and after careful consideration. Changes here can affect how the agent applies rules, so make sure the modification is truly needed.
 `
 
-const claudeAgentContent = `---
-name: code-editor
-description: For every code editing
-tools: Bash, Read, Edit, Write, Grep, Glob
-model: sonnet
-color: orange
----
+// claudeAgentContent returns the default Claude agent, running the CLI
+// installed with runtime. It is rendered like sync-agents does, so later
+// syncs keep it up to date.
+func claudeAgentContent(runtime string) string {
+	return formatClaudeAgent("code-editor", &models.AgentConfig{}, "", runtime)
+}
 
-You must read full output of ` + "`npx code-editor-agent \"${RELATIVE_PATH_OF_FILE_TO_EDIT_FROM_PROJECT_ROOT_EXCLUDING_LEADING_DOT_SLASH}\"`" + ` before create/update/delete any file, even if file does not exist yet.
-`
+// InitOptions controls optional steps of Init
+type InitOptions struct {
+	// Hook registers the PreToolUse hook in .claude/settings.json
+	Hook bool
+	// Runtime ("go" or "node") adds the file editing rules to CLAUDE.md and
+	// the permission to run the CLI to .claude/settings.json, and is how the
+	// agent and the hook run the CLI
+	Runtime string
+	// DryRun prints what would change instead of writing anything
+	DryRun bool
+	// Presets are the starter rule packs to install
+	Presets []string
+	// Agents are the starter agents to create; the editor is always created
+	Agents []string
+}
 
-// Init initializes the project with default configuration
-func Init() error {
-	if utils.FileExists(models.RuleCacheFilePath) {
+// Init initializes the project with default configuration. Updating
+// CLAUDE.md and .claude/settings.json is idempotent, so with Hook or
+// Runtime it can also run on an initialized project.
+func Init(opts InitOptions) error {
+	if opts.Runtime != "" && runtimePermissions[opts.Runtime] == "" {
+		return fmt.Errorf("Unknown runtime '%s'. Expected '%s' or '%s'.", opts.Runtime, RuntimeGo, RuntimeNode)
+	}
+	if err := validateStarterOptions(&opts); err != nil {
+		return err
+	}
+	updatesClaude := opts.Hook || opts.Runtime != ""
+
+	if !fileExists(models.RuleCacheFilePath) {
+		files, err := initFiles(opts)
+		if err != nil {
+			return err
+		}
+		if opts.DryRun {
+			for _, file := range append(sortedKeys(files), models.RuleCacheFilePath) {
+				fmt.Printf("Would create %s\n", file)
+			}
+		} else if err := scaffold(opts, files); err != nil {
+			return err
+		}
+	} else if !updatesClaude {
 		return fmt.Errorf("Very likely you have already initialized the agent. To re-initialize, delete the %s file and retry.", models.RuleCacheFilePath)
+	} else {
+		fmt.Println("Already initialized, only updating Claude Code files")
 	}
 
-	// Create config file
-	configDir := filepath.Dir(models.ConfigFilePath)
-	if err := os.MkdirAll(configDir, 0755); err != nil {
-		return fmt.Errorf("failed to create config directory: %w", err)
+	if opts.Runtime != "" {
+		if err := updateClaudeMD(opts.DryRun); err != nil {
+			return err
+		}
 	}
-	if err := os.WriteFile(models.ConfigFilePath, []byte(configFileContent), 0644); err != nil {
-		return fmt.Errorf("failed to write config file: %w", err)
+	if updatesClaude {
+		return updateClaudeSettings(opts)
 	}
+	return nil
+}
 
-	// Create RENAME-ME.code-editor-agent.md
-	if err := os.WriteFile("RENAME-ME.code-editor-agent.md", []byte(ruleFileContent), 0644); err != nil {
-		return fmt.Errorf("failed to write rule file: %w", err)
+// metaRuleFilePath is the rule about rule files written by init
+const metaRuleFilePath = "RENAME-ME.code-editor-agent.md"
+
+// templateFiles returns the files init writes for opts, by path: the config,
+// the rule about rule files, the Claude agent and the starter rules of presets
+func templateFiles(opts InitOptions) (map[string]string, error) {
+	files, err := starterRuleFiles(opts)
+	if err != nil {
+		return nil, err
+	}
+	configPath, err := config.Resolve(projectFS)
+	if err != nil {
+		return nil, err
 	}
+	configContent := starterConfigContent(opts)
+	if format := config.FormatOf(configPath); format != config.FormatJSONC {
+		// Keep the format of an existing YAML or TOML configuration file
+		converted, err := config.Convert([]byte(configContent), models.ConfigFilePath, format)
+		if err != nil {
+			return nil, err
+		}
+		configContent = string(converted)
+	}
+	files[configPath] = configContent
+	files[metaRuleFilePath] = ruleFileContent
+	files[".claude/agents/code-editor.md"] = claudeAgentContent(initRuntime(opts))
+	return files, nil
+}
 
-	// Create .claude/agents/code-editor.md
-	claudeAgentsDir := ".claude/agents"
-	if err := os.MkdirAll(claudeAgentsDir, 0755); err != nil {
-		return fmt.Errorf("failed to create claude agents directory: %w", err)
+// initFiles returns the files created by init, by path
+func initFiles(opts InitOptions) (map[string]string, error) {
+	starterRules, err := starterRuleFiles(opts)
+	if err != nil {
+		return nil, err
+	}
+	// Starter rules never overwrite existing files
+	for _, file := range sortedKeys(starterRules) {
+		if fileExists(file) {
+			return nil, fmt.Errorf("File %s already exists. Remove it or choose other presets.", file)
+		}
 	}
-	if err := os.WriteFile(filepath.Join(claudeAgentsDir, "code-editor.md"), []byte(claudeAgentContent), 0644); err != nil {
-		return fmt.Errorf("failed to write claude agent file: %w", err)
+	return templateFiles(opts)
+}
+
+// scaffold writes the files created by init, keeping existing ones, records
+// them for upgrade and generates the cache
+func scaffold(opts InitOptions, files map[string]string) error {
+	base := &templateBase{Presets: opts.Presets, Agents: opts.Agents, Files: make(map[string]string)}
+	for _, file := range sortedKeys(files) {
+		if fileExists(file) {
+			fmt.Printf("Keeping existing %s, run `code-editor-agent cmd upgrade` to merge template changes\n", file)
+			continue
+		}
+		if err := writeFile(file, []byte(files[file])); err != nil {
+			return fmt.Errorf("failed to write %s: %w", file, err)
+		}
+		base.Files[file] = files[file]
 	}
 
 	// Create cache directory and generate initial cache
-	cacheDir := filepath.Dir(models.RuleCacheFilePath)
-	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+	if err := projectFS.MkdirAll(path.Dir(models.RuleCacheFilePath), 0755); err != nil {
 		return fmt.Errorf("failed to create cache directory: %w", err)
 	}
 
+	if err := writeTemplateBase(base); err != nil {
+		return err
+	}
+
 	// Generate initial cache
-	return Generate(true)
+	return generate(true, initRuntime(opts))
+}
+
+// initRuntime returns the runtime the agents run the CLI with: the one
+// requested, or else the one allowed in .claude/settings.json
+func initRuntime(opts InitOptions) string {
+	if opts.Runtime != "" {
+		return opts.Runtime
+	}
+	return projectRuntime()
 }
@@ -0,0 +1,51 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestInitRuntime(t *testing.T) {
+	tests := []struct {
+		runtime    string
+		invocation string
+	}{
+		{runtime: RuntimeGo, invocation: "code-editor-agent"},
+		{runtime: RuntimeNode, invocation: "npx code-editor-agent"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.runtime, func(t *testing.T) {
+			project := memFS{fstest.MapFS{}}
+			useProject(t, project)
+			opts := InitOptions{Hook: true, Runtime: test.runtime, Agents: []string{"reviewer"}}
+			if _, err := captureStdout(t, func() error { return Init(opts) }); err != nil {
+				t.Fatal(err)
+			}
+
+			for _, agentPath := range []string{".claude/agents/code-editor.md", ".claude/agents/code-reviewer.md"} {
+				agent := string(project.MapFS[agentPath].Data)
+				if want := "output of `" + test.invocation + " "; !strings.Contains(agent, want) {
+					t.Errorf("%s = %q, want instruction running %q", agentPath, agent, test.invocation)
+				}
+			}
+			// the hook is Go-only, so it runs the Go binary with either runtime
+			settings := string(project.MapFS[ClaudeSettingsFilePath].Data)
+			for _, want := range []string{`"command": "code-editor-agent cmd hook"`, `"Bash(` + test.invocation + `:*)"`} {
+				if !strings.Contains(settings, want) {
+					t.Errorf("%s = %s, want %s", ClaudeSettingsFilePath, settings, want)
+				}
+			}
+
+			// sync-agents keeps the runtime allowed in the settings
+			if _, err := captureStdout(t, func() error { return SyncAgents(true) }); err != nil {
+				t.Fatal(err)
+			}
+			agent := string(project.MapFS[".claude/agents/code-editor.md"].Data)
+			if want := "output of `" + test.invocation + " \""; !strings.Contains(agent, want) || strings.Count(agent, "output of") != 1 {
+				t.Errorf("synced agent = %q, want a single instruction running %q", agent, test.invocation)
+			}
+		})
+	}
+}
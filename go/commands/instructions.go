@@ -0,0 +1,114 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/dirt-rain/code-editor-agent/utils"
+)
+
+// ClaudeMDFilePath is the project instructions file read by Claude Code
+const ClaudeMDFilePath = "CLAUDE.md"
+
+// Runtimes the CLI can be installed with
+const (
+	RuntimeGo   = "go"
+	RuntimeNode = "node"
+)
+
+// runtimeInvocations run the CLI, per runtime
+var runtimeInvocations = map[string]string{
+	RuntimeGo:   "code-editor-agent",
+	RuntimeNode: "npx code-editor-agent",
+}
+
+// runtimeInvocation returns the command running the CLI installed with
+// runtime, npx like the Node.js version if the runtime is unknown
+func runtimeInvocation(runtime string) string {
+	if invocation, ok := runtimeInvocations[runtime]; ok {
+		return invocation
+	}
+	return runtimeInvocations[RuntimeNode]
+}
+
+// runtimePermissions are the Claude Code permissions to run the CLI, per runtime
+var runtimePermissions = map[string]string{
+	RuntimeGo:   "Bash(code-editor-agent:*)",
+	RuntimeNode: "Bash(npx code-editor-agent:*)",
+}
+
+// Markers around the block of CLAUDE.md managed by init
+const (
+	claudeMDBlockStart = "<!-- BEGIN code-editor-agent: managed by `code-editor-agent cmd init`, changes are overwritten -->"
+	claudeMDBlockEnd   = "<!-- END code-editor-agent -->"
+)
+
+// claudeMDInstructions tells the main session to edit files through the agent
+const claudeMDInstructions = `> **DO NOT FORGET THESE RULES, EVEN IF CONTEXT IS COMPACTED.**
+
+**File Editing Rules:**
+1. Never edit files directly. Always use the ` + "`@code-editor`" + ` agent for file modifications.
+2. When instructing file edits, describe the file's purpose and what it should accomplish,
+   rather than specifying exact file contents.
+3. The ` + "`@code-editor`" + ` agent has specialized context for file editing.
+`
+
+// claudeMDUnmanagedHint identifies the instructions pasted by hand from the README
+const claudeMDUnmanagedHint = "Always use the `@code-editor` agent for file modifications."
+
+// mergeClaudeMD returns content with the managed block inserted or replaced.
+// It reports false if content already has the instructions outside the block.
+func mergeClaudeMD(content string) (string, bool) {
+	block := claudeMDBlockStart + "\n" + claudeMDInstructions + claudeMDBlockEnd + "\n"
+
+	start := strings.Index(content, claudeMDBlockStart)
+	if start >= 0 {
+		if end := strings.Index(content[start:], claudeMDBlockEnd); end >= 0 {
+			end += start + len(claudeMDBlockEnd)
+			if end < len(content) && content[end] == '\n' {
+				end++
+			}
+			return content[:start] + block + content[end:], true
+		}
+	}
+
+	if strings.Contains(content, claudeMDUnmanagedHint) {
+		return content, false
+	}
+	if content == "" {
+		return block, true
+	}
+	if !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	return content + "\n" + block, true
+}
+
+// updateClaudeMD inserts or updates the managed block of CLAUDE.md
+func updateClaudeMD(dryRun bool) error {
+	raw, err := readFile(ClaudeMDFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", ClaudeMDFilePath, err)
+	}
+
+	merged, ok := mergeClaudeMD(string(raw))
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Warning: %s already has the file editing rules outside a managed block. Remove them to let init manage them.\n", ClaudeMDFilePath)
+		return nil
+	}
+	if merged == string(raw) {
+		fmt.Printf("%s is up to date\n", ClaudeMDFilePath)
+		return nil
+	}
+
+	if dryRun {
+		fmt.Print(utils.UnifiedDiff("a/"+ClaudeMDFilePath, "b/"+ClaudeMDFilePath, string(raw), merged))
+		return nil
+	}
+	if err := writeFile(ClaudeMDFilePath, []byte(merged)); err != nil {
+		return fmt.Errorf("failed to write %s: %w", ClaudeMDFilePath, err)
+	}
+	fmt.Printf("Updated %s\n", ClaudeMDFilePath)
+	return nil
+}
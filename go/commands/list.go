@@ -0,0 +1,262 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/dirt-rain/code-editor-agent/config"
+	"github.com/dirt-rain/code-editor-agent/models"
+	"github.com/dirt-rain/code-editor-agent/resolver"
+)
+
+// What `cmd list` lists
+const (
+	ListAgents = "agents"
+	ListRules  = "rules"
+	ListTags   = "tags"
+)
+
+// ListKinds lists what `cmd list` can list
+var ListKinds = []string{ListAgents, ListRules, ListTags}
+
+// listedAgent is an agent in the output of `cmd list agents`
+type listedAgent struct {
+	Name            string   `json:"name"`
+	CommandGroup    *string  `json:"commandGroup"`
+	RuleFilePattern string   `json:"ruleFilePattern"`
+	References      []string `json:"references"`
+	DirectoryRules  string   `json:"directoryRules,omitempty"`
+	Rules           int      `json:"rules"`
+}
+
+// listedRule is a rule in the output of `cmd list rules`
+type listedRule struct {
+	Agent    string   `json:"agent"`
+	Path     string   `json:"path"`
+	Patterns []string `json:"patterns"`
+	Scope    string   `json:"scope,omitempty"`
+	Priority *int     `json:"priority"`
+	Order    *int     `json:"order"`
+	Tags     []string `json:"tags"`
+	// FrontMatter is the front matter format: yaml, toml, json or none
+	FrontMatter string `json:"frontMatter"`
+	Bytes       int    `json:"bytes"`
+	// Tokens is a rough estimate of the tokens the body takes in the context
+	Tokens int `json:"estimatedTokens"`
+}
+
+// listedTag is a tag in the output of `cmd list tags`
+type listedTag struct {
+	Agent string   `json:"agent"`
+	Tag   string   `json:"tag"`
+	Rules []string `json:"rules"`
+	// ReferencedBy are the rules referencing the tag
+	ReferencedBy []string `json:"referencedBy"`
+}
+
+// List prints the agents, rules or tags of the configuration and cache, of
+// every agent or only of agentName, as a table or as JSON
+func List(kind, agentName, format string) error {
+	cfg, err := config.Load(projectFS)
+	if err != nil {
+		return err
+	}
+
+	agentNames := make([]string, 0, len(cfg.Agents))
+	for name := range cfg.Agents {
+		agentNames = append(agentNames, name)
+	}
+	sort.Strings(agentNames)
+	if agentName != "" {
+		if _, ok := cfg.Agents[agentName]; !ok {
+			return fmt.Errorf("Agent '%s' not found in configuration.", agentName)
+		}
+		agentNames = []string{agentName}
+	}
+
+	// Agents can be listed before the cache is generated
+	var allAgentRules map[string][]models.RuleCacheEntry
+	if kind != ListAgents || fileExists(models.RuleCacheFilePath) {
+		if allAgentRules, err = loadRuleCache(); err != nil {
+			return err
+		}
+	}
+
+	var items interface{}
+	var write func(w *tabwriter.Writer)
+	switch kind {
+	case ListAgents:
+		agents := listAgents(cfg, allAgentRules, agentNames)
+		items, write = agents, func(w *tabwriter.Writer) { writeAgentsTable(w, agents, allAgentRules != nil) }
+	case ListRules:
+		rules := listRules(allAgentRules, agentNames)
+		items, write = rules, func(w *tabwriter.Writer) { writeRulesTable(w, rules) }
+	case ListTags:
+		tags := listTags(allAgentRules, agentNames)
+		items, write = tags, func(w *tabwriter.Writer) { writeTagsTable(w, tags) }
+	default:
+		return fmt.Errorf("Unknown list '%s'. Expected one of: %s.", kind, strings.Join(ListKinds, ", "))
+	}
+
+	if format == FormatJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(items)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	write(w)
+	return w.Flush()
+}
+
+func listAgents(cfg *models.Config, allAgentRules map[string][]models.RuleCacheEntry, agentNames []string) []listedAgent {
+	agents := make([]listedAgent, 0, len(agentNames))
+	for _, name := range agentNames {
+		agentConfig := cfg.Agents[name]
+		references := agentConfig.References
+		if references == nil {
+			references = []string{}
+		}
+		agents = append(agents, listedAgent{
+			Name:            name,
+			CommandGroup:    agentConfig.CommandGroup,
+			RuleFilePattern: agentConfig.RuleFilePattern,
+			References:      references,
+			DirectoryRules:  agentConfig.DirectoryRules,
+			Rules:           len(allAgentRules[name]),
+		})
+	}
+	return agents
+}
+
+func listRules(allAgentRules map[string][]models.RuleCacheEntry, agentNames []string) []listedRule {
+	rules := []listedRule{}
+	for _, agentName := range agentNames {
+		for _, rule := range allAgentRules[agentName] {
+			bytes := 0
+			if body, err := extractBody(rule.Path); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: Failed to read %s, run `code-editor-agent cmd generate` to update the cache: %v\n", rule.Path, err)
+			} else {
+				bytes = len(body)
+			}
+			format := "none"
+			if detected, err := resolver.FrontMatterFormat(projectFS, rule.Path); err == nil && detected != "" {
+				format = string(detected)
+			}
+			tags := rule.Tags
+			if tags == nil {
+				tags = []string{}
+			}
+			rules = append(rules, listedRule{
+				Agent:       agentName,
+				Path:        rule.Path,
+				Patterns:    rule.GetPatterns(),
+				Scope:       rule.Scope,
+				Priority:    rule.Priority,
+				Order:       rule.Order,
+				Tags:        tags,
+				FrontMatter: format,
+				Bytes:       bytes,
+				Tokens:      estimateTokens(bytes),
+			})
+		}
+	}
+	return rules
+}
+
+// estimateTokens estimates the number of tokens of a text of the given size,
+// at about 4 bytes per token
+func estimateTokens(bytes int) int {
+	return (bytes + 3) / 4
+}
+
+func listTags(allAgentRules map[string][]models.RuleCacheEntry, agentNames []string) []listedTag {
+	tags := []listedTag{}
+	for _, agentName := range agentNames {
+		byTag := make(map[string]*listedTag)
+		tagOf := func(tag string) *listedTag {
+			if byTag[tag] == nil {
+				byTag[tag] = &listedTag{Agent: agentName, Tag: tag, Rules: []string{}, ReferencedBy: []string{}}
+			}
+			return byTag[tag]
+		}
+		for _, rule := range allAgentRules[agentName] {
+			for _, tag := range rule.Tags {
+				tagOf(tag).Rules = append(tagOf(tag).Rules, rule.Path)
+			}
+			for _, ref := range append(append([]string{}, rule.ReferencesIfTop...), rule.ReferencesAlways...) {
+				target, _ := resolver.SplitReference(ref)
+				if resolver.IsDocumentReference(target) {
+					continue
+				}
+				tag := tagOf(target)
+				if !containsString(tag.ReferencedBy, rule.Path) {
+					tag.ReferencedBy = append(tag.ReferencedBy, rule.Path)
+				}
+			}
+		}
+
+		names := make([]string, 0, len(byTag))
+		for name := range byTag {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			tags = append(tags, *byTag[name])
+		}
+	}
+	return tags
+}
+
+func writeAgentsTable(w io.Writer, agents []listedAgent, hasCache bool) {
+	fmt.Fprintln(w, "AGENT\tCOMMAND GROUP\tRULE FILE PATTERN\tREFERENCES\tRULES")
+	for _, agent := range agents {
+		commandGroup := "null"
+		if agent.CommandGroup != nil {
+			commandGroup = *agent.CommandGroup
+		}
+		rules := "-"
+		if hasCache {
+			rules = fmt.Sprint(agent.Rules)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", agent.Name, commandGroup, agent.RuleFilePattern, orDash(strings.Join(agent.References, ", ")), rules)
+	}
+}
+
+func writeRulesTable(w io.Writer, rules []listedRule) {
+	fmt.Fprintln(w, "AGENT\tPATH\tPATTERNS\tPRIORITY\tORDER\tTAGS\tFRONT MATTER\tSIZE")
+	for _, rule := range rules {
+		patterns := strings.Join(rule.Patterns, ", ")
+		if rule.Scope == models.ScopeDirectory {
+			patterns = "(directory)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%d B, ~%d tokens\n", rule.Agent, rule.Path, orDash(patterns), optionalInt(rule.Priority), optionalInt(rule.Order), orDash(strings.Join(rule.Tags, ", ")), rule.FrontMatter, rule.Bytes, rule.Tokens)
+	}
+}
+
+func writeTagsTable(w io.Writer, tags []listedTag) {
+	fmt.Fprintln(w, "AGENT\tTAG\tRULES\tREFERENCED BY")
+	for _, tag := range tags {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", tag.Agent, tag.Tag, orDash(strings.Join(tag.Rules, ", ")), orDash(strings.Join(tag.ReferencedBy, ", ")))
+	}
+}
+
+// orDash returns value, or "-" if it is empty
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+// optionalInt formats an optional integer, "-" if not set
+func optionalInt(value *int) string {
+	if value == nil {
+		return "-"
+	}
+	return fmt.Sprint(*value)
+}
@@ -0,0 +1,236 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dirt-rain/code-editor-agent/config"
+	"github.com/dirt-rain/code-editor-agent/mcp"
+	"github.com/dirt-rain/code-editor-agent/models"
+	"github.com/dirt-rain/code-editor-agent/resolver"
+)
+
+// pathArguments are the arguments of the load_rules and explain tools
+type pathArguments struct {
+	Path  string  `json:"path"`
+	Group *string `json:"group"`
+}
+
+var pathArgumentsSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"path": map[string]interface{}{
+			"type":        "string",
+			"description": "Path of the file to edit, relative to the project root",
+		},
+		"group": map[string]interface{}{
+			"type":        "string",
+			"description": "commandGroup of the agent; omit for the agent with commandGroup: null",
+		},
+	},
+	"required": []string{"path"},
+}
+
+// MCP runs a Model Context Protocol server over stdio exposing rule resolution
+// as tools. Like the daemon, it keeps the config and cache in memory and
+// reloads them when they change.
+func MCP(version string, stdin io.Reader, stdout io.Writer) error {
+	state := &projectState{}
+	server := &mcp.Server{
+		Name:    "code-editor-agent",
+		Version: version,
+		Tools: []mcp.Tool{
+			{
+				Name:        "load_rules",
+				Description: "Load the file-specific rules to follow before creating, updating or deleting a file.",
+				InputSchema: pathArgumentsSchema,
+				Handler: func(arguments json.RawMessage) (string, error) {
+					return mcpResolve(state, arguments, func(w io.Writer, r *resolver.Resolver, res *resolver.Resolution) error {
+						if err := writeRules(w, r, res); err != nil {
+							return err
+						}
+						logUsage(r.Config, res)
+						return nil
+					})
+				},
+			},
+			{
+				Name:        "explain",
+				Description: "Explain which rules apply to a file and why each other rule does not.",
+				InputSchema: pathArgumentsSchema,
+				Handler: func(arguments json.RawMessage) (string, error) {
+					return mcpResolve(state, arguments, func(w io.Writer, _ *resolver.Resolver, res *resolver.Resolution) error {
+						writeExplanation(w, res)
+						return nil
+					})
+				},
+			},
+			{
+				Name:        "list_rules",
+				Description: "List the rules of an agent with their patterns, tags, priority and order.",
+				InputSchema: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"agent": map[string]interface{}{
+							"type":        "string",
+							"description": "Agent name; omit for the agent with commandGroup: null",
+						},
+					},
+				},
+				Handler: func(arguments json.RawMessage) (string, error) {
+					return mcpListRules(state, arguments)
+				},
+			},
+		},
+		ListResources: func() ([]mcp.Resource, error) {
+			return mcpListResources(state)
+		},
+		ReadResource: func(uri string) (string, string, error) {
+			return mcpReadResource(state, uri)
+		},
+	}
+	return server.Serve(stdin, stdout)
+}
+
+// mcpResolve resolves the rules for the tool arguments and writes them with write
+func mcpResolve(state *projectState, arguments json.RawMessage, write func(io.Writer, *resolver.Resolver, *resolver.Resolution) error) (string, error) {
+	var args pathArguments
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return "", fmt.Errorf("invalid arguments: %w", err)
+	}
+	if args.Path == "" {
+		return "", fmt.Errorf("'path' is required")
+	}
+
+	cfg, allAgentRules, err := state.current()
+	if err != nil {
+		return "", err
+	}
+	agentName, err := config.FindAgentByCommandGroup(cfg, args.Group)
+	if err != nil {
+		return "", err
+	}
+
+	r := newResolver(cfg, allAgentRules)
+	res, err := r.Explain(agentName, filepath.ToSlash(args.Path))
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	if err := write(&sb, r, res); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+func mcpListRules(state *projectState, arguments json.RawMessage) (string, error) {
+	var args struct {
+		Agent string `json:"agent"`
+	}
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return "", fmt.Errorf("invalid arguments: %w", err)
+	}
+
+	cfg, allAgentRules, err := state.current()
+	if err != nil {
+		return "", err
+	}
+	agentName := args.Agent
+	if agentName == "" {
+		if agentName, err = config.FindAgentByCommandGroup(cfg, nil); err != nil {
+			return "", err
+		}
+	} else if _, ok := cfg.Agents[agentName]; !ok {
+		return "", fmt.Errorf("Agent '%s' not found in configuration.", agentName)
+	}
+
+	var sb strings.Builder
+	rules := allAgentRules[agentName]
+	fmt.Fprintf(&sb, "Rules of agent '%s' (%d):\n", agentName, len(rules))
+	for _, rule := range rules {
+		fmt.Fprintf(&sb, "\n- %s\n", rule.Path)
+		if rule.Scope == models.ScopeDirectory {
+			fmt.Fprintf(&sb, "  scope: directory\n")
+		} else {
+			fmt.Fprintf(&sb, "  patterns: %s\n", strings.Join(rule.GetPatterns(), ", "))
+		}
+		if len(rule.IgnorePatterns) > 0 {
+			fmt.Fprintf(&sb, "  ignorePatterns: %s\n", strings.Join(rule.IgnorePatterns, ", "))
+		}
+		if len(rule.Tags) > 0 {
+			fmt.Fprintf(&sb, "  tags: %s\n", strings.Join(rule.Tags, ", "))
+		}
+		if rule.Priority != nil {
+			fmt.Fprintf(&sb, "  priority: %d\n", *rule.Priority)
+		}
+		if rule.Order != nil {
+			fmt.Fprintf(&sb, "  order: %d\n", *rule.Order)
+		}
+	}
+	return sb.String(), nil
+}
+
+// mcpListResources lists every rule file in the cache as a file:// resource
+func mcpListResources(state *projectState) ([]mcp.Resource, error) {
+	_, allAgentRules, err := state.current()
+	if err != nil {
+		return nil, err
+	}
+
+	agentNames := make([]string, 0, len(allAgentRules))
+	for agentName := range allAgentRules {
+		agentNames = append(agentNames, agentName)
+	}
+	sort.Strings(agentNames)
+
+	resources := []mcp.Resource{}
+	seen := make(map[string]bool)
+	for _, agentName := range agentNames {
+		for _, rule := range allAgentRules[agentName] {
+			if seen[rule.Path] {
+				continue
+			}
+			seen[rule.Path] = true
+
+			absPath, err := filepath.Abs(rule.Path)
+			if err != nil {
+				return nil, err
+			}
+			resources = append(resources, mcp.Resource{
+				URI:         (&url.URL{Scheme: "file", Path: filepath.ToSlash(absPath)}).String(),
+				Name:        rule.Path,
+				Description: fmt.Sprintf("Rule file of agent '%s'", agentName),
+				MimeType:    "text/markdown",
+			})
+		}
+	}
+	return resources, nil
+}
+
+// mcpReadResource reads a rule file listed by mcpListResources
+func mcpReadResource(state *projectState, uri string) (string, string, error) {
+	resources, err := mcpListResources(state)
+	if err != nil {
+		return "", "", err
+	}
+	for _, resource := range resources {
+		if resource.URI != uri {
+			continue
+		}
+		content, err := readFile(resource.Name)
+		if err != nil {
+			return "", "", err
+		}
+		if content == nil {
+			return "", "", fmt.Errorf("resource not found: %s", uri)
+		}
+		return string(content), resource.MimeType, nil
+	}
+	return "", "", fmt.Errorf("unknown resource: %s", uri)
+}
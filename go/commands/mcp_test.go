@@ -0,0 +1,76 @@
+package commands
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// mcpClient talks to the MCP server of the project in projectFS over pipes
+type mcpClient struct {
+	t      *testing.T
+	stdin  *io.PipeWriter
+	stdout *bufio.Scanner
+	id     int
+}
+
+func startMCP(t *testing.T) *mcpClient {
+	t.Helper()
+	stdinReader, stdin := io.Pipe()
+	stdoutReader, stdout := io.Pipe()
+	done := make(chan error)
+	go func() { done <- MCP("test", stdinReader, stdout) }()
+	t.Cleanup(func() {
+		stdin.Close()
+		if err := <-done; err != nil {
+			t.Errorf("MCP() error = %v", err)
+		}
+	})
+	return &mcpClient{t: t, stdin: stdin, stdout: bufio.NewScanner(stdoutReader)}
+}
+
+// callTool calls a tool and returns its text result
+func (c *mcpClient) callTool(name string, arguments map[string]interface{}) string {
+	c.t.Helper()
+	c.id++
+	params, _ := json.Marshal(map[string]interface{}{"name": name, "arguments": arguments})
+	fmt.Fprintf(c.stdin, `{"jsonrpc": "2.0", "id": %d, "method": "tools/call", "params": %s}`+"\n", c.id, params)
+	if !c.stdout.Scan() {
+		c.t.Fatal("no response")
+	}
+	var resp struct {
+		Result struct {
+			Content []struct {
+				Text string `json:"text"`
+			} `json:"content"`
+			IsError bool `json:"isError"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(c.stdout.Bytes(), &resp); err != nil || len(resp.Result.Content) != 1 || resp.Result.IsError {
+		c.t.Fatalf("tools/call %s = %s", name, c.stdout.Bytes())
+	}
+	return resp.Result.Content[0].Text
+}
+
+func TestMCPReloadsChanges(t *testing.T) {
+	project := newMemProject(t, map[string]string{
+		"a.code-editor-agent.md": "---\npatterns: \"**\"\n---\n# A\n",
+	})
+	client := startMCP(t)
+
+	if output := client.callTool("load_rules", map[string]interface{}{"path": "src/main.go"}); !strings.Contains(output, "# A") {
+		t.Errorf("load_rules = %q, want rule A", output)
+	}
+
+	project.MapFS["b.code-editor-agent.md"] = &fstest.MapFile{Data: []byte("---\npatterns: \"**\"\n---\n# B\n")}
+	if _, err := captureStdout(t, func() error { return Generate(false) }); err != nil {
+		t.Fatal(err)
+	}
+	if output := client.callTool("list_rules", map[string]interface{}{}); !strings.Contains(output, "b.code-editor-agent.md") {
+		t.Errorf("list_rules = %q, want the rule added since the last call", output)
+	}
+}
@@ -0,0 +1,159 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/dirt-rain/code-editor-agent/config"
+	"github.com/dirt-rain/code-editor-agent/matcher"
+	"github.com/dirt-rain/code-editor-agent/models"
+	"github.com/dirt-rain/code-editor-agent/resolver"
+)
+
+// NewRuleOptions holds the options of `cmd new-rule`
+type NewRuleOptions struct {
+	Agent    string // defaults to the agent with commandGroup: null
+	Patterns []string
+	Tags     []string
+	Priority *int
+}
+
+// NewRule writes a rule file named after name for the agent, reports how many
+// files its patterns match and regenerates the cache
+func NewRule(name string, opts NewRuleOptions) error {
+	if !fileExists(models.RuleCacheFilePath) {
+		return fmt.Errorf("Very likely current working directory is not the root of the project, or `code-editor-agent cmd init` not yet runned.")
+	}
+
+	cfg, err := config.Load(projectFS)
+	if err != nil {
+		return err
+	}
+
+	agentName := opts.Agent
+	if agentName == "" {
+		if agentName, err = config.FindAgentByCommandGroup(cfg, nil); err != nil {
+			return err
+		}
+	}
+	agentConfig, ok := cfg.Agents[agentName]
+	if !ok {
+		return fmt.Errorf("Agent '%s' not found in configuration.", agentName)
+	}
+	suffix, err := ruleFileSuffix(agentConfig.RuleFilePattern)
+	if err != nil {
+		return err
+	}
+
+	rulePath, err := newRulePath(name, suffix)
+	if err != nil {
+		return err
+	}
+	if matched, _ := doublestar.Match(agentConfig.RuleFilePattern, rulePath); !matched {
+		return fmt.Errorf("%s would not be matched by the ruleFilePattern '%s' of agent '%s'. Choose a name matching it.", rulePath, agentConfig.RuleFilePattern, agentName)
+	}
+	if resolver.IsExcluded(rulePath, cfg.Exclude) {
+		return fmt.Errorf("%s is excluded by the 'exclude' configuration. Choose another directory.", rulePath)
+	}
+	if fileExists(rulePath) {
+		return fmt.Errorf("File %s already exists.", rulePath)
+	}
+
+	if len(opts.Patterns) == 0 && len(opts.Tags) == 0 && agentConfig.DirectoryRules == "" {
+		return fmt.Errorf("A rule without --patterns is only loaded through its tags. Pass --patterns or --tag.")
+	}
+	if err := matcher.ValidatePatterns(opts.Patterns); err != nil {
+		return fmt.Errorf("Invalid --patterns: %v.", err)
+	}
+	if len(opts.Patterns) > 0 {
+		if err := reportPatternMatches(opts.Patterns, cfg.Exclude); err != nil {
+			return err
+		}
+	}
+
+	directoryScoped := len(opts.Patterns) == 0 && agentConfig.DirectoryRules != ""
+	if err := writeFile(rulePath, []byte(formatNewRule(rulePath, suffix, opts, directoryScoped))); err != nil {
+		return fmt.Errorf("failed to write %s: %w", rulePath, err)
+	}
+	if directoryScoped {
+		fmt.Printf("Wrote %s, applying to the files under its directory\n\n", rulePath)
+	} else {
+		fmt.Printf("Wrote %s\n\n", rulePath)
+	}
+
+	return Generate(false)
+}
+
+// newRulePath returns the path of the rule file for name, which may include
+// directories and the rule file suffix
+func newRulePath(name, suffix string) (string, error) {
+	rulePath := path.Clean(filepath.ToSlash(strings.TrimSuffix(name, suffix)))
+	if rulePath == "." || strings.HasSuffix(name, "/") || path.IsAbs(rulePath) || strings.HasPrefix(rulePath, "../") || rulePath == ".." {
+		return "", fmt.Errorf("Invalid rule name '%s'. Expected a name like 'db' or 'src/db/queries'.", name)
+	}
+	return rulePath + suffix, nil
+}
+
+// reportPatternMatches prints how many files of the repository each pattern
+// and the whole pattern list match
+func reportPatternMatches(patterns, exclude []string) error {
+	files, err := findFiles("**", exclude, doublestar.WithFilesOnly())
+	if err != nil {
+		return fmt.Errorf("failed to list files: %w", err)
+	}
+
+	for _, raw := range patterns {
+		pattern := matcher.ParsePattern(raw)
+		count := 0
+		for _, file := range files {
+			if matched, _ := doublestar.Match(pattern.Glob, file); matched {
+				count++
+			}
+		}
+		fmt.Printf("  %s: %d files\n", raw, count)
+		if count == 0 && !pattern.Negate {
+			fmt.Fprintf(os.Stderr, "Warning: Pattern '%s' matches no files.\n", raw)
+		}
+	}
+
+	total := 0
+	for _, file := range files {
+		if matcher.MatchPatterns(patterns, file) {
+			total++
+		}
+	}
+	fmt.Printf("The rule applies to %d files\n", total)
+	return nil
+}
+
+// formatNewRule formats the front matter and a heading for a new rule.
+// Directory-scoped rules apply to their directory instead of patterns.
+func formatNewRule(rulePath, suffix string, opts NewRuleOptions, directoryScoped bool) string {
+	var sb strings.Builder
+	sb.WriteString("---\n")
+	switch len(opts.Patterns) {
+	case 0:
+		if directoryScoped {
+			fmt.Fprintf(&sb, "scope: %s\n", models.ScopeDirectory)
+		} else {
+			sb.WriteString("patterns: []\n")
+		}
+	case 1:
+		fmt.Fprintf(&sb, "patterns: %s\n", jsonString(opts.Patterns[0]))
+	default:
+		fmt.Fprintf(&sb, "patterns: %s\n", jsonList(opts.Patterns))
+	}
+	if opts.Priority != nil {
+		fmt.Fprintf(&sb, "priority: %d\n", *opts.Priority)
+	}
+	if len(opts.Tags) > 0 {
+		fmt.Fprintf(&sb, "tags: %s\n", jsonList(opts.Tags))
+	}
+	sb.WriteString("---\n\n")
+	fmt.Fprintf(&sb, "# %s\n", strings.TrimSuffix(path.Base(rulePath), suffix))
+	return sb.String()
+}
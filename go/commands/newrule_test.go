@@ -0,0 +1,136 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dirt-rain/code-editor-agent/models"
+)
+
+const newRuleTestConfig = `{
+  "exclude": ["./node_modules/**"],
+  "agents": {
+    "code-editor": {"ruleFilePattern": "` + defaultRuleFilePattern + `", "commandGroup": null},
+    "code-reviewer": {"ruleFilePattern": "**/*.code-reviewer.md", "commandGroup": "reviewer", "directoryRules": "nearest"},
+  },
+}`
+
+func TestNewRule(t *testing.T) {
+	priority := 3
+	tests := []struct {
+		name     string
+		ruleName string
+		opts     NewRuleOptions
+		path     string
+		content  string
+		output   []string
+	}{
+		{
+			name:     "single pattern",
+			ruleName: "db",
+			opts:     NewRuleOptions{Patterns: []string{"src/db/**"}},
+			path:     "db.code-editor-agent.md",
+			content:  "---\npatterns: \"src/db/**\"\n---\n\n# db\n",
+			output:   []string{"  src/db/**: 2 files\n", "The rule applies to 2 files\n", "Wrote db.code-editor-agent.md\n"},
+		},
+		{
+			name:     "directory and suffix in the name",
+			ruleName: "src/db/queries.code-editor-agent.md",
+			opts:     NewRuleOptions{Patterns: []string{"src/db/*.sql", "!src/db/seed.sql"}, Priority: &priority, Tags: []string{"db", "sql"}},
+			path:     "src/db/queries.code-editor-agent.md",
+			content:  "---\npatterns: [\"src/db/*.sql\", \"!src/db/seed.sql\"]\npriority: 3\ntags: [\"db\", \"sql\"]\n---\n\n# queries\n",
+			output:   []string{"  src/db/*.sql: 2 files\n", "  !src/db/seed.sql: 1 files\n", "The rule applies to 1 files\n"},
+		},
+		{
+			name:     "tags only",
+			ruleName: "style",
+			opts:     NewRuleOptions{Tags: []string{"style"}},
+			path:     "style.code-editor-agent.md",
+			content:  "---\npatterns: []\ntags: [\"style\"]\n---\n\n# style\n",
+		},
+		{
+			name:     "directory rule",
+			ruleName: "src/db/review",
+			opts:     NewRuleOptions{Agent: "code-reviewer"},
+			path:     "src/db/review.code-reviewer.md",
+			content:  "---\nscope: directory\n---\n\n# review\n",
+			output:   []string{"Wrote src/db/review.code-reviewer.md, applying to the files under its directory\n"},
+		},
+		{
+			name:     "patterns of a directory rules agent",
+			ruleName: "queries",
+			opts:     NewRuleOptions{Agent: "code-reviewer", Patterns: []string{"**/*.sql"}},
+			path:     "queries.code-reviewer.md",
+			content:  "---\npatterns: \"**/*.sql\"\n---\n\n# queries\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			project := newMemProject(t, map[string]string{
+				models.ConfigFilePath: newRuleTestConfig,
+				"src/db/schema.sql":   "",
+				"src/db/seed.sql":     "",
+			})
+
+			output, err := captureStdout(t, func() error { return NewRule(test.ruleName, test.opts) })
+			if err != nil {
+				t.Fatal(err)
+			}
+			file, ok := project.MapFS[test.path]
+			if !ok {
+				t.Fatalf("%s was not written, output %q", test.path, output)
+			}
+			if string(file.Data) != test.content {
+				t.Errorf("%s = %q, want %q", test.path, file.Data, test.content)
+			}
+			for _, want := range test.output {
+				if !strings.Contains(output, want) {
+					t.Errorf("output = %q, want %q", output, want)
+				}
+			}
+
+			// The cache is regenerated with the new rule
+			cache := string(project.MapFS[models.RuleCacheFilePath].Data)
+			if !strings.Contains(cache, test.path) {
+				t.Errorf("cache = %s, want %s", cache, test.path)
+			}
+		})
+	}
+}
+
+func TestNewRuleErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		ruleName string
+		opts     NewRuleOptions
+		want     string
+	}{
+		{name: "existing file", ruleName: "db", opts: NewRuleOptions{Patterns: []string{"src/**"}}, want: "File db.code-editor-agent.md already exists."},
+		{name: "parent directory", ruleName: "../db", opts: NewRuleOptions{Patterns: []string{"src/**"}}, want: "Invalid rule name '../db'."},
+		{name: "absolute path", ruleName: "/db", opts: NewRuleOptions{Patterns: []string{"src/**"}}, want: "Invalid rule name '/db'."},
+		{name: "directory only", ruleName: "src/", opts: NewRuleOptions{Patterns: []string{"src/**"}}, want: "Invalid rule name 'src/'."},
+		{name: "excluded directory", ruleName: "node_modules/db", opts: NewRuleOptions{Patterns: []string{"src/**"}}, want: "node_modules/db.code-editor-agent.md is excluded"},
+		{name: "no patterns or tags", ruleName: "api", want: "A rule without --patterns is only loaded through its tags."},
+		{name: "invalid pattern", ruleName: "api", opts: NewRuleOptions{Patterns: []string{"src/[a"}}, want: "Invalid --patterns:"},
+		{name: "unknown agent", ruleName: "api", opts: NewRuleOptions{Agent: "writer", Tags: []string{"x"}}, want: "Agent 'writer' not found in configuration."},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			project := newMemProject(t, map[string]string{
+				models.ConfigFilePath:     newRuleTestConfig,
+				"db.code-editor-agent.md": "---\npatterns: \"src/db/**\"\n---\n\n# Mine\n",
+			})
+			before := len(project.MapFS)
+
+			_, err := captureStdout(t, func() error { return NewRule(test.ruleName, test.opts) })
+			if err == nil || !strings.HasPrefix(err.Error(), test.want) {
+				t.Fatalf("NewRule(%q) = %v, want %q", test.ruleName, err, test.want)
+			}
+			if len(project.MapFS) != before || string(project.MapFS["db.code-editor-agent.md"].Data) != "---\npatterns: \"src/db/**\"\n---\n\n# Mine\n" {
+				t.Error("NewRule() changed the project despite the error")
+			}
+		})
+	}
+}
@@ -0,0 +1,397 @@
+package commands
+
+import (
+	"bufio"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/dirt-rain/code-editor-agent/models"
+)
+
+// presetFiles holds the starter rule files of every preset, as presets/<preset>/<file>
+//
+//go:embed presets
+var presetFiles embed.FS
+
+// preset is a starter rule pack for a kind of project
+type preset struct {
+	Name        string
+	Description string
+	// Detect reports whether the project in the current directory looks like this kind
+	Detect  func() bool
+	Exclude []string
+	// DirectoryRules enables directory rules on the editor agent
+	DirectoryRules string
+}
+
+var presets = []preset{
+	{
+		Name:        "go",
+		Description: "Go modules",
+		Detect:      func() bool { return anyFileExists("go.mod", "go.work") },
+		Exclude:     []string{"./vendor/**"},
+	},
+	{
+		Name:        "typescript",
+		Description: "TypeScript and Node.js packages",
+		Detect:      func() bool { return anyFileExists("tsconfig.json", "package.json") },
+		Exclude:     []string{"./dist/**"},
+	},
+	{
+		Name:        "python",
+		Description: "Python packages",
+		Detect:      func() bool { return anyFileExists("pyproject.toml", "requirements.txt", "setup.py") },
+		Exclude:     []string{"./.venv/**", "./venv/**"},
+	},
+	{
+		Name:        "monorepo",
+		Description: "Workspaces with several packages, with per-directory rules",
+		Detect: func() bool {
+			return anyFileExists("pnpm-workspace.yaml", "go.work", "lerna.json", "nx.json", "turbo.json") || hasNPMWorkspaces()
+		},
+		DirectoryRules: models.DirectoryRulesNearest,
+	},
+}
+
+// starterAgent is an agent init can create
+type starterAgent struct {
+	Key          string
+	Name         string
+	CommandGroup string // empty for commandGroup: null
+	RuleSuffix   string
+	Comment      string
+	ClaudeAgent  *models.ClaudeAgentConfig
+}
+
+var starterAgents = []starterAgent{
+	{
+		Key:        "editor",
+		Name:       "code-editor",
+		RuleSuffix: ".code-editor-agent.md",
+		Comment:    "Default agent: code-editor",
+	},
+	{
+		Key:          "reviewer",
+		Name:         "code-reviewer",
+		CommandGroup: "reviewer",
+		RuleSuffix:   ".code-reviewer.md",
+		Comment:      "Reviews code, usage: code-editor-agent reviewer <path>",
+		ClaudeAgent: &models.ClaudeAgentConfig{
+			Description:  "Review code for quality and security",
+			Tools:        []string{"Bash", "Read", "Grep", "Glob"},
+			Color:        "red",
+			Instructions: "Don't edit files. Report findings with file paths and line numbers.",
+		},
+	},
+	{
+		Key:          "test-writer",
+		Name:         "test-writer",
+		CommandGroup: "test",
+		RuleSuffix:   ".test-writer.md",
+		Comment:      "Writes tests, usage: code-editor-agent test <path>",
+		ClaudeAgent: &models.ClaudeAgentConfig{
+			Description: "Write and update tests",
+			Color:       "green",
+		},
+	},
+}
+
+// defaultStarterAgents are the agents created without --agents
+var defaultStarterAgents = []string{"editor"}
+
+// PresetNames lists the available presets
+func PresetNames() []string {
+	names := make([]string, len(presets))
+	for i, p := range presets {
+		names[i] = p.Name
+	}
+	return names
+}
+
+// StarterAgentKeys lists the agents init can create
+func StarterAgentKeys() []string {
+	keys := make([]string, len(starterAgents))
+	for i, agent := range starterAgents {
+		keys[i] = agent.Key
+	}
+	return keys
+}
+
+func findPreset(name string) (*preset, bool) {
+	for i := range presets {
+		if presets[i].Name == name {
+			return &presets[i], true
+		}
+	}
+	return nil, false
+}
+
+func findStarterAgent(key string) (*starterAgent, bool) {
+	for i := range starterAgents {
+		if starterAgents[i].Key == key {
+			return &starterAgents[i], true
+		}
+	}
+	return nil, false
+}
+
+// validateStarterOptions checks the presets and agents of opts, adding the
+// editor agent, which every project needs
+func validateStarterOptions(opts *InitOptions) error {
+	for _, name := range opts.Presets {
+		if _, ok := findPreset(name); !ok {
+			return fmt.Errorf("Unknown preset '%s'. Expected one of: %s.", name, strings.Join(PresetNames(), ", "))
+		}
+	}
+	if len(opts.Agents) == 0 {
+		opts.Agents = defaultStarterAgents
+	}
+	for _, key := range opts.Agents {
+		if _, ok := findStarterAgent(key); !ok {
+			return fmt.Errorf("Unknown agent '%s'. Expected one of: %s.", key, strings.Join(StarterAgentKeys(), ", "))
+		}
+	}
+	if !containsString(opts.Agents, "editor") {
+		opts.Agents = append([]string{"editor"}, opts.Agents...)
+	}
+	return nil
+}
+
+// isDefaultStarter reports whether opts ask for the plain default setup
+func isDefaultStarter(opts InitOptions) bool {
+	return len(opts.Presets) == 0 && len(opts.Agents) == 1 && opts.Agents[0] == "editor"
+}
+
+// starterConfigContent builds the config file for the selected presets and agents
+func starterConfigContent(opts InitOptions) string {
+	if isDefaultStarter(opts) {
+		return configFileContent
+	}
+
+	exclude := []string{"./node_modules/**"}
+	directoryRules := ""
+	for _, name := range opts.Presets {
+		p, _ := findPreset(name)
+		for _, pattern := range p.Exclude {
+			if !containsString(exclude, pattern) {
+				exclude = append(exclude, pattern)
+			}
+		}
+		if p.DirectoryRules != "" {
+			directoryRules = p.DirectoryRules
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString("{\n")
+	fmt.Fprintf(&sb, "  \"exclude\": %s,\n", jsonList(exclude))
+	sb.WriteString("  \"agents\": {\n")
+	for _, agent := range starterAgents {
+		if !containsString(opts.Agents, agent.Key) {
+			continue
+		}
+		fmt.Fprintf(&sb, "    // %s\n", agent.Comment)
+		fmt.Fprintf(&sb, "    %s: {\n", jsonString(agent.Name))
+		ruleFilePattern := "**/*" + agent.RuleSuffix
+		if agent.Key == "editor" {
+			// Directory rules are named code-editor-agent.md
+			ruleFilePattern = "**/{*" + agent.RuleSuffix + "," + strings.TrimPrefix(agent.RuleSuffix, ".") + "}"
+		}
+		fmt.Fprintf(&sb, "      \"ruleFilePattern\": %s,\n", jsonString(ruleFilePattern))
+		if agent.CommandGroup == "" {
+			sb.WriteString("      \"commandGroup\": null,\n")
+		} else {
+			fmt.Fprintf(&sb, "      \"commandGroup\": %s,\n", jsonString(agent.CommandGroup))
+			sb.WriteString("      \"references\": [\"code-editor\"],\n")
+		}
+		if agent.Key == "editor" && directoryRules != "" {
+			fmt.Fprintf(&sb, "      \"directoryRules\": %s,\n", jsonString(directoryRules))
+		}
+		if claudeAgent := agent.ClaudeAgent; claudeAgent != nil {
+			// Generates .claude/agents/<name>.md, see `cmd sync-agents`
+			sb.WriteString("      \"claudeAgent\": {\n")
+			fmt.Fprintf(&sb, "        \"description\": %s,\n", jsonString(claudeAgent.Description))
+			if len(claudeAgent.Tools) > 0 {
+				fmt.Fprintf(&sb, "        \"tools\": %s,\n", jsonList(claudeAgent.Tools))
+			}
+			fmt.Fprintf(&sb, "        \"color\": %s,\n", jsonString(claudeAgent.Color))
+			if claudeAgent.Instructions != "" {
+				fmt.Fprintf(&sb, "        \"instructions\": %s,\n", jsonString(claudeAgent.Instructions))
+			}
+			sb.WriteString("      },\n")
+		}
+		sb.WriteString("    },\n")
+	}
+	sb.WriteString("  },\n")
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+// starterRuleFiles returns the rule files of the selected presets for the
+// selected agents, by path
+func starterRuleFiles(opts InitOptions) (map[string]string, error) {
+	files := make(map[string]string)
+	for _, name := range opts.Presets {
+		entries, err := fs.ReadDir(presetFiles, "presets/"+name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read preset %s: %w", name, err)
+		}
+		for _, entry := range entries {
+			for _, key := range opts.Agents {
+				agent, _ := findStarterAgent(key)
+				if !strings.HasSuffix(entry.Name(), agent.RuleSuffix) {
+					continue
+				}
+				content, err := presetFiles.ReadFile(path.Join("presets", name, entry.Name()))
+				if err != nil {
+					return nil, fmt.Errorf("failed to read preset %s: %w", name, err)
+				}
+				files[entry.Name()] = string(content)
+			}
+		}
+	}
+	return files, nil
+}
+
+// DetectPresets returns the presets that match the project in the current directory
+func DetectPresets() []string {
+	detected := []string{}
+	for _, p := range presets {
+		if p.Detect() {
+			detected = append(detected, p.Name)
+		}
+	}
+	return detected
+}
+
+// PromptInit asks for the presets, agents and runtime of init on a terminal,
+// proposing the presets detected in the project
+func PromptInit(in io.Reader, out io.Writer, opts *InitOptions) error {
+	reader := bufio.NewReader(in)
+	ask := func(question string, defaultValue string) (string, error) {
+		fmt.Fprintf(out, "%s [%s]: ", question, defaultValue)
+		line, err := reader.ReadString('\n')
+		if err != nil && !(err == io.EOF && line != "") {
+			return "", fmt.Errorf("Init was cancelled.")
+		}
+		if line = strings.TrimSpace(line); line != "" {
+			return line, nil
+		}
+		return defaultValue, nil
+	}
+
+	fmt.Fprintln(out, "Presets:")
+	for _, p := range presets {
+		fmt.Fprintf(out, "  %-12s %s\n", p.Name, p.Description)
+	}
+	presetsAnswer, err := ask("Presets to install (comma-separated, or none)", orNone(strings.Join(DetectPresets(), ",")))
+	if err != nil {
+		return err
+	}
+	opts.Presets = splitAnswer(presetsAnswer)
+
+	agentsAnswer, err := ask("Agents to create ("+strings.Join(StarterAgentKeys(), ", ")+")", strings.Join(StarterAgentKeys(), ","))
+	if err != nil {
+		return err
+	}
+	opts.Agents = splitAnswer(agentsAnswer)
+
+	runtimeAnswer, err := ask("Add the rules to CLAUDE.md and allow running the CLI installed with (go, node, or none)", "none")
+	if err != nil {
+		return err
+	}
+	if answers := splitAnswer(runtimeAnswer); len(answers) > 0 {
+		opts.Runtime = answers[0]
+	}
+
+	if err := validateStarterOptions(opts); err != nil {
+		return err
+	}
+
+	files, err := starterRuleFiles(*opts)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "\nAgents: %s\nStarter rule files: %d\n", strings.Join(opts.Agents, ", "), len(files))
+	confirm, err := ask("Continue? (y/n)", "y")
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(strings.ToLower(confirm), "y") {
+		return fmt.Errorf("Init was cancelled.")
+	}
+	return nil
+}
+
+// IsTerminal reports whether f is an interactive terminal
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// splitAnswer splits a comma-separated answer, where "none" means nothing
+func splitAnswer(answer string) []string {
+	values := []string{}
+	for _, value := range strings.Split(answer, ",") {
+		if value = strings.TrimSpace(value); value != "" && value != "none" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func orNone(value string) string {
+	if value == "" {
+		return "none"
+	}
+	return value
+}
+
+// hasNPMWorkspaces reports whether package.json declares npm or yarn workspaces
+func hasNPMWorkspaces() bool {
+	raw, err := readFile("package.json")
+	if err != nil || raw == nil {
+		return false
+	}
+	var pkg map[string]interface{}
+	if err := json.Unmarshal(raw, &pkg); err != nil {
+		return false
+	}
+	_, ok := pkg["workspaces"]
+	return ok
+}
+
+func anyFileExists(paths ...string) bool {
+	for _, p := range paths {
+		if fileExists(p) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedKeys returns the keys of m in order
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,249 @@
+package commands
+
+import (
+	"bytes"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/dirt-rain/code-editor-agent/models"
+)
+
+// ruleFilesIn returns the rule files in the root of the project, besides
+// the one written by init
+func ruleFilesIn(project memFS) []string {
+	files := []string{}
+	for name := range project.MapFS {
+		if !strings.Contains(name, "/") && strings.HasSuffix(name, ".md") && name != metaRuleFilePath {
+			files = append(files, name)
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+func TestInitPresets(t *testing.T) {
+	tests := []struct {
+		name    string
+		presets []string
+		agents  []string
+		files   []string
+		// config are parts of the written config file
+		config       []string
+		claudeAgents []string
+	}{
+		{
+			name:         "default",
+			files:        []string{},
+			config:       []string{`"ruleFilePattern": "` + defaultRuleFilePattern + `"`},
+			claudeAgents: []string{"code-editor"},
+		},
+		{
+			name:         "go",
+			presets:      []string{"go"},
+			files:        []string{"go-mod.code-editor-agent.md", "go.code-editor-agent.md"},
+			config:       []string{`"exclude": ["./node_modules/**", "./vendor/**"]`, `"ruleFilePattern": "` + defaultRuleFilePattern + `"`},
+			claudeAgents: []string{"code-editor"},
+		},
+		{
+			name:         "typescript with the reviewer",
+			presets:      []string{"typescript"},
+			agents:       []string{"reviewer"},
+			files:        []string{"package-json.code-editor-agent.md", "typescript.code-editor-agent.md", "typescript.code-reviewer.md"},
+			config:       []string{`"./dist/**"`, `"code-reviewer": {`, `"commandGroup": "reviewer"`, `"description": "Review code for quality and security"`},
+			claudeAgents: []string{"code-editor", "code-reviewer"},
+		},
+		{
+			name:         "python with the test writer",
+			presets:      []string{"python"},
+			agents:       []string{"test-writer"},
+			files:        []string{"python-deps.code-editor-agent.md", "python.code-editor-agent.md", "python.test-writer.md"},
+			config:       []string{`"./.venv/**", "./venv/**"`, `"test-writer": {`, `"commandGroup": "test"`},
+			claudeAgents: []string{"code-editor", "test-writer"},
+		},
+		{
+			name:         "monorepo",
+			presets:      []string{"monorepo"},
+			files:        []string{"monorepo.code-editor-agent.md"},
+			config:       []string{`"directoryRules": "nearest"`},
+			claudeAgents: []string{"code-editor"},
+		},
+		{
+			name:    "several presets and every agent",
+			presets: []string{"go", "monorepo"},
+			agents:  []string{"editor", "reviewer", "test-writer"},
+			files: []string{
+				"go-mod.code-editor-agent.md", "go.code-editor-agent.md", "go.code-reviewer.md", "go.test-writer.md",
+				"monorepo.code-editor-agent.md", "monorepo.code-reviewer.md",
+			},
+			config:       []string{`"./vendor/**"`, `"directoryRules": "nearest"`, `"code-reviewer": {`, `"test-writer": {`},
+			claudeAgents: []string{"code-editor", "code-reviewer", "test-writer"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			project := memFS{fstest.MapFS{}}
+			useProject(t, project)
+			opts := InitOptions{Presets: test.presets, Agents: test.agents}
+			if _, err := captureStdout(t, func() error { return Init(opts) }); err != nil {
+				t.Fatal(err)
+			}
+
+			if files := ruleFilesIn(project); !reflect.DeepEqual(files, test.files) {
+				t.Errorf("rule files = %v, want %v", files, test.files)
+			}
+			config := string(project.MapFS[models.ConfigFilePath].Data)
+			for _, want := range test.config {
+				if !strings.Contains(config, want) {
+					t.Errorf("config = %s, want %s", config, want)
+				}
+			}
+			claudeAgents := []string{}
+			for name := range project.MapFS {
+				if strings.HasPrefix(name, ClaudeAgentsDir+"/") && strings.HasSuffix(name, ".md") {
+					claudeAgents = append(claudeAgents, strings.TrimSuffix(strings.TrimPrefix(name, ClaudeAgentsDir+"/"), ".md"))
+				}
+			}
+			sort.Strings(claudeAgents)
+			if !reflect.DeepEqual(claudeAgents, test.claudeAgents) {
+				t.Errorf("Claude agents = %v, want %v", claudeAgents, test.claudeAgents)
+			}
+
+			// The presets and agents are recorded for upgrade
+			base, err := readTemplateBase()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(base.Presets, test.presets) {
+				t.Errorf("recorded presets = %v, want %v", base.Presets, test.presets)
+			}
+		})
+	}
+}
+
+func TestInitPresetErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		opts  InitOptions
+		want  string
+	}{
+		{name: "unknown preset", opts: InitOptions{Presets: []string{"rust"}}, want: "Unknown preset 'rust'."},
+		{name: "unknown agent", opts: InitOptions{Agents: []string{"writer"}}, want: "Unknown agent 'writer'."},
+		{
+			name:  "existing starter rule",
+			files: map[string]string{"go.code-editor-agent.md": "# Mine\n"},
+			opts:  InitOptions{Presets: []string{"go"}},
+			want:  "File go.code-editor-agent.md already exists.",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			project := memFS{fstest.MapFS{}}
+			for name, content := range test.files {
+				project.MapFS[name] = &fstest.MapFile{Data: []byte(content)}
+			}
+			useProject(t, project)
+			_, err := captureStdout(t, func() error { return Init(test.opts) })
+			if err == nil || !strings.HasPrefix(err.Error(), test.want) {
+				t.Fatalf("Init() = %v, want %q", err, test.want)
+			}
+			if _, ok := project.MapFS[models.ConfigFilePath]; ok {
+				t.Error("Init() wrote the config despite the error")
+			}
+		})
+	}
+}
+
+func TestDetectPresets(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  []string
+	}{
+		{name: "empty", want: []string{}},
+		{name: "go", files: map[string]string{"go.mod": "module x\n"}, want: []string{"go"}},
+		{name: "go workspace", files: map[string]string{"go.work": "go 1.22\n"}, want: []string{"go", "monorepo"}},
+		{name: "typescript", files: map[string]string{"package.json": `{"name": "x"}`}, want: []string{"typescript"}},
+		{name: "npm workspaces", files: map[string]string{"package.json": `{"workspaces": ["packages/*"]}`}, want: []string{"typescript", "monorepo"}},
+		{name: "python", files: map[string]string{"requirements.txt": ""}, want: []string{"python"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			project := memFS{fstest.MapFS{}}
+			for name, content := range test.files {
+				project.MapFS[name] = &fstest.MapFile{Data: []byte(content)}
+			}
+			useProject(t, project)
+			if got := DetectPresets(); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("DetectPresets() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestPromptInit(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		input string
+		want  InitOptions
+		// wantErr is the error, if any
+		wantErr string
+	}{
+		{
+			name:  "defaults",
+			files: map[string]string{"go.mod": "module x\n"},
+			input: "\n\n\n\n",
+			want:  InitOptions{Presets: []string{"go"}, Agents: []string{"editor", "reviewer", "test-writer"}},
+		},
+		{
+			name:  "answers",
+			input: "typescript, monorepo\nreviewer\ngo\ny\n",
+			want:  InitOptions{Presets: []string{"typescript", "monorepo"}, Agents: []string{"editor", "reviewer"}, Runtime: RuntimeGo},
+		},
+		{
+			name:  "no presets",
+			files: map[string]string{"go.mod": "module x\n"},
+			input: "none\neditor\nnone\nyes",
+			want:  InitOptions{Presets: []string{}, Agents: []string{"editor"}},
+		},
+		{name: "unknown preset", input: "rust\n\n\n\n", wantErr: "Unknown preset 'rust'."},
+		{name: "declined", input: "\n\n\nn\n", wantErr: "Init was cancelled."},
+		{name: "end of input", input: "go\n", wantErr: "Init was cancelled."},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			project := memFS{fstest.MapFS{}}
+			for name, content := range test.files {
+				project.MapFS[name] = &fstest.MapFile{Data: []byte(content)}
+			}
+			useProject(t, project)
+
+			var opts InitOptions
+			var out bytes.Buffer
+			err := PromptInit(strings.NewReader(test.input), &out, &opts)
+			if test.wantErr != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), test.wantErr) {
+					t.Fatalf("PromptInit() = %v, want %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(opts, test.want) {
+				t.Errorf("PromptInit() options = %+v, want %+v", opts, test.want)
+			}
+			if !strings.Contains(out.String(), "Presets to install (comma-separated, or none) [") || !strings.Contains(out.String(), "Starter rule files: ") {
+				t.Errorf("PromptInit() printed %q, want the questions and a summary", out.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package commands
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/dirt-rain/code-editor-agent/utils"
+)
+
+// projectFS is the project the commands read and write, the current
+// directory (which main changes to the project root) unless replaced by tests
+var projectFS utils.WritableFS = utils.DirFS(".")
+
+// projectPath converts a path relative to the project root to a name of
+// projectFS. It reports false for paths outside the project, like a config
+// file given with --config, which are used on disk as is.
+func projectPath(filePath string) (string, bool) {
+	name := path.Clean(filepath.ToSlash(filePath))
+	return name, fs.ValidPath(name)
+}
+
+// fileExists checks if a project file exists
+func fileExists(filePath string) bool {
+	name, ok := projectPath(filePath)
+	if !ok {
+		return utils.FileExists(filePath)
+	}
+	_, err := fs.Stat(projectFS, name)
+	return err == nil
+}
+
+// statFile returns the FileInfo of a project file
+func statFile(filePath string) (fs.FileInfo, error) {
+	name, ok := projectPath(filePath)
+	if !ok {
+		return os.Stat(filePath)
+	}
+	return fs.Stat(projectFS, name)
+}
+
+// readFile reads a project file, returning nil if it doesn't exist
+func readFile(filePath string) ([]byte, error) {
+	name, ok := projectPath(filePath)
+	if !ok {
+		return utils.ReadFileNoThrowOnENOENT(filePath)
+	}
+	data, err := fs.ReadFile(projectFS, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	return data, err
+}
+
+// writeFile writes a project file, creating its directory if needed
+func writeFile(filePath string, data []byte) error {
+	name, ok := projectPath(filePath)
+	if !ok {
+		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+			return err
+		}
+		return os.WriteFile(filePath, data, 0644)
+	}
+	if err := projectFS.MkdirAll(path.Dir(name), 0755); err != nil {
+		return err
+	}
+	return projectFS.WriteFile(name, data, 0644)
+}
+
+// removeFile removes a project file
+func removeFile(filePath string) error {
+	name, ok := projectPath(filePath)
+	if !ok {
+		return os.Remove(filePath)
+	}
+	return projectFS.Remove(name)
+}
@@ -0,0 +1,196 @@
+package commands
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/dirt-rain/code-editor-agent/models"
+)
+
+// newMemProject returns an in-memory project with a generated cache, used by
+// the commands until the end of the test
+func newMemProject(t *testing.T, files map[string]string) memFS {
+	t.Helper()
+	project := memFS{fstest.MapFS{}}
+	for name, content := range files {
+		project.MapFS[name] = &fstest.MapFile{Data: []byte(content)}
+	}
+	useProject(t, project)
+	if _, err := captureStdout(t, func() error { return Generate(true) }); err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	return project
+}
+
+func TestExportInMemory(t *testing.T) {
+	project := newMemProject(t, map[string]string{
+		"ts.code-editor-agent.md": "---\npatterns: \"src/**/*.ts\"\n---\n# TypeScript\n",
+		".cursor/rules/old.mdc":   "---\nglobs: x\n---\n\n" + exportMarker + "\n",
+		".cursor/rules/mine.mdc":  "---\nglobs: y\n---\n",
+	})
+
+	if _, err := captureStdout(t, func() error { return Export(ExportOptions{To: ExportCursor}) }); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := project.MapFS[".cursor/rules/ts.mdc"]; !ok {
+		t.Error(".cursor/rules/ts.mdc was not written")
+	}
+	if _, ok := project.MapFS[".cursor/rules/old.mdc"]; ok {
+		t.Error("stale .cursor/rules/old.mdc was not removed")
+	}
+	if _, ok := project.MapFS[".cursor/rules/mine.mdc"]; !ok {
+		t.Error("hand-written .cursor/rules/mine.mdc was removed")
+	}
+}
+
+func TestUsageLogInMemory(t *testing.T) {
+	project := newMemProject(t, map[string]string{
+		models.ConfigFilePath:     `{"usageLog": true, "agents": {"code-editor": {"ruleFilePattern": "**/*.code-editor-agent.md", "commandGroup": null}}}`,
+		"ts.code-editor-agent.md": "---\npatterns: \"src/**/*.ts\"\nwhen:\n  fileExists: tsconfig.json\n---\n# TypeScript\n",
+		"tsconfig.json":           "{}",
+	})
+
+	for _, filePath := range []string{"src/a.ts", "src/b.ts"} {
+		output, err := captureStdout(t, func() error { return Load("code-editor", filePath, FormatText) })
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(output, "# TypeScript") {
+			t.Errorf("load %s = %q, want the rule whose when condition holds in the project", filePath, output)
+		}
+	}
+
+	log := project.MapFS[models.UsageLogFilePath]
+	if log == nil || strings.Count(string(log.Data), "\n") != 2 {
+		t.Fatalf("usage log = %v, want 2 lines", log)
+	}
+	output, err := captureStdout(t, func() error { return Stats(StatsOptions{Format: FormatJSON}) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(output, `"loads": 2`) {
+		t.Errorf("stats = %s, want 2 loads", output)
+	}
+}
+
+func TestCoverageInMemory(t *testing.T) {
+	newMemProject(t, map[string]string{
+		"ts.code-editor-agent.md": "---\npatterns: \"src/**/*.ts\"\n---\n",
+		".gitignore":              "dist/\n",
+		"src/a.ts":                "",
+		"dist/a.ts":               "",
+	})
+	output, err := captureStdout(t, func() error { return Coverage(CoverageOptions{Format: FormatJSON}) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(output, "src/a.ts") || strings.Contains(output, "dist/a.ts") {
+		t.Errorf("coverage = %s, want src/a.ts and not the ignored dist/a.ts", output)
+	}
+}
+
+func TestListInMemory(t *testing.T) {
+	newMemProject(t, map[string]string{
+		models.ConfigFilePath: `{"agents": {
+  "code-editor": {"ruleFilePattern": "**/*.code-editor-agent.md", "commandGroup": null},
+  "code-reviewer": {"ruleFilePattern": "**/*.code-reviewer.md", "commandGroup": "reviewer", "references": ["code-editor"]},
+}}`,
+		"ts.code-editor-agent.md":    "---\npatterns: \"src/**/*.ts\"\npriority: 2\ntags: [ts, style]\n---\n# TypeScript\n",
+		"style.code-editor-agent.md": "+++\npatterns = []\ntags = \"style\"\nreferencesAlways = [\"ts\", \"docs/style.md\"]\n+++\n# Style\n",
+		"all.code-reviewer.md":       "---\npatterns: \"**\"\norder: 1\n---\n# Review\n",
+		"docs/style.md":              "# Style guide\n",
+	})
+
+	// text and table print the same table; fields are compared without the alignment
+	tables := []struct {
+		kind  string
+		agent string
+		rows  [][]string
+	}{
+		{kind: ListAgents, rows: [][]string{
+			{"AGENT", "COMMAND", "GROUP", "RULE", "FILE", "PATTERN", "REFERENCES", "RULES"},
+			{"code-editor", "null", "**/*.code-editor-agent.md", "-", "2"},
+			{"code-reviewer", "reviewer", "**/*.code-reviewer.md", "code-editor", "1"},
+		}},
+		{kind: ListRules, agent: "code-editor", rows: [][]string{
+			{"AGENT", "PATH", "PATTERNS", "PRIORITY", "ORDER", "TAGS", "FRONT", "MATTER", "SIZE"},
+			{"code-editor", "style.code-editor-agent.md", "-", "-", "-", "style", "toml", "8", "B,", "~2", "tokens"},
+			{"code-editor", "ts.code-editor-agent.md", "src/**/*.ts", "2", "-", "ts,", "style", "yaml", "13", "B,", "~4", "tokens"},
+		}},
+		{kind: ListTags, rows: [][]string{
+			{"AGENT", "TAG", "RULES", "REFERENCED", "BY"},
+			{"code-editor", "style", "style.code-editor-agent.md,", "ts.code-editor-agent.md", "-"},
+			{"code-editor", "ts", "ts.code-editor-agent.md", "style.code-editor-agent.md"},
+		}},
+	}
+	for _, test := range tables {
+		for _, format := range []string{FormatText, FormatTable} {
+			t.Run(test.kind+" "+format, func(t *testing.T) {
+				output, err := captureStdout(t, func() error { return List(test.kind, test.agent, format) })
+				if err != nil {
+					t.Fatal(err)
+				}
+				lines := strings.Split(strings.TrimSuffix(output, "\n"), "\n")
+				if len(lines) != len(test.rows) {
+					t.Fatalf("list %s = %q, want %d lines", test.kind, output, len(test.rows))
+				}
+				for i, line := range lines {
+					if fields := strings.Fields(line); !reflect.DeepEqual(fields, test.rows[i]) {
+						t.Errorf("list %s line %d = %q, want %q", test.kind, i, fields, test.rows[i])
+					}
+				}
+			})
+		}
+	}
+
+	t.Run("agents json", func(t *testing.T) {
+		var agents []listedAgent
+		listJSON(t, ListAgents, "", &agents)
+		reviewer := "reviewer"
+		want := []listedAgent{
+			{Name: "code-editor", RuleFilePattern: "**/*.code-editor-agent.md", References: []string{}, Rules: 2},
+			{Name: "code-reviewer", CommandGroup: &reviewer, RuleFilePattern: "**/*.code-reviewer.md", References: []string{"code-editor"}, Rules: 1},
+		}
+		if !reflect.DeepEqual(agents, want) {
+			t.Errorf("list agents = %+v, want %+v", agents, want)
+		}
+	})
+	t.Run("rules json", func(t *testing.T) {
+		var rules []listedRule
+		listJSON(t, ListRules, "code-reviewer", &rules)
+		order := 1
+		want := []listedRule{{Agent: "code-reviewer", Path: "all.code-reviewer.md", Patterns: []string{"**"}, Order: &order, Tags: []string{}, FrontMatter: "yaml", Bytes: 9, Tokens: 3}}
+		if !reflect.DeepEqual(rules, want) {
+			t.Errorf("list rules = %+v, want %+v", rules, want)
+		}
+	})
+	t.Run("tags json", func(t *testing.T) {
+		var tags []listedTag
+		listJSON(t, ListTags, "code-reviewer", &tags)
+		if len(tags) != 0 {
+			t.Errorf("list tags = %+v, want none", tags)
+		}
+	})
+
+	if _, err := captureStdout(t, func() error { return List("files", "", FormatText) }); err == nil {
+		t.Error("List(files) succeeded, want an error")
+	}
+	if _, err := captureStdout(t, func() error { return List(ListRules, "test-writer", FormatText) }); err == nil {
+		t.Error("List() of an unknown agent succeeded, want an error")
+	}
+}
+
+// listJSON runs `cmd list kind --format json` and decodes its output into v
+func listJSON(t *testing.T, kind, agentName string, v interface{}) {
+	t.Helper()
+	output, err := captureStdout(t, func() error { return List(kind, agentName, FormatJSON) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(output), v); err != nil {
+		t.Fatalf("list %s printed invalid JSON %q: %v", kind, output, err)
+	}
+}
@@ -0,0 +1,35 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/dirt-rain/code-editor-agent/models"
+	"github.com/dirt-rain/code-editor-agent/resolver"
+)
+
+// loadRuleCache reads the unified cache file
+func loadRuleCache() (resolver.Cache, error) {
+	return resolver.LoadCache(projectFS)
+}
+
+// newResolver returns a resolver for the project in projectFS, printing
+// warnings
+func newResolver(cfg *models.Config, cache resolver.Cache) *resolver.Resolver {
+	r := resolver.New(projectFS, ".", cfg, cache)
+	r.Warn = func(message string) {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", message)
+	}
+	return r
+}
+
+// findFiles finds all project files matching the pattern, excluding patterns in exclude
+func findFiles(pattern string, exclude []string, opts ...doublestar.GlobOption) ([]string, error) {
+	return resolver.FindFiles(projectFS, pattern, exclude, opts...)
+}
+
+// extractBody extracts the body (content after front matter) from a project file
+func extractBody(filePath string) (string, error) {
+	return resolver.ExtractBody(projectFS, filePath)
+}
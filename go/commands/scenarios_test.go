@@ -0,0 +1,103 @@
+package commands
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/dirt-rain/code-editor-agent/utils"
+)
+
+// testdataRoot is the repository root, holding test-templates and
+// test-snapshots. It is absolute as TestGolden changes the working directory.
+var testdataRoot, _ = filepath.Abs("../..")
+
+// memFS is an in-memory project
+type memFS struct {
+	fstest.MapFS
+}
+
+func (m memFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	m.MapFS[name] = &fstest.MapFile{Data: append([]byte{}, data...), Mode: perm}
+	return nil
+}
+
+// MkdirAll does nothing, the directories of a MapFS are implied by its files
+func (m memFS) MkdirAll(name string, perm fs.FileMode) error {
+	return nil
+}
+
+func (m memFS) AppendFile(name string, data []byte, perm fs.FileMode) error {
+	if file, ok := m.MapFS[name]; ok {
+		file.Data = append(file.Data, data...)
+		return nil
+	}
+	return m.WriteFile(name, data, perm)
+}
+
+func (m memFS) Remove(name string) error {
+	if _, ok := m.MapFS[name]; !ok {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	}
+	delete(m.MapFS, name)
+	return nil
+}
+
+// useProject makes the commands work on fsys until the end of the test
+func useProject(t *testing.T, fsys utils.WritableFS) {
+	t.Helper()
+	previous := projectFS
+	projectFS = fsys
+	t.Cleanup(func() { projectFS = previous })
+}
+
+// captureStdout returns what run prints to stdout
+func captureStdout(t *testing.T, run func() error) (string, error) {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	output := make(chan string)
+	go func() {
+		var buf bytes.Buffer
+		io.Copy(&buf, r)
+		output <- buf.String()
+	}()
+	runErr := run()
+	w.Close()
+	return <-output, runErr
+}
+
+// readTestdata reads a file of test-templates or test-snapshots
+func readTestdata(t *testing.T, name string) []byte {
+	t.Helper()
+	content, err := os.ReadFile(filepath.Join(testdataRoot, filepath.FromSlash(name)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return content
+}
+
+// TestScenarios runs each scenario on an in-memory project
+func TestScenarios(t *testing.T) {
+	if *update {
+		t.Skip("TestGolden updates the snapshots")
+	}
+	for _, s := range discoverScenarios(t) {
+		t.Run(s.name, func(t *testing.T) {
+			useProject(t, memFS{fstest.MapFS{}})
+
+			output := runScenario(t, s)
+			compareSnapshot(t, s, output)
+		})
+	}
+}
@@ -0,0 +1,409 @@
+package commands
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/dirt-rain/code-editor-agent/conditions"
+	"github.com/dirt-rain/code-editor-agent/config"
+	"github.com/dirt-rain/code-editor-agent/models"
+)
+
+// Environment variables controlling the daemon client
+const (
+	DaemonSocketEnv  = "CODE_EDITOR_AGENT_SOCKET"
+	DaemonDisableEnv = "CODE_EDITOR_AGENT_NO_DAEMON"
+)
+
+// daemonDialTimeout keeps the CLI fast when no daemon is running
+const daemonDialTimeout = 200 * time.Millisecond
+
+// daemonRequest is a single request line sent to the daemon
+type daemonRequest struct {
+	Command string            `json:"command"` // "load", "explain", "rules" or "env"
+	Group   *string           `json:"group"`
+	Agent   string            `json:"agent,omitempty"` // agent name, instead of group
+	Path    string            `json:"path"`
+	Root    string            `json:"root"` // client's project root, must match the daemon's
+	Env     map[string]string `json:"env"`  // client's variables read by `when` conditions
+}
+
+// daemonResponse is a single response line sent by the daemon
+type daemonResponse struct {
+	Output string   `json:"output,omitempty"`
+	Env    []string `json:"env,omitempty"` // variables read by `when` conditions, for "env"
+	Error  string   `json:"error,omitempty"`
+}
+
+// maxSocketPathLen is the size of sun_path on macOS and the BSDs, the
+// smallest of the supported systems, including the terminating NUL
+const maxSocketPathLen = 104
+
+// DefaultSocketPath returns the socket path used for the project in the
+// current directory: a per-project socket in $XDG_RUNTIME_DIR if it is set,
+// or else in a per-user directory of the temporary directory, which keeps
+// the path short however deep the project is
+func DefaultSocketPath() (string, error) {
+	if socketPath := os.Getenv(DaemonSocketEnv); socketPath != "" {
+		return socketPath, nil
+	}
+	root, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(root))
+	name := hex.EncodeToString(sum[:6]) + ".sock"
+	if runtimeDir := os.Getenv("XDG_RUNTIME_DIR"); runtimeDir != "" {
+		return filepath.Join(runtimeDir, "code-editor-agent", name), nil
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("code-editor-agent-%d", os.Getuid()), name), nil
+}
+
+// projectState is the config and cache kept in memory by the daemon and the
+// MCP server
+type projectState struct {
+	mu            sync.RWMutex
+	cfg           *models.Config
+	allAgentRules map[string][]models.RuleCacheEntry
+	envNames      []string
+	stamp         string
+}
+
+// fileStamp identifies the current version of the config and cache files
+func fileStamp() string {
+	var sb strings.Builder
+	paths := append([]string{}, config.FilePaths...)
+	if !containsString(paths, config.FilePath) {
+		paths = append(paths, config.FilePath)
+	}
+	for _, path := range append(paths, models.RuleCacheFilePath) {
+		if info, err := statFile(path); err == nil {
+			fmt.Fprintf(&sb, "%s:%d:%d;", path, info.Size(), info.ModTime().UnixNano())
+		} else {
+			fmt.Fprintf(&sb, "%s:missing;", path)
+		}
+	}
+	return sb.String()
+}
+
+// current returns the config and cache, after picking up changes on disk.
+// Checking the stamps costs a few stats, and answers are never stale.
+func (s *projectState) current() (*models.Config, map[string][]models.RuleCacheEntry, error) {
+	if err := s.reload(); err != nil {
+		return nil, nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cfg, s.allAgentRules, nil
+}
+
+// reload re-reads the config and cache if they changed on disk
+func (s *projectState) reload() error {
+	stamp := fileStamp()
+	s.mu.RLock()
+	unchanged := stamp == s.stamp
+	s.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	cfg, err := config.Load(projectFS)
+	if err != nil {
+		return err
+	}
+	allAgentRules, err := loadRuleCache()
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.cfg, s.allAgentRules, s.envNames, s.stamp = cfg, allAgentRules, conditionEnvNames(allAgentRules), stamp
+	s.mu.Unlock()
+	return nil
+}
+
+// conditionEnvNames returns the environment variables read by the `when`
+// conditions of the cached rules, the only ones clients send
+func conditionEnvNames(allAgentRules map[string][]models.RuleCacheEntry) []string {
+	seen := make(map[string]bool)
+	names := []string{}
+	for _, rules := range allAgentRules {
+		for _, rule := range rules {
+			if rule.When == nil {
+				continue
+			}
+			// Invalid conditions are reported when they are evaluated
+			cond, err := conditions.Parse(rule.When)
+			if err != nil {
+				continue
+			}
+			for _, name := range cond.EnvNames() {
+				if !seen[name] {
+					seen[name] = true
+					names = append(names, name)
+				}
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Serve runs a daemon answering load and explain requests over a Unix socket,
+// keeping the config and cache in memory and reloading them when they change.
+// The socket is socketPath, or DefaultSocketPath if it is empty, and is only
+// accessible to the current user.
+func Serve(socketPath string) error {
+	root, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	if socketPath == "" {
+		if socketPath, err = DefaultSocketPath(); err != nil {
+			return err
+		}
+		if os.Getenv(DaemonSocketEnv) == "" {
+			if err := createSocketDir(filepath.Dir(socketPath)); err != nil {
+				return err
+			}
+		}
+	}
+	if len(socketPath) >= maxSocketPathLen {
+		return fmt.Errorf("Socket path %s is too long: %d bytes, Unix sockets allow at most %d. Pass a shorter --socket.", socketPath, len(socketPath), maxSocketPathLen-1)
+	}
+
+	state := &projectState{}
+	if err := state.reload(); err != nil {
+		return err
+	}
+
+	// A leftover socket from a crashed daemon is removed; a live one is an error
+	if _, err := os.Stat(socketPath); err == nil {
+		if conn, err := net.DialTimeout("unix", socketPath, daemonDialTimeout); err == nil {
+			conn.Close()
+			return fmt.Errorf("A daemon is already listening on %s.", socketPath)
+		}
+		if err := os.Remove(socketPath); err != nil {
+			return fmt.Errorf("failed to remove stale socket: %w", err)
+		}
+	}
+
+	listener, err := listenUnix(socketPath)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", socketPath, err)
+	}
+	defer os.Remove(socketPath)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		listener.Close()
+	}()
+
+	fmt.Fprintf(os.Stderr, "Listening on %s\n", socketPath)
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go handleDaemonConn(conn, state, root)
+	}
+}
+
+func handleDaemonConn(conn net.Conn, state *projectState, root string) {
+	defer conn.Close()
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	encoder := json.NewEncoder(conn)
+	encoder.SetEscapeHTML(false)
+
+	for scanner.Scan() {
+		var req daemonRequest
+		resp := daemonResponse{}
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			resp.Error = fmt.Sprintf("invalid request: %v", err)
+		} else if req.Root != root {
+			resp.Error = fmt.Sprintf("daemon serves %s, not %s", root, req.Root)
+		} else if req.Command == "env" {
+			names, err := state.conditionEnv()
+			resp.Env = names
+			if err != nil {
+				resp.Error = err.Error()
+			}
+		} else {
+			output, err := state.handle(&req)
+			resp.Output = output
+			if err != nil {
+				resp.Error = err.Error()
+			}
+		}
+		if err := encoder.Encode(resp); err != nil {
+			return
+		}
+	}
+}
+
+// createSocketDir creates the directory of the default socket, readable only
+// by the current user. An existing directory must belong to the current user,
+// so nobody else can create sockets in it.
+func createSocketDir(dir string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create socket directory: %w", err)
+	}
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return fmt.Errorf("failed to create socket directory: %w", err)
+	}
+	if !info.IsDir() || !ownedByCurrentUser(info) {
+		return fmt.Errorf("Socket directory %s is not a directory owned by the current user. Remove it, or pass --socket.", dir)
+	}
+	if err := os.Chmod(dir, 0700); err != nil {
+		return fmt.Errorf("failed to restrict access to %s: %w", dir, err)
+	}
+	return nil
+}
+
+// conditionEnv returns the environment variables clients send, after
+// picking up changes to the config and cache
+func (s *projectState) conditionEnv() ([]string, error) {
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.envNames, nil
+}
+
+func (s *projectState) handle(req *daemonRequest) (string, error) {
+	cfg, allAgentRules, err := s.current()
+	if err != nil {
+		return "", err
+	}
+
+	agentName := req.Agent
+	if agentName == "" {
+		if agentName, err = config.FindAgentByCommandGroup(cfg, req.Group); err != nil {
+			return "", err
+		}
+	}
+
+	r := newResolver(cfg, allAgentRules)
+	r.Context.LookupEnv = func(name string) (string, bool) {
+		value, ok := req.Env[name]
+		return value, ok
+	}
+
+	res, err := r.Explain(agentName, req.Path)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	switch req.Command {
+	case "load":
+		if err := writeRules(&sb, r, res); err != nil {
+			return "", err
+		}
+		logUsage(cfg, res)
+	case "explain":
+		writeExplanation(&sb, res)
+	case "rules":
+		// Like load, but nothing at all when no rules apply, for the hook
+		if len(res.Candidates) == 0 {
+			return "", nil
+		}
+		if err := writeRules(&sb, r, res); err != nil {
+			return "", err
+		}
+		logUsage(cfg, res)
+	default:
+		return "", fmt.Errorf("unknown command: %s", req.Command)
+	}
+	return sb.String(), nil
+}
+
+// RequestDaemon sends a load or explain request to a running daemon and
+// writes its output to w. It reports false, without writing anything, if no
+// daemon for this project is reachable or the request failed, so the caller
+// can fall back to resolving locally.
+func RequestDaemon(command string, group *string, filePath string, w io.Writer) bool {
+	output, ok := requestDaemon(daemonRequest{Command: command, Group: group, Path: filePath})
+	if !ok {
+		return false
+	}
+	_, err := io.WriteString(w, output)
+	return err == nil
+}
+
+// requestDaemon sends req to a running daemon and returns its output, or
+// false if no daemon for this project is reachable or the request failed
+func requestDaemon(req daemonRequest) (string, bool) {
+	if os.Getenv(DaemonDisableEnv) != "" {
+		return "", false
+	}
+	socketPath, err := DefaultSocketPath()
+	if err != nil {
+		return "", false
+	}
+	root, err := os.Getwd()
+	if err != nil {
+		return "", false
+	}
+
+	// A socket of another user could be a daemon reading our requests
+	info, err := os.Stat(socketPath)
+	if err != nil || !ownedByCurrentUser(info) {
+		return "", false
+	}
+	conn, err := net.DialTimeout("unix", socketPath, daemonDialTimeout)
+	if err != nil {
+		return "", false
+	}
+	defer conn.Close()
+	encoder := json.NewEncoder(conn)
+	decoder := json.NewDecoder(bufio.NewReader(conn))
+
+	// Only send the environment variables `when` conditions read
+	var envResp daemonResponse
+	if err := encoder.Encode(daemonRequest{Command: "env", Root: root}); err != nil {
+		return "", false
+	}
+	if err := decoder.Decode(&envResp); err != nil || envResp.Error != "" {
+		return "", false
+	}
+	req.Root = root
+	req.Env = make(map[string]string)
+	for _, name := range envResp.Env {
+		if value, ok := os.LookupEnv(name); ok {
+			req.Env[name] = value
+		}
+	}
+
+	if err := encoder.Encode(req); err != nil {
+		return "", false
+	}
+	var resp daemonResponse
+	if err := decoder.Decode(&resp); err != nil || resp.Error != "" {
+		return "", false
+	}
+	return resp.Output, true
+}
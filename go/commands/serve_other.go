@@ -0,0 +1,19 @@
+//go:build !unix
+
+package commands
+
+import (
+	"io/fs"
+	"net"
+)
+
+// ownedByCurrentUser reports whether the file belongs to the current user.
+// Without Unix file owners, access to the socket is left to the system.
+func ownedByCurrentUser(info fs.FileInfo) bool {
+	return true
+}
+
+// listenUnix listens on a socket whose access is left to the system
+func listenUnix(socketPath string) (net.Listener, error) {
+	return net.Listen("unix", socketPath)
+}
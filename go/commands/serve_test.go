@@ -0,0 +1,195 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// recordingConn records what the client sent to the daemon
+type recordingConn struct {
+	net.Conn
+	mu       *sync.Mutex
+	received *bytes.Buffer
+}
+
+func (c recordingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.mu.Lock()
+	c.received.Write(p[:n])
+	c.mu.Unlock()
+	return n, err
+}
+
+// startTestDaemon serves the project in projectFS on a socket used by the
+// client until the end of the test, returning what clients sent
+func startTestDaemon(t *testing.T) func() string {
+	t.Helper()
+	socketPath := filepath.Join(t.TempDir(), "daemon.sock")
+	t.Setenv(DaemonSocketEnv, socketPath)
+	t.Setenv(DaemonDisableEnv, "")
+
+	state := &projectState{}
+	if err := state.reload(); err != nil {
+		t.Fatal(err)
+	}
+	listener, err := listenUnix(socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	if info, err := os.Stat(socketPath); err != nil || (runtime.GOOS != "windows" && info.Mode().Perm() != 0600) {
+		t.Fatalf("socket %s = %v, %v, want mode 0600", socketPath, info, err)
+	}
+
+	var mu sync.Mutex
+	received := &bytes.Buffer{}
+	root, _ := filepath.Abs(".")
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			handleDaemonConn(recordingConn{conn, &mu, received}, state, root)
+		}
+	}()
+	return func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		return received.String()
+	}
+}
+
+func TestDaemonRequest(t *testing.T) {
+	project := newMemProject(t, map[string]string{
+		"a.code-editor-agent.md":   "---\npatterns: \"**\"\n---\n# A\n",
+		"env.code-editor-agent.md": "---\npatterns: \"src/**\"\nwhen:\n  env: RULE_ENV\n---\n# Env\n",
+	})
+	t.Setenv("RULE_ENV", "1")
+	t.Setenv("UNRELATED_SECRET", "hunter2")
+	received := startTestDaemon(t)
+
+	output, ok := requestDaemon(daemonRequest{Command: "load", Path: "src/main.go"})
+	if !ok {
+		t.Fatal("requestDaemon() failed")
+	}
+	if !strings.Contains(output, "# A") || !strings.Contains(output, "# Env") {
+		t.Errorf("load output = %q, want both rules", output)
+	}
+	if sent := received(); !strings.Contains(sent, `"RULE_ENV":"1"`) || strings.Contains(sent, "UNRELATED_SECRET") {
+		t.Errorf("client sent %s, want only the variables read by conditions", sent)
+	}
+
+	// Rule changes are picked up by the next request
+	project.MapFS["a.code-editor-agent.md"].Data = []byte("---\npatterns: \"docs/**\"\n---\n# A\n")
+	if _, err := captureStdout(t, func() error { return Generate(false) }); err != nil {
+		t.Fatal(err)
+	}
+	output, ok = requestDaemon(daemonRequest{Command: "rules", Agent: "code-editor", Path: "src/main.go"})
+	if !ok || strings.Contains(output, "# A") || !strings.Contains(output, "# Env") {
+		t.Errorf("rules output = %q, %t, want only the rule still matching", output, ok)
+	}
+	output, ok = requestDaemon(daemonRequest{Command: "rules", Agent: "code-editor", Path: "README"})
+	if !ok || output != "" {
+		t.Errorf("rules output = %q, %t, want nothing for a file without rules", output, ok)
+	}
+}
+
+func TestConditionEnvNames(t *testing.T) {
+	newMemProject(t, map[string]string{
+		"a.code-editor-agent.md": "---\npatterns: \"**\"\nwhen:\n  any:\n    - env: [B_VAR, A_VAR]\n    - not:\n        env: {C_VAR: x}\n---\n# A\n",
+		"b.code-editor-agent.md": "---\npatterns: \"**\"\nwhen:\n  env: A_VAR\n---\n# B\n",
+	})
+	allAgentRules, err := loadRuleCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := strings.Join(conditionEnvNames(allAgentRules), ",")
+	if want := "A_VAR,B_VAR,C_VAR"; got != want {
+		t.Errorf("conditionEnvNames() = %s, want %s", got, want)
+	}
+}
+
+func TestDefaultSocketPath(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	deep := filepath.Join(t.TempDir(), strings.Repeat("nested-project-directory/", 8))
+	if err := os.MkdirAll(deep, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(deep); err != nil {
+		t.Fatal(err)
+	}
+
+	runtimeDir := t.TempDir()
+	tests := []struct {
+		name       string
+		socketEnv  string
+		runtimeDir string
+		dir        string
+	}{
+		{name: "runtime directory", runtimeDir: runtimeDir, dir: filepath.Join(runtimeDir, "code-editor-agent")},
+		{name: "temporary directory", dir: filepath.Join(os.TempDir(), fmt.Sprintf("code-editor-agent-%d", os.Getuid()))},
+		{name: "environment", socketEnv: "/run/custom.sock", runtimeDir: runtimeDir, dir: "/run"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv(DaemonSocketEnv, test.socketEnv)
+			t.Setenv("XDG_RUNTIME_DIR", test.runtimeDir)
+			socketPath, err := DefaultSocketPath()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if filepath.Dir(socketPath) != test.dir {
+				t.Errorf("DefaultSocketPath() = %s, want a socket in %s", socketPath, test.dir)
+			}
+			if len(socketPath) >= maxSocketPathLen {
+				t.Errorf("DefaultSocketPath() = %s, longer than a Unix socket path can be for %s", socketPath, deep)
+			}
+		})
+	}
+}
+
+func TestServeSocketPathTooLong(t *testing.T) {
+	newMemProject(t, map[string]string{})
+	socketPath := filepath.Join(t.TempDir(), strings.Repeat("s", maxSocketPathLen)+".sock")
+	err := Serve(socketPath)
+	if err == nil || !strings.Contains(err.Error(), "is too long") {
+		t.Errorf("Serve(%s) = %v, want a too long error", socketPath, err)
+	}
+}
+
+func TestCreateSocketDir(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("directory modes are Unix-only")
+	}
+	dir := filepath.Join(t.TempDir(), "code-editor-agent")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := createSocketDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(dir); err != nil || info.Mode().Perm() != 0700 {
+		t.Errorf("socket directory = %v, %v, want mode 0700", info, err)
+	}
+
+	// A symbolic link could point to a directory of another user
+	link := filepath.Join(t.TempDir(), "link")
+	if err := os.Symlink(dir, link); err != nil {
+		t.Fatal(err)
+	}
+	if err := createSocketDir(link); err == nil {
+		t.Error("createSocketDir() of a symbolic link succeeded, want an error")
+	}
+}
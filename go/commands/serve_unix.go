@@ -0,0 +1,24 @@
+//go:build unix
+
+package commands
+
+import (
+	"io/fs"
+	"net"
+	"os"
+	"syscall"
+)
+
+// ownedByCurrentUser reports whether the file belongs to the current user
+func ownedByCurrentUser(info fs.FileInfo) bool {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	return ok && int(stat.Uid) == os.Getuid()
+}
+
+// listenUnix listens on a socket created with mode 0600, so other users
+// can't connect before its mode could be changed
+func listenUnix(socketPath string) (net.Listener, error) {
+	mask := syscall.Umask(0177)
+	defer syscall.Umask(mask)
+	return net.Listen("unix", socketPath)
+}
@@ -0,0 +1,192 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/dirt-rain/code-editor-agent/utils"
+)
+
+// ClaudeSettingsFilePath is the project-level Claude Code settings file
+const ClaudeSettingsFilePath = ".claude/settings.json"
+
+// readClaudeSettings reads .claude/settings.json, returning an empty object if it doesn't exist
+func readClaudeSettings() (map[string]interface{}, error) {
+	raw, err := readFile(ClaudeSettingsFilePath)
+	if err != nil {
+		return nil, err
+	}
+	settings := map[string]interface{}{}
+	if raw == nil || len(bytes.TrimSpace(raw)) == 0 {
+		return settings, nil
+	}
+	if err := json.Unmarshal(raw, &settings); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", ClaudeSettingsFilePath, err)
+	}
+	return settings, nil
+}
+
+// marshalClaudeSettings formats settings the way Claude Code writes them
+func marshalClaudeSettings(settings map[string]interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(settings); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeClaudeSettings writes .claude/settings.json
+func writeClaudeSettings(settings map[string]interface{}) error {
+	content, err := marshalClaudeSettings(settings)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", ClaudeSettingsFilePath, err)
+	}
+	if err := writeFile(ClaudeSettingsFilePath, content); err != nil {
+		return fmt.Errorf("failed to write %s: %w", ClaudeSettingsFilePath, err)
+	}
+	return nil
+}
+
+// addPreToolUseHook registers command as a PreToolUse hook for the tools in
+// matcher, leaving all other settings untouched. It reports whether the
+// settings changed.
+func addPreToolUseHook(settings map[string]interface{}, matcher, command string) (bool, error) {
+	hooks, ok := settings["hooks"].(map[string]interface{})
+	if !ok {
+		if settings["hooks"] != nil {
+			return false, fmt.Errorf("`%s` 'hooks' must be an object", ClaudeSettingsFilePath)
+		}
+		hooks = map[string]interface{}{}
+		settings["hooks"] = hooks
+	}
+
+	entries, ok := hooks["PreToolUse"].([]interface{})
+	if !ok && hooks["PreToolUse"] != nil {
+		return false, fmt.Errorf("`%s` 'hooks.PreToolUse' must be an array", ClaudeSettingsFilePath)
+	}
+
+	// Already registered, possibly with a different matcher chosen by the user
+	for _, entry := range entries {
+		entryMap, _ := entry.(map[string]interface{})
+		handlers, _ := entryMap["hooks"].([]interface{})
+		for _, handler := range handlers {
+			handlerMap, _ := handler.(map[string]interface{})
+			if existing, _ := handlerMap["command"].(string); existing == command {
+				return false, nil
+			}
+		}
+	}
+
+	hooks["PreToolUse"] = append(entries, map[string]interface{}{
+		"matcher": matcher,
+		"hooks": []interface{}{
+			map[string]interface{}{
+				"type":    "command",
+				"command": command,
+			},
+		},
+	})
+	return true, nil
+}
+
+// addPermission adds permission to the allowed permissions, leaving all
+// other settings untouched. It reports whether the settings changed.
+func addPermission(settings map[string]interface{}, permission string) (bool, error) {
+	permissions, ok := settings["permissions"].(map[string]interface{})
+	if !ok {
+		if settings["permissions"] != nil {
+			return false, fmt.Errorf("`%s` 'permissions' must be an object", ClaudeSettingsFilePath)
+		}
+		permissions = map[string]interface{}{}
+		settings["permissions"] = permissions
+	}
+
+	allow, ok := permissions["allow"].([]interface{})
+	if !ok && permissions["allow"] != nil {
+		return false, fmt.Errorf("`%s` 'permissions.allow' must be an array", ClaudeSettingsFilePath)
+	}
+	for _, existing := range allow {
+		if existing == permission {
+			return false, nil
+		}
+	}
+
+	permissions["allow"] = append(allow, permission)
+	return true, nil
+}
+
+// updateClaudeSettings registers the hook and the permission to run the CLI
+// in .claude/settings.json, as requested by opts
+func updateClaudeSettings(opts InitOptions) error {
+	raw, err := readFile(ClaudeSettingsFilePath)
+	if err != nil {
+		return err
+	}
+	settings, err := readClaudeSettings()
+	if err != nil {
+		return err
+	}
+
+	changed := false
+	if opts.Hook {
+		added, err := addPreToolUseHook(settings, hookToolMatcher, hookCommand)
+		if err != nil {
+			return err
+		}
+		if !added {
+			fmt.Printf("Hook already registered in %s\n", ClaudeSettingsFilePath)
+		}
+		changed = changed || added
+	}
+	if opts.Runtime != "" {
+		permission := runtimePermissions[opts.Runtime]
+		added, err := addPermission(settings, permission)
+		if err != nil {
+			return err
+		}
+		if !added {
+			fmt.Printf("Permission %s already allowed in %s\n", permission, ClaudeSettingsFilePath)
+		}
+		changed = changed || added
+	}
+	if !changed {
+		return nil
+	}
+
+	if opts.DryRun {
+		content, err := marshalClaudeSettings(settings)
+		if err != nil {
+			return fmt.Errorf("failed to marshal %s: %w", ClaudeSettingsFilePath, err)
+		}
+		fmt.Print(utils.UnifiedDiff("a/"+ClaudeSettingsFilePath, "b/"+ClaudeSettingsFilePath, string(raw), string(content)))
+		return nil
+	}
+	if err := writeClaudeSettings(settings); err != nil {
+		return err
+	}
+	fmt.Printf("Updated %s\n", ClaudeSettingsFilePath)
+	return nil
+}
+
+// projectRuntime returns the runtime whose permission is allowed in
+// .claude/settings.json, or "" if none is
+func projectRuntime() string {
+	settings, err := readClaudeSettings()
+	if err != nil {
+		return ""
+	}
+	permissions, _ := settings["permissions"].(map[string]interface{})
+	allow, _ := permissions["allow"].([]interface{})
+	for _, runtime := range []string{RuntimeGo, RuntimeNode} {
+		for _, permission := range allow {
+			if permission == runtimePermissions[runtime] {
+				return runtime
+			}
+		}
+	}
+	return ""
+}
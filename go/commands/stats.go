@@ -0,0 +1,283 @@
+package commands
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dirt-rain/code-editor-agent/config"
+	"github.com/dirt-rain/code-editor-agent/models"
+	"github.com/dirt-rain/code-editor-agent/resolver"
+)
+
+// usageEntry is a line of the usage log, written for every load
+type usageEntry struct {
+	Time    time.Time `json:"time"`
+	Agent   string    `json:"agent"`
+	Path    string    `json:"path"`
+	Printed []string  `json:"printed"`
+	Dropped []string  `json:"dropped"`
+}
+
+// usageLogMu serializes the appends of the daemon's connections
+var usageLogMu sync.Mutex
+
+// logUsage appends the outcome of a load to the usage log if usageLog is set.
+// Failing to write it only warns, the rules were printed already.
+func logUsage(cfg *models.Config, res *resolver.Resolution) {
+	if !cfg.UsageLog {
+		return
+	}
+	entry := usageEntry{Time: time.Now().UTC(), Agent: res.AgentName, Path: res.FilePath, Printed: []string{}, Dropped: []string{}}
+	for _, rule := range res.Final {
+		entry.Printed = append(entry.Printed, resolver.DisplayName(rule))
+	}
+	for _, rule := range res.Dropped() {
+		entry.Dropped = append(entry.Dropped, resolver.DisplayName(rule))
+	}
+	line, err := json.Marshal(entry)
+	if err == nil {
+		err = appendUsageLog(append(line, '\n'))
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: Failed to write the usage log: %v\n", err)
+	}
+}
+
+func appendUsageLog(line []byte) error {
+	usageLogMu.Lock()
+	defer usageLogMu.Unlock()
+	if err := projectFS.MkdirAll(path.Dir(models.UsageLogFilePath), 0755); err != nil {
+		return err
+	}
+	return projectFS.AppendFile(models.UsageLogFilePath, line, 0644)
+}
+
+// StatsOptions holds the options of `cmd stats`
+type StatsOptions struct {
+	Agent  string // every agent if empty
+	Format string
+	// Days is the period after which a rule not loaded counts as unused
+	Days int
+	// Limit is the number of rules in each list, 0 for all
+	Limit int
+}
+
+// ruleUsage sums up the usage log entries of a rule file
+type ruleUsage struct {
+	Path       string     `json:"path"`
+	Loads      int        `json:"loads"`
+	Dropped    int        `json:"dropped"`
+	LastLoaded *time.Time `json:"lastLoaded"`
+}
+
+// statsReport is the summary of the usage log
+type statsReport struct {
+	Agent       string      `json:"agent,omitempty"`
+	Loads       int         `json:"loads"`
+	Since       *time.Time  `json:"since"`
+	Days        int         `json:"days"`
+	MostUsed    []ruleUsage `json:"mostUsed"`
+	LeastUsed   []ruleUsage `json:"leastUsed"`
+	Unused      []ruleUsage `json:"unused"`
+	UnusedTotal int         `json:"unusedTotal"`
+	MostDropped []ruleUsage `json:"mostDropped"`
+}
+
+// Stats summarizes the usage log: the most and least loaded rules, the rules
+// not loaded in the last opts.Days days and the rules most often dropped by
+// priority. Only the rules of the current cache are reported; sections count
+// as their rule file.
+func Stats(opts StatsOptions) error {
+	cfg, err := config.Load(projectFS)
+	if err != nil {
+		return err
+	}
+	allAgentRules, err := loadRuleCache()
+	if err != nil {
+		return err
+	}
+
+	agentNames := []string{}
+	if opts.Agent != "" {
+		if agentNames, _, err = newResolver(cfg, allAgentRules).AgentRules(opts.Agent); err != nil {
+			return err
+		}
+	} else {
+		for name := range allAgentRules {
+			agentNames = append(agentNames, name)
+		}
+	}
+	usages := make(map[string]*ruleUsage)
+	for _, name := range agentNames {
+		for _, rule := range allAgentRules[name] {
+			usages[rule.Path] = &ruleUsage{Path: rule.Path}
+		}
+	}
+
+	entries, err := readUsageLog()
+	if os.IsNotExist(err) && !cfg.UsageLog {
+		configPath, _ := config.Resolve(projectFS)
+		return fmt.Errorf("No usage log found. Set \"usageLog\": true in `%s` to record the loaded rules.", configPath)
+	} else if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read the usage log: %w", err)
+	}
+
+	report := &statsReport{Agent: opts.Agent, Days: opts.Days}
+	for _, entry := range entries {
+		if opts.Agent != "" && entry.Agent != opts.Agent {
+			continue
+		}
+		report.Loads++
+		if report.Since == nil || entry.Time.Before(*report.Since) {
+			report.Since = &entry.Time
+		}
+		for _, file := range ruleFiles(entry.Printed) {
+			if usage := usages[file]; usage != nil {
+				usage.Loads++
+				if usage.LastLoaded == nil || entry.Time.After(*usage.LastLoaded) {
+					usage.LastLoaded = &entry.Time
+				}
+			}
+		}
+		for _, file := range ruleFiles(entry.Dropped) {
+			if usage := usages[file]; usage != nil {
+				usage.Dropped++
+			}
+		}
+	}
+
+	all := make([]ruleUsage, 0, len(usages))
+	for _, usage := range usages {
+		all = append(all, *usage)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Path < all[j].Path })
+
+	report.MostUsed = filterUsages(all, func(u ruleUsage) bool { return u.Loads > 0 })
+	sort.SliceStable(report.MostUsed, func(i, j int) bool { return report.MostUsed[i].Loads > report.MostUsed[j].Loads })
+	report.LeastUsed = append([]ruleUsage{}, all...)
+	sort.SliceStable(report.LeastUsed, func(i, j int) bool { return report.LeastUsed[i].Loads < report.LeastUsed[j].Loads })
+	cutoff := time.Now().AddDate(0, 0, -opts.Days)
+	report.Unused = filterUsages(all, func(u ruleUsage) bool { return u.LastLoaded == nil || u.LastLoaded.Before(cutoff) })
+	sort.SliceStable(report.Unused, func(i, j int) bool {
+		a, b := report.Unused[i].LastLoaded, report.Unused[j].LastLoaded
+		return a == nil && b != nil || a != nil && b != nil && a.Before(*b)
+	})
+	report.UnusedTotal = len(report.Unused)
+	report.MostDropped = filterUsages(all, func(u ruleUsage) bool { return u.Dropped > 0 })
+	sort.SliceStable(report.MostDropped, func(i, j int) bool { return report.MostDropped[i].Dropped > report.MostDropped[j].Dropped })
+	if opts.Limit > 0 {
+		report.MostUsed = report.MostUsed[:min(opts.Limit, len(report.MostUsed))]
+		report.LeastUsed = report.LeastUsed[:min(opts.Limit, len(report.LeastUsed))]
+		report.Unused = report.Unused[:min(opts.Limit, len(report.Unused))]
+		report.MostDropped = report.MostDropped[:min(opts.Limit, len(report.MostDropped))]
+	}
+
+	if opts.Format == FormatJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+	return writeStatsTable(os.Stdout, report)
+}
+
+// readUsageLog reads the entries of the usage log, warning about invalid lines
+func readUsageLog() ([]usageEntry, error) {
+	f, err := projectFS.Open(models.UsageLogFilePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	entries := []usageEntry{}
+	invalid := 0
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 16*1024*1024)
+	for scanner.Scan() {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var entry usageEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			invalid++
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if invalid > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: Skipped %d invalid lines of %s\n", invalid, models.UsageLogFilePath)
+	}
+	return entries, scanner.Err()
+}
+
+// ruleFiles returns the distinct rule files of logged rule names, dropping sections
+func ruleFiles(names []string) []string {
+	files := []string{}
+	for _, name := range names {
+		file, _ := resolver.SplitReference(name)
+		if !containsString(files, file) {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+func filterUsages(usages []ruleUsage, keep func(ruleUsage) bool) []ruleUsage {
+	filtered := []ruleUsage{}
+	for _, usage := range usages {
+		if keep(usage) {
+			filtered = append(filtered, usage)
+		}
+	}
+	return filtered
+}
+
+func writeStatsTable(out io.Writer, report *statsReport) error {
+	if report.Agent != "" {
+		fmt.Fprintf(out, "Agent: %s\n", report.Agent)
+	}
+	if report.Since == nil {
+		fmt.Fprintln(out, "No loads recorded yet.")
+	} else {
+		fmt.Fprintf(out, "%d loads since %s\n", report.Loads, report.Since.Local().Format(time.DateOnly))
+	}
+
+	sections := []struct {
+		title string
+		rules []ruleUsage
+		total int
+	}{
+		{"Most used rules", report.MostUsed, 0},
+		{"Least used rules", report.LeastUsed, 0},
+		{fmt.Sprintf("Rules not loaded in the last %d days (%d)", report.Days, report.UnusedTotal), report.Unused, report.UnusedTotal},
+		{"Rules most often dropped by priority", report.MostDropped, 0},
+	}
+	for _, section := range sections {
+		if len(section.rules) == 0 {
+			continue
+		}
+		fmt.Fprintf(out, "\n%s:\n", section.title)
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "  RULE\tLOADS\tDROPPED\tLAST LOADED")
+		for _, usage := range section.rules {
+			lastLoaded := "never"
+			if usage.LastLoaded != nil {
+				lastLoaded = usage.LastLoaded.Local().Format(time.DateOnly)
+			}
+			fmt.Fprintf(w, "  %s\t%d\t%d\t%s\n", usage.Path, usage.Loads, usage.Dropped, lastLoaded)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		writeMore(out, section.total, len(section.rules))
+	}
+	return nil
+}
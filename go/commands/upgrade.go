@@ -0,0 +1,200 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/dirt-rain/code-editor-agent/models"
+	"github.com/dirt-rain/code-editor-agent/utils"
+)
+
+// templateBase records the templates init wrote, so upgrade can tell the
+// user's edits apart from template changes
+type templateBase struct {
+	Presets []string          `json:"presets,omitempty"`
+	Agents  []string          `json:"agents,omitempty"`
+	Files   map[string]string `json:"files"`
+}
+
+// readTemplateBase reads the recorded templates, returning nil for projects
+// initialized before they were recorded
+func readTemplateBase() (*templateBase, error) {
+	raw, err := readFile(models.TemplateBaseFilePath)
+	if err != nil || raw == nil {
+		return nil, err
+	}
+	var base templateBase
+	if err := json.Unmarshal(raw, &base); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", models.TemplateBaseFilePath, err)
+	}
+	if base.Files == nil {
+		base.Files = make(map[string]string)
+	}
+	return &base, nil
+}
+
+// writeTemplateBase records the templates written to the project
+func writeTemplateBase(base *templateBase) error {
+	content, err := json.MarshalIndent(base, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal template base: %w", err)
+	}
+	if err := writeFile(models.TemplateBaseFilePath, append(content, '\n')); err != nil {
+		return fmt.Errorf("failed to write %s: %w", models.TemplateBaseFilePath, err)
+	}
+	return nil
+}
+
+// legacyTemplateFiles returns the templates written by init before they were
+// recorded, which older Node.js versions write too, by path
+func legacyTemplateFiles() map[string]string {
+	legacyAgent := strings.TrimSuffix(formatClaudeAgent("code-editor", &models.AgentConfig{}, "", RuntimeNode),
+		"\n"+claudeAgentUserStart+"\n"+claudeAgentUserEnd+"\n")
+	return map[string]string{
+		models.ConfigFilePath:           strings.Replace(configFileContent, defaultRuleFilePattern, "**/*.code-editor-agent.md", 1),
+		metaRuleFilePath:                ruleFileContent,
+		".claude/agents/code-editor.md": legacyAgent,
+	}
+}
+
+// UpgradeOptions holds the options of `cmd upgrade`
+type UpgradeOptions struct {
+	DryRun bool // show the changes without writing anything
+	// Adopt records the current templates as the base of files init didn't record
+	Adopt bool
+	// WriteConflicts writes files with conflicts, with conflict markers
+	WriteConflicts bool
+	// RuleFile is where the RENAME-ME.code-editor-agent.md rule was renamed to
+	RuleFile string
+}
+
+// Upgrade merges the changes of the built-in templates since init into the
+// project's files, keeping the user's edits
+func Upgrade(opts UpgradeOptions) error {
+	if !fileExists(models.RuleCacheFilePath) {
+		return fmt.Errorf("Very likely current working directory is not the root of the project, or `code-editor-agent cmd init` not yet runned.")
+	}
+
+	base, err := readTemplateBase()
+	if err != nil {
+		return err
+	}
+	legacy := base == nil
+	if legacy {
+		// Initialized by an older version or the Node.js version with the default templates
+		base = &templateBase{Agents: defaultStarterAgents, Files: legacyTemplateFiles()}
+	}
+
+	templates, err := templateFiles(InitOptions{Presets: base.Presets, Agents: base.Agents})
+	if err != nil {
+		return err
+	}
+
+	changed, skipped := false, 0
+	for _, templatePath := range sortedKeys(templates) {
+		template := templates[templatePath]
+		filePath := templatePath
+		if templatePath == metaRuleFilePath && opts.RuleFile != "" {
+			filePath = opts.RuleFile
+		}
+		baseContent, hasBase := base.Files[templatePath]
+
+		current, err := readFile(filePath)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", filePath, err)
+		}
+
+		switch {
+		case current == nil && (hasBase || legacy):
+			if templatePath == metaRuleFilePath {
+				fmt.Printf("Skipping %s: not found. If you renamed it, pass its path with --rule-file.\n", filePath)
+			} else {
+				fmt.Printf("Skipping %s: not found\n", filePath)
+			}
+
+		case current == nil:
+			// Added to the templates since init
+			fmt.Printf("==> %s: new template file\n", filePath)
+			if !opts.DryRun {
+				if err := writeUpgradedFile(filePath, template); err != nil {
+					return err
+				}
+				fmt.Printf("Created %s\n", filePath)
+				base.Files[templatePath] = template
+				changed = true
+			}
+
+		case string(current) == template:
+			base.Files[templatePath] = template
+
+		case !hasBase:
+			fmt.Printf("==> %s: no recorded template to merge with, differences from the current template:\n", filePath)
+			fmt.Print(utils.UnifiedDiff("a/"+filePath, "b/"+filePath, string(current), template))
+			if opts.Adopt {
+				// The differences are kept as the user's edits
+				base.Files[templatePath] = template
+				fmt.Println("Recorded the current template, keeping your version")
+			} else {
+				fmt.Println("Skipped. Edit the file by hand, or run with --adopt to keep your version and merge future template changes.")
+				skipped++
+			}
+
+		case baseContent == template:
+			// Template unchanged since init; the differences are the user's edits
+
+		default:
+			merged, conflicts := utils.Merge3(baseContent, string(current), template)
+			fmt.Printf("==> %s\n", filePath)
+			fmt.Println("Template changes:")
+			fmt.Print(utils.UnifiedDiff("a/"+templatePath+" (base)", "b/"+templatePath+" (template)", baseContent, template))
+			fmt.Println("Changes to your file:")
+			fmt.Print(utils.UnifiedDiff("a/"+filePath, "b/"+filePath, string(current), merged))
+			if conflicts > 0 && !opts.WriteConflicts {
+				fmt.Printf("Skipped: %d conflicts with your edits. Run with --write-conflicts to write them with conflict markers.\n", conflicts)
+				skipped++
+				continue
+			}
+			if opts.DryRun {
+				continue
+			}
+			if err := writeUpgradedFile(filePath, merged); err != nil {
+				return err
+			}
+			base.Files[templatePath] = template
+			changed = true
+			if conflicts > 0 {
+				fmt.Printf("Wrote %s with %d conflicts, resolve the %s markers\n", filePath, conflicts, utils.ConflictStart)
+			} else {
+				fmt.Printf("Upgraded %s\n", filePath)
+			}
+		}
+	}
+
+	if opts.DryRun {
+		return nil
+	}
+	if err := writeTemplateBase(base); err != nil {
+		return err
+	}
+
+	if skipped == 1 {
+		fmt.Println("\n1 file was not upgraded")
+	} else if skipped > 0 {
+		fmt.Printf("\n%d files were not upgraded\n", skipped)
+	} else if !changed {
+		fmt.Println("Everything is up to date")
+	}
+	if changed {
+		fmt.Println()
+		return Generate(false)
+	}
+	return nil
+}
+
+func writeUpgradedFile(filePath, content string) error {
+	if err := writeFile(filePath, []byte(content)); err != nil {
+		return fmt.Errorf("failed to write %s: %w", filePath, err)
+	}
+	return nil
+}
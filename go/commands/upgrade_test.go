@@ -0,0 +1,202 @@
+package commands
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/dirt-rain/code-editor-agent/models"
+	"github.com/dirt-rain/code-editor-agent/utils"
+)
+
+const claudeAgentPath = ".claude/agents/code-editor.md"
+
+// newInitializedProject returns an in-memory project initialized by init,
+// used by the commands until the end of the test
+func newInitializedProject(t *testing.T) memFS {
+	t.Helper()
+	project := memFS{fstest.MapFS{}}
+	useProject(t, project)
+	if _, err := captureStdout(t, func() error { return Init(InitOptions{}) }); err != nil {
+		t.Fatalf("init: %v", err)
+	}
+	return project
+}
+
+// setFile replaces a file of the project
+func setFile(project memFS, name, content string) {
+	project.MapFS[name] = &fstest.MapFile{Data: []byte(content)}
+}
+
+// recordBase replaces the recorded template of a file
+func recordBase(t *testing.T, project memFS, name, content string) {
+	t.Helper()
+	base, err := readTemplateBase()
+	if err != nil || base == nil {
+		t.Fatalf("readTemplateBase() = %v, %v", base, err)
+	}
+	base.Files[name] = content
+	if err := writeTemplateBase(base); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUpgrade(t *testing.T) {
+	const notes = "## Notes\n"
+	oldRule := ruleFileContent[:strings.Index(ruleFileContent, notes)]
+	editedRule := func(rule string) string {
+		return strings.Replace(rule, "## How it works:", "## How it works here:", 1)
+	}
+
+	tests := []struct {
+		name  string
+		setup func(t *testing.T, project memFS)
+		opts  UpgradeOptions
+		// files are the expected project files after the upgrade
+		files  map[string]string
+		output []string
+	}{
+		{
+			name:   "up to date",
+			setup:  func(t *testing.T, project memFS) {},
+			files:  map[string]string{metaRuleFilePath: ruleFileContent},
+			output: []string{"Everything is up to date"},
+		},
+		{
+			name: "template change merged with edits",
+			setup: func(t *testing.T, project memFS) {
+				recordBase(t, project, metaRuleFilePath, oldRule)
+				setFile(project, metaRuleFilePath, editedRule(oldRule))
+			},
+			files:  map[string]string{metaRuleFilePath: editedRule(ruleFileContent)},
+			output: []string{"Template changes:", "+" + strings.TrimSuffix(notes, "\n"), "Upgraded " + metaRuleFilePath},
+		},
+		{
+			name: "dry run",
+			setup: func(t *testing.T, project memFS) {
+				recordBase(t, project, metaRuleFilePath, oldRule)
+				setFile(project, metaRuleFilePath, editedRule(oldRule))
+			},
+			opts:   UpgradeOptions{DryRun: true},
+			files:  map[string]string{metaRuleFilePath: editedRule(oldRule)},
+			output: []string{"Changes to your file:"},
+		},
+		{
+			name: "renamed rule file",
+			setup: func(t *testing.T, project memFS) {
+				recordBase(t, project, metaRuleFilePath, oldRule)
+				delete(project.MapFS, metaRuleFilePath)
+				setFile(project, "docs/rules.code-editor-agent.md", oldRule)
+			},
+			opts:   UpgradeOptions{RuleFile: "docs/rules.code-editor-agent.md"},
+			files:  map[string]string{"docs/rules.code-editor-agent.md": ruleFileContent},
+			output: []string{"Upgraded docs/rules.code-editor-agent.md"},
+		},
+		{
+			name: "conflict",
+			setup: func(t *testing.T, project memFS) {
+				recordBase(t, project, metaRuleFilePath, strings.Replace(ruleFileContent, "## File format", "## Format", 1))
+				setFile(project, metaRuleFilePath, strings.Replace(ruleFileContent, "## File format", "## Our format", 1))
+			},
+			files:  map[string]string{metaRuleFilePath: strings.Replace(ruleFileContent, "## File format", "## Our format", 1)},
+			output: []string{"Skipped: 1 conflicts", "\n1 file was not upgraded\n"},
+		},
+		{
+			name: "conflict written",
+			setup: func(t *testing.T, project memFS) {
+				recordBase(t, project, metaRuleFilePath, strings.Replace(ruleFileContent, "## File format", "## Format", 1))
+				setFile(project, metaRuleFilePath, strings.Replace(ruleFileContent, "## File format", "## Our format", 1))
+			},
+			opts:   UpgradeOptions{WriteConflicts: true},
+			output: []string{"Wrote " + metaRuleFilePath + " with 1 conflicts"},
+		},
+		{
+			name: "legacy project",
+			setup: func(t *testing.T, project memFS) {
+				delete(project.MapFS, models.TemplateBaseFilePath)
+				for name, content := range legacyTemplateFiles() {
+					setFile(project, name, content)
+				}
+				legacyConfig := legacyTemplateFiles()[models.ConfigFilePath]
+				setFile(project, models.ConfigFilePath, strings.Replace(legacyConfig, `"./node_modules/**"`, `"./node_modules/**", "./dist/**"`, 1))
+			},
+			files: map[string]string{
+				models.ConfigFilePath: strings.Replace(configFileContent, `"./node_modules/**"`, `"./node_modules/**", "./dist/**"`, 1),
+				metaRuleFilePath:      ruleFileContent,
+				claudeAgentPath:       claudeAgentContent(RuntimeNode),
+			},
+			output: []string{"Upgraded " + models.ConfigFilePath, "Upgraded " + claudeAgentPath},
+		},
+		{
+			name: "file without a recorded template",
+			setup: func(t *testing.T, project memFS) {
+				base, _ := readTemplateBase()
+				delete(base.Files, claudeAgentPath)
+				writeTemplateBase(base)
+				setFile(project, claudeAgentPath, "# My agent\n")
+			},
+			files:  map[string]string{claudeAgentPath: "# My agent\n"},
+			output: []string{"no recorded template to merge with", "Skipped. Edit the file by hand, or run with --adopt", "\n1 file was not upgraded\n"},
+		},
+		{
+			name: "adopt",
+			setup: func(t *testing.T, project memFS) {
+				base, _ := readTemplateBase()
+				delete(base.Files, claudeAgentPath)
+				writeTemplateBase(base)
+				setFile(project, claudeAgentPath, "# My agent\n")
+			},
+			opts:   UpgradeOptions{Adopt: true},
+			files:  map[string]string{claudeAgentPath: "# My agent\n"},
+			output: []string{"Recorded the current template, keeping your version", "Everything is up to date"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			project := newInitializedProject(t)
+			test.setup(t, project)
+
+			output, err := captureStdout(t, func() error { return Upgrade(test.opts) })
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range test.output {
+				if !strings.Contains(output, want) {
+					t.Errorf("output = %q, want %q", output, want)
+				}
+			}
+			for name, want := range test.files {
+				if got := string(project.MapFS[name].Data); got != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+			if test.opts.WriteConflicts && !strings.Contains(string(project.MapFS[metaRuleFilePath].Data), utils.ConflictStart) {
+				t.Errorf("%s has no conflict markers", metaRuleFilePath)
+			}
+
+			// Applied changes are recorded, so a second upgrade has nothing to do
+			if test.opts.DryRun || strings.Contains(output, "not upgraded") {
+				return
+			}
+			var base templateBase
+			if err := json.Unmarshal(project.MapFS[models.TemplateBaseFilePath].Data, &base); err != nil {
+				t.Fatal(err)
+			}
+			templates, err := templateFiles(InitOptions{Presets: base.Presets, Agents: base.Agents})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for name, template := range templates {
+				if base.Files[name] != template {
+					t.Errorf("recorded base of %s = %q, want the current template", name, base.Files[name])
+				}
+			}
+			output, err = captureStdout(t, func() error { return Upgrade(UpgradeOptions{}) })
+			if err != nil || !strings.Contains(output, "Everything is up to date") {
+				t.Errorf("second upgrade printed %q, %v, want everything up to date", output, err)
+			}
+		})
+	}
+}
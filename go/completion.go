@@ -0,0 +1,290 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/dirt-rain/code-editor-agent/commands"
+	"github.com/dirt-rain/code-editor-agent/config"
+)
+
+// completionGroups is the argument of `cmd completion` printing the command
+// groups of the config, used by the completion scripts
+const completionGroups = "groups"
+
+// completionFlags lists the flags of a command for completion
+type completionFlags struct {
+	names     []string // with the leading --
+	values    []string // flags taking a value
+	summaries map[string]string
+}
+
+// globalFlagNames are the flags registered by globalOptions.register
+var globalFlagNames = map[string]bool{"root": true, "config": true, "format": true, "quiet": true}
+
+// collectFlags returns the flags of a command, or the global flags if c is nil
+func collectFlags(c *command) *completionFlags {
+	flags := flag.NewFlagSet("", flag.ContinueOnError)
+	global := &globalOptions{}
+	if c != nil {
+		c.setup(flags, global)
+	}
+	global.register(flags)
+
+	result := &completionFlags{summaries: make(map[string]string)}
+	flags.VisitAll(func(f *flag.Flag) {
+		if c != nil && globalFlagNames[f.Name] {
+			return
+		}
+		name := "--" + f.Name
+		result.names = append(result.names, name)
+		if boolFlag, ok := f.Value.(interface{ IsBoolFlag() bool }); !ok || !boolFlag.IsBoolFlag() {
+			result.values = append(result.values, name)
+		}
+		// Drop defaults and value lists from the description
+		summary, _, _ := strings.Cut(f.Usage, " (")
+		summary, _, _ = strings.Cut(summary, ": ")
+		result.summaries[f.Name] = summary
+	})
+	result.names = append(result.names, "--help")
+	return result
+}
+
+// completionScript returns the completion script for a shell
+func completionScript(shell string) (string, error) {
+	switch shell {
+	case "bash":
+		return bashCompletion(), nil
+	case "zsh":
+		return zshCompletion(), nil
+	case "fish":
+		return fishCompletion(), nil
+	default:
+		return "", fmt.Errorf("Unknown shell '%s'. Expected 'bash', 'zsh' or 'fish'.", shell)
+	}
+}
+
+// commandNames returns the names of the commands, separated by spaces
+func commandNames() string {
+	names := []string{}
+	for _, c := range commandList() {
+		names = append(names, c.name)
+	}
+	return strings.Join(names, " ")
+}
+
+// flagsTakingValues returns the flags of every command taking a value,
+// separated by |, for a shell case pattern
+func flagsTakingValues() string {
+	seen := map[string]bool{}
+	values := []string{}
+	for _, c := range append([]*command{nil}, commandList()...) {
+		for _, name := range collectFlags(c).values {
+			if !seen[name] {
+				seen[name] = true
+				values = append(values, name)
+			}
+		}
+	}
+	return strings.Join(values, "|")
+}
+
+func bashCompletion() string {
+	var sb strings.Builder
+	sb.WriteString(`# bash completion for code-editor-agent
+# Install: source <(code-editor-agent cmd completion bash)
+
+_code_editor_agent() {
+    local cur="${COMP_WORDS[COMP_CWORD]}" prev="${COMP_WORDS[COMP_CWORD-1]}"
+    local first= command= i
+    for ((i = 1; i < COMP_CWORD; i++)); do
+        case "${COMP_WORDS[i]}" in
+`)
+	fmt.Fprintf(&sb, "        %s) ((i++)) ;;\n", flagsTakingValues())
+	sb.WriteString(`        --) [[ -z $first ]] && first=-- ;;
+        -*) ;;
+        *)
+            if [[ -z $first ]]; then
+                first="${COMP_WORDS[i]}"
+            elif [[ $first == cmd && -z $command ]]; then
+                command="${COMP_WORDS[i]}"
+            fi
+            ;;
+        esac
+    done
+
+    case "$prev" in
+    --format) COMPREPLY=($(compgen -W "text json table html" -- "$cur")); return ;;
+    --root) COMPREPLY=($(compgen -d -- "$cur")); return ;;
+    --config) COMPREPLY=($(compgen -f -- "$cur")); return ;;
+`)
+	sb.WriteString("    --to)\n        case \"$command\" in\n")
+	fmt.Fprintf(&sb, "        config) COMPREPLY=($(compgen -W \"%s\" -- \"$cur\")) ;;\n", strings.Join(config.Formats, " "))
+	fmt.Fprintf(&sb, "        export) COMPREPLY=($(compgen -W \"%s\" -- \"$cur\")) ;;\n", strings.Join(commands.ExportTargets, " "))
+	sb.WriteString("        esac\n        return ;;\n")
+	sb.WriteString(`    esac
+
+    local groups
+    if [[ $cur == -* ]]; then
+        case "$command" in
+`)
+	for _, c := range commandList() {
+		fmt.Fprintf(&sb, "        %s) COMPREPLY=($(compgen -W \"%s\" -- \"$cur\")) ;;\n", c.name, strings.Join(collectFlags(c).names, " "))
+	}
+	fmt.Fprintf(&sb, "        *) COMPREPLY=($(compgen -W \"%s\" -- \"$cur\")) ;;\n", strings.Join(collectFlags(nil).names, " "))
+	sb.WriteString(`        esac
+    elif [[ -z $first ]]; then
+        groups="$("${COMP_WORDS[0]}" cmd completion groups 2>/dev/null)"
+        COMPREPLY=($(compgen -W "cmd $groups" -- "$cur") $(compgen -f -- "$cur"))
+    elif [[ $first == cmd && -z $command ]]; then
+`)
+	fmt.Fprintf(&sb, "        COMPREPLY=($(compgen -W \"%s\" -- \"$cur\"))\n", commandNames())
+	sb.WriteString(`    elif [[ $command == completion ]]; then
+        COMPREPLY=($(compgen -W "bash zsh fish" -- "$cur"))
+    elif [[ $command == list ]]; then
+`)
+	fmt.Fprintf(&sb, "        COMPREPLY=($(compgen -W \"%s\" -- \"$cur\"))\n", strings.Join(commands.ListKinds, " "))
+	sb.WriteString(`    elif [[ $command == config ]]; then
+`)
+	fmt.Fprintf(&sb, "        COMPREPLY=($(compgen -W \"%s\" -- \"$cur\"))\n", strings.Join(commands.ConfigActions, " "))
+	sb.WriteString(`    elif [[ $command == explain || $command == hook ]]; then
+        groups="$("${COMP_WORDS[0]}" cmd completion groups 2>/dev/null)"
+        COMPREPLY=($(compgen -W "$groups" -- "$cur") $(compgen -f -- "$cur"))
+    else
+        COMPREPLY=($(compgen -f -- "$cur"))
+    fi
+}
+
+complete -o filenames -F _code_editor_agent code-editor-agent
+`)
+	return sb.String()
+}
+
+func zshCompletion() string {
+	var sb strings.Builder
+	sb.WriteString(`#compdef code-editor-agent
+# zsh completion for code-editor-agent
+# Install: source <(code-editor-agent cmd completion zsh), after compinit
+
+_code_editor_agent() {
+    local first= command= i
+    for ((i = 2; i < CURRENT; i++)); do
+        case "${words[i]}" in
+`)
+	fmt.Fprintf(&sb, "        %s) ((i++)) ;;\n", flagsTakingValues())
+	sb.WriteString(`        --) [[ -z $first ]] && first=-- ;;
+        -*) ;;
+        *)
+            if [[ -z $first ]]; then
+                first="${words[i]}"
+            elif [[ $first == cmd && -z $command ]]; then
+                command="${words[i]}"
+            fi
+            ;;
+        esac
+    done
+
+    case "${words[CURRENT-1]}" in
+    --format) compadd text json table html; return ;;
+    --root) _directories; return ;;
+    --config) _files; return ;;
+`)
+	sb.WriteString("    --to)\n        case \"$command\" in\n")
+	fmt.Fprintf(&sb, "        config) compadd %s ;;\n", strings.Join(config.Formats, " "))
+	fmt.Fprintf(&sb, "        export) compadd %s ;;\n", strings.Join(commands.ExportTargets, " "))
+	sb.WriteString("        esac\n        return ;;\n")
+	sb.WriteString(`    esac
+
+    if [[ ${words[CURRENT]} == -* ]]; then
+        case "$command" in
+`)
+	for _, c := range commandList() {
+		fmt.Fprintf(&sb, "        %s) compadd -- %s ;;\n", c.name, strings.Join(collectFlags(c).names, " "))
+	}
+	fmt.Fprintf(&sb, "        *) compadd -- %s ;;\n", strings.Join(collectFlags(nil).names, " "))
+	sb.WriteString(`        esac
+    elif [[ -z $first ]]; then
+        compadd cmd ${(f)"$(${words[1]} cmd completion groups 2>/dev/null)"}
+        _files
+    elif [[ $first == cmd && -z $command ]]; then
+`)
+	fmt.Fprintf(&sb, "        compadd %s\n", commandNames())
+	sb.WriteString(`    elif [[ $command == completion ]]; then
+        compadd bash zsh fish
+    elif [[ $command == list ]]; then
+`)
+	fmt.Fprintf(&sb, "        compadd %s\n", strings.Join(commands.ListKinds, " "))
+	sb.WriteString(`    elif [[ $command == config ]]; then
+`)
+	fmt.Fprintf(&sb, "        compadd %s\n", strings.Join(commands.ConfigActions, " "))
+	sb.WriteString(`    elif [[ $command == explain || $command == hook ]]; then
+        compadd ${(f)"$(${words[1]} cmd completion groups 2>/dev/null)"}
+        _files
+    else
+        _files
+    fi
+}
+
+compdef _code_editor_agent code-editor-agent
+`)
+	return sb.String()
+}
+
+func fishCompletion() string {
+	var sb strings.Builder
+	sb.WriteString(`# fish completion for code-editor-agent
+# Install: code-editor-agent cmd completion fish > ~/.config/fish/completions/code-editor-agent.fish
+
+function __code_editor_agent_groups
+    code-editor-agent cmd completion groups 2>/dev/null
+end
+
+`)
+	writeFishFlags(&sb, "", collectFlags(nil))
+	sb.WriteString("complete -c code-editor-agent -l format -x -a 'text json table html'\n")
+	sb.WriteString("complete -c code-editor-agent -n __fish_use_subcommand -a cmd -d 'Run a command'\n")
+	sb.WriteString("complete -c code-editor-agent -n __fish_use_subcommand -a '(__code_editor_agent_groups)' -d 'Command group'\n")
+
+	noCommand := fmt.Sprintf("__fish_seen_subcommand_from cmd; and not __fish_seen_subcommand_from %s", commandNames())
+	for _, c := range commandList() {
+		fmt.Fprintf(&sb, "complete -c code-editor-agent -n '%s' -f -a %s -d %s\n", noCommand, c.name, fishQuote(c.summary))
+	}
+	for _, c := range commandList() {
+		writeFishFlags(&sb, "__fish_seen_subcommand_from cmd; and __fish_seen_subcommand_from "+c.name, collectFlags(c))
+	}
+	sb.WriteString("complete -c code-editor-agent -n '__fish_seen_subcommand_from cmd; and __fish_seen_subcommand_from completion' -f -a 'bash zsh fish'\n")
+	fmt.Fprintf(&sb, "complete -c code-editor-agent -n '__fish_seen_subcommand_from cmd; and __fish_seen_subcommand_from list' -f -a '%s'\n", strings.Join(commands.ListKinds, " "))
+	fmt.Fprintf(&sb, "complete -c code-editor-agent -n '__fish_seen_subcommand_from cmd; and __fish_seen_subcommand_from config' -f -a '%s'\n", strings.Join(commands.ConfigActions, " "))
+	fmt.Fprintf(&sb, "complete -c code-editor-agent -n '__fish_seen_subcommand_from cmd; and __fish_seen_subcommand_from config' -l to -x -a '%s'\n", strings.Join(config.Formats, " "))
+	fmt.Fprintf(&sb, "complete -c code-editor-agent -n '__fish_seen_subcommand_from cmd; and __fish_seen_subcommand_from export' -l to -x -a '%s'\n", strings.Join(commands.ExportTargets, " "))
+	sb.WriteString("complete -c code-editor-agent -n '__fish_seen_subcommand_from cmd; and __fish_seen_subcommand_from explain hook' -a '(__code_editor_agent_groups)' -d 'Command group'\n")
+	return sb.String()
+}
+
+// writeFishFlags writes a complete line per flag, under the given condition
+func writeFishFlags(sb *strings.Builder, condition string, flags *completionFlags) {
+	takesValue := make(map[string]bool)
+	for _, name := range flags.values {
+		takesValue[name] = true
+	}
+	for _, name := range flags.names {
+		if name == "--help" || name == "--format" {
+			continue
+		}
+		sb.WriteString("complete -c code-editor-agent")
+		if condition != "" {
+			fmt.Fprintf(sb, " -n '%s'", condition)
+		}
+		fmt.Fprintf(sb, " -l %s", strings.TrimPrefix(name, "--"))
+		if takesValue[name] {
+			sb.WriteString(" -r")
+		}
+		fmt.Fprintf(sb, " -d %s\n", fishQuote(flags.summaries[strings.TrimPrefix(name, "--")]))
+	}
+}
+
+// fishQuote quotes s as a single-quoted fish string
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
@@ -0,0 +1,344 @@
+package conditions
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// Condition is a parsed `when` block from rule front matter.
+// All non-empty fields of a single Condition must hold.
+type Condition struct {
+	FileExists []string
+	Env        map[string]*string // nil value means "set and non-empty"
+	GitBranch  []string
+	All        []*Condition
+	Any        []*Condition
+	Not        *Condition
+}
+
+// Context provides the repository state conditions are evaluated against
+type Context struct {
+	LookupEnv  func(name string) (string, bool)
+	FileExists func(path string) bool
+	GitBranch  func() (string, error)
+}
+
+// DefaultContext evaluates conditions against the current working directory
+// and process environment
+func DefaultContext() *Context {
+	branchLoaded := false
+	var branch string
+	var branchErr error
+	return &Context{
+		LookupEnv: os.LookupEnv,
+		FileExists: func(path string) bool {
+			_, err := os.Stat(path)
+			return err == nil
+		},
+		GitBranch: func() (string, error) {
+			if !branchLoaded {
+				branch, branchErr = ReadGitBranch(".")
+				branchLoaded = true
+			}
+			return branch, branchErr
+		},
+	}
+}
+
+// NewContext evaluates conditions against the project in fsys, whose git
+// repository is at root on disk, and the process environment
+func NewContext(fsys fs.FS, root string) *Context {
+	ctx := DefaultContext()
+	ctx.FileExists = func(filePath string) bool {
+		_, err := fs.Stat(fsys, path.Clean(filepath.ToSlash(filePath)))
+		return err == nil
+	}
+	branchLoaded := false
+	var branch string
+	var branchErr error
+	ctx.GitBranch = func() (string, error) {
+		if !branchLoaded {
+			branch, branchErr = ReadGitBranch(root)
+			branchLoaded = true
+		}
+		return branch, branchErr
+	}
+	return ctx
+}
+
+// Parse converts a raw `when` value (decoded from YAML or JSON) into a Condition
+func Parse(raw interface{}) (*Condition, error) {
+	m, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("'when' must be an object")
+	}
+	if len(m) == 0 {
+		return nil, fmt.Errorf("'when' must not be empty")
+	}
+
+	cond := &Condition{}
+	for key, value := range m {
+		switch key {
+		case "fileExists":
+			paths, err := toStringList(value)
+			if err != nil {
+				return nil, fmt.Errorf("'fileExists' must be a string or array of strings")
+			}
+			cond.FileExists = paths
+		case "env":
+			env, err := parseEnv(value)
+			if err != nil {
+				return nil, err
+			}
+			cond.Env = env
+		case "gitBranch":
+			branches, err := toStringList(value)
+			if err != nil {
+				return nil, fmt.Errorf("'gitBranch' must be a string or array of strings")
+			}
+			for _, branch := range branches {
+				if !doublestar.ValidatePattern(branch) {
+					return nil, fmt.Errorf("'gitBranch' has invalid glob pattern %q", branch)
+				}
+			}
+			cond.GitBranch = branches
+		case "all", "any":
+			list, ok := value.([]interface{})
+			if !ok || len(list) == 0 {
+				return nil, fmt.Errorf("'%s' must be a non-empty array of conditions", key)
+			}
+			children := make([]*Condition, 0, len(list))
+			for _, item := range list {
+				child, err := Parse(item)
+				if err != nil {
+					return nil, err
+				}
+				children = append(children, child)
+			}
+			if key == "all" {
+				cond.All = children
+			} else {
+				cond.Any = children
+			}
+		case "not":
+			child, err := Parse(value)
+			if err != nil {
+				return nil, err
+			}
+			cond.Not = child
+		default:
+			return nil, fmt.Errorf("unknown condition '%s'", key)
+		}
+	}
+	return cond, nil
+}
+
+// parseEnv accepts either a variable name (or list of names) that must be
+// set and non-empty, or an object mapping names to their expected values
+func parseEnv(value interface{}) (map[string]*string, error) {
+	env := make(map[string]*string)
+	if m, ok := value.(map[string]interface{}); ok {
+		for name, expected := range m {
+			if expected == nil {
+				env[name] = nil
+				continue
+			}
+			str, ok := expected.(string)
+			if !ok {
+				str = fmt.Sprint(expected)
+			}
+			env[name] = &str
+		}
+		return env, nil
+	}
+
+	names, err := toStringList(value)
+	if err != nil {
+		return nil, fmt.Errorf("'env' must be a string, array of strings or object")
+	}
+	for _, name := range names {
+		env[name] = nil
+	}
+	return env, nil
+}
+
+func toStringList(value interface{}) ([]string, error) {
+	switch v := value.(type) {
+	case string:
+		return []string{v}, nil
+	case []interface{}:
+		result := make([]string, len(v))
+		for i, item := range v {
+			str, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("not a string")
+			}
+			result[i] = str
+		}
+		return result, nil
+	default:
+		return nil, fmt.Errorf("not a string")
+	}
+}
+
+// EnvNames returns the environment variables the condition reads, sorted
+func (c *Condition) EnvNames() []string {
+	seen := make(map[string]bool)
+	c.collectEnvNames(seen)
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (c *Condition) collectEnvNames(seen map[string]bool) {
+	if c == nil {
+		return
+	}
+	for name := range c.Env {
+		seen[name] = true
+	}
+	for _, sub := range append(append([]*Condition{}, c.All...), c.Any...) {
+		sub.collectEnvNames(seen)
+	}
+	c.Not.collectEnvNames(seen)
+}
+
+// Evaluate reports whether the condition holds, together with a
+// human-readable explanation of how it was decided
+func (c *Condition) Evaluate(ctx *Context) (bool, string) {
+	parts := []string{}
+	result := true
+
+	for _, path := range c.FileExists {
+		ok := ctx.FileExists(path)
+		parts = append(parts, fmt.Sprintf("fileExists %q = %t", path, ok))
+		result = result && ok
+	}
+
+	names := make([]string, 0, len(c.Env))
+	for name := range c.Env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		actual, set := ctx.LookupEnv(name)
+		var ok bool
+		if expected := c.Env[name]; expected == nil {
+			ok = set && actual != ""
+			parts = append(parts, fmt.Sprintf("env %s is set = %t", name, ok))
+		} else {
+			ok = set && actual == *expected
+			parts = append(parts, fmt.Sprintf("env %s == %q = %t", name, *expected, ok))
+		}
+		result = result && ok
+	}
+
+	if len(c.GitBranch) > 0 {
+		branch, err := ctx.GitBranch()
+		ok := false
+		if err == nil {
+			for _, pattern := range c.GitBranch {
+				if matched, _ := doublestar.Match(pattern, branch); matched {
+					ok = true
+					break
+				}
+			}
+		}
+		current := fmt.Sprintf("current: %q", branch)
+		if err != nil {
+			current = fmt.Sprintf("error: %v", err)
+		}
+		parts = append(parts, fmt.Sprintf("gitBranch %s (%s) = %t", strings.Join(quoteAll(c.GitBranch), "|"), current, ok))
+		result = result && ok
+	}
+
+	if len(c.All) > 0 {
+		ok := true
+		children := []string{}
+		for _, child := range c.All {
+			childOk, reason := child.Evaluate(ctx)
+			ok = ok && childOk
+			children = append(children, reason)
+		}
+		parts = append(parts, fmt.Sprintf("all(%s) = %t", strings.Join(children, ", "), ok))
+		result = result && ok
+	}
+
+	if len(c.Any) > 0 {
+		ok := false
+		children := []string{}
+		for _, child := range c.Any {
+			childOk, reason := child.Evaluate(ctx)
+			ok = ok || childOk
+			children = append(children, reason)
+		}
+		parts = append(parts, fmt.Sprintf("any(%s) = %t", strings.Join(children, ", "), ok))
+		result = result && ok
+	}
+
+	if c.Not != nil {
+		childOk, reason := c.Not.Evaluate(ctx)
+		parts = append(parts, fmt.Sprintf("not(%s) = %t", reason, !childOk))
+		result = result && !childOk
+	}
+
+	if len(parts) == 1 {
+		return result, parts[0]
+	}
+	return result, fmt.Sprintf("(%s) = %t", strings.Join(parts, " and "), result)
+}
+
+func quoteAll(values []string) []string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = fmt.Sprintf("%q", v)
+	}
+	return quoted
+}
+
+// ReadGitBranch returns the branch checked out in the repository at root by
+// reading .git/HEAD directly. It returns an empty string for a detached HEAD.
+func ReadGitBranch(root string) (string, error) {
+	gitDir := filepath.Join(root, ".git")
+	info, err := os.Stat(gitDir)
+	if err != nil {
+		return "", fmt.Errorf("not a git repository")
+	}
+
+	// Worktrees and submodules use a .git file pointing at the real directory
+	if !info.IsDir() {
+		content, err := os.ReadFile(gitDir)
+		if err != nil {
+			return "", err
+		}
+		line := strings.TrimSpace(string(content))
+		if !strings.HasPrefix(line, "gitdir:") {
+			return "", fmt.Errorf("unrecognized .git file")
+		}
+		gitDir = strings.TrimSpace(strings.TrimPrefix(line, "gitdir:"))
+		if !filepath.IsAbs(gitDir) {
+			gitDir = filepath.Join(root, gitDir)
+		}
+	}
+
+	head, err := os.ReadFile(filepath.Join(gitDir, "HEAD"))
+	if err != nil {
+		return "", err
+	}
+	ref := strings.TrimSpace(string(head))
+	if !strings.HasPrefix(ref, "ref:") {
+		return "", nil
+	}
+	ref = strings.TrimSpace(strings.TrimPrefix(ref, "ref:"))
+	return strings.TrimPrefix(ref, "refs/heads/"), nil
+}
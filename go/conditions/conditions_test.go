@@ -0,0 +1,185 @@
+package conditions
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	"gopkg.in/yaml.v3"
+)
+
+// parseYAML parses a `when` block written in YAML
+func parseYAML(t *testing.T, when string) (*Condition, error) {
+	t.Helper()
+	var raw interface{}
+	if err := yaml.Unmarshal([]byte(when), &raw); err != nil {
+		t.Fatalf("invalid YAML %q: %v", when, err)
+	}
+	return Parse(raw)
+}
+
+// testContext has the files, environment and branch of a test repository
+func testContext(branch string) *Context {
+	env := map[string]string{"CI": "true", "EMPTY": "", "STAGE": "prod"}
+	files := map[string]bool{"go.mod": true, "web/package.json": true}
+	return &Context{
+		LookupEnv: func(name string) (string, bool) {
+			value, ok := env[name]
+			return value, ok
+		},
+		FileExists: func(path string) bool { return files[path] },
+		GitBranch: func() (string, error) {
+			if branch == "" {
+				return "", errors.New("not a git repository")
+			}
+			return branch, nil
+		},
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	tests := []struct {
+		when   string
+		branch string
+		want   bool
+	}{
+		{when: "fileExists: go.mod", want: true},
+		{when: "fileExists: [go.mod, missing.txt]", want: false},
+		{when: "env: CI", want: true},
+		{when: "env: EMPTY", want: false},
+		{when: "env: UNSET", want: false},
+		{when: "env: {STAGE: prod}", want: true},
+		{when: "env: {STAGE: dev}", want: false},
+		{when: "env: {EMPTY: \"\"}", want: true},
+		{when: "env: {CI: true}", want: true},
+		{when: "gitBranch: main", branch: "main", want: true},
+		{when: "gitBranch: release/*", branch: "release/1.2", want: true},
+		{when: "gitBranch: [main, develop]", branch: "feature/x", want: false},
+		{when: "gitBranch: main", want: false},
+		{when: "{fileExists: go.mod, env: UNSET}", want: false},
+		{when: "all: [{fileExists: go.mod}, {env: CI}]", want: true},
+		{when: "all: [{fileExists: go.mod}, {env: UNSET}]", want: false},
+		{when: "any: [{env: UNSET}, {fileExists: web/package.json}]", want: true},
+		{when: "any: [{env: UNSET}, {fileExists: missing.txt}]", want: false},
+		{when: "not: {env: CI}", want: false},
+		{when: "not: {any: [{env: UNSET}, {gitBranch: main}]}", branch: "develop", want: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.when, func(t *testing.T) {
+			cond, err := parseYAML(t, test.when)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, reason := cond.Evaluate(testContext(test.branch)); got != test.want {
+				t.Errorf("Evaluate() = %t (%s), want %t", got, reason, test.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateReason(t *testing.T) {
+	tests := []struct {
+		when string
+		want string
+	}{
+		{when: "env: CI", want: "env CI is set = true"},
+		{when: "env: {STAGE: dev}", want: `env STAGE == "dev" = false`},
+		{when: "gitBranch: main", want: `gitBranch "main" (error: not a git repository) = false`},
+		{when: "{fileExists: go.mod, env: UNSET}", want: `(fileExists "go.mod" = true and env UNSET is set = false) = false`},
+		{when: "not: {any: [{env: CI}, {env: UNSET}]}", want: "not(any(env CI is set = true, env UNSET is set = false) = true) = false"},
+	}
+
+	for _, test := range tests {
+		cond, err := parseYAML(t, test.when)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, reason := cond.Evaluate(testContext("")); reason != test.want {
+			t.Errorf("Evaluate(%s) reason = %q, want %q", test.when, reason, test.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, when := range []string{
+		"fileExists",
+		"{}",
+		"unknown: x",
+		"fileExists: {a: b}",
+		"env: 1",
+		"gitBranch: \"[main\"",
+		"all: []",
+		"any: {env: CI}",
+		"not: CI",
+		"all: [{env: CI}, {bogus: 1}]",
+	} {
+		if _, err := parseYAML(t, when); err == nil {
+			t.Errorf("Parse(%s) succeeded, want an error", when)
+		}
+	}
+}
+
+func TestEnvNames(t *testing.T) {
+	cond, err := parseYAML(t, "{env: B, all: [{env: {A: x}}], any: [{fileExists: f}, {not: {env: [C, B]}}]}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := cond.EnvNames(), []string{"A", "B", "C"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("EnvNames() = %v, want %v", got, want)
+	}
+}
+
+func TestNewContextFileExists(t *testing.T) {
+	ctx := NewContext(fstest.MapFS{"web/package.json": {}}, t.TempDir())
+	for path, want := range map[string]bool{
+		"web/package.json":   true,
+		"./web/package.json": true,
+		"package.json":       false,
+	} {
+		if got := ctx.FileExists(path); got != want {
+			t.Errorf("FileExists(%q) = %t, want %t", path, got, want)
+		}
+	}
+}
+
+func TestReadGitBranch(t *testing.T) {
+	writeFile := func(t *testing.T, path, content string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{name: "branch", files: map[string]string{".git/HEAD": "ref: refs/heads/feature/x\n"}, want: "feature/x"},
+		{name: "detached", files: map[string]string{".git/HEAD": "0123456789abcdef\n"}, want: ""},
+		{name: "worktree", files: map[string]string{".git": "gitdir: ../repo/.git/worktrees/w\n", "../repo/.git/worktrees/w/HEAD": "ref: refs/heads/main\n"}, want: "main"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root := filepath.Join(t.TempDir(), "project")
+			for name, content := range test.files {
+				writeFile(t, filepath.Join(root, name), content)
+			}
+			got, err := ReadGitBranch(root)
+			if err != nil || got != test.want {
+				t.Errorf("ReadGitBranch() = %q, %v, want %q", got, err, test.want)
+			}
+		})
+	}
+
+	if _, err := ReadGitBranch(t.TempDir()); err == nil {
+		t.Error("ReadGitBranch() of a directory without .git succeeded, want an error")
+	}
+}
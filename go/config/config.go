@@ -1,42 +1,110 @@
 package config
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
 
 	"github.com/dirt-rain/code-editor-agent/models"
 	"github.com/dirt-rain/code-editor-agent/utils"
-	"github.com/tidwall/jsonc"
 )
 
 var defaultConfig = &models.Config{
 	Exclude: []string{"./node_modules/**"},
 	Agents: map[string]*models.AgentConfig{
 		"code-editor": {
-			RuleFilePattern: "**/*.code-editor-agent.md",
+			RuleFilePattern: "**/{*.code-editor-agent.md,code-editor-agent.md}",
 			CommandGroup:    nil,
 		},
 	},
 }
 
-// LoadConfig loads and validates the configuration file
+// FilePath is the configuration file read by LoadConfig, overridable with
+// --config. Left to models.ConfigFilePath, the file is found by Detect.
+var FilePath = models.ConfigFilePath
+
+// FilePaths are the configuration files looked up by Detect, one per format
+var FilePaths = []string{models.ConfigFilePath, models.ConfigYAMLFilePath, models.ConfigTOMLFilePath}
+
+// Detect returns the configuration file of the project in fsys among
+// FilePaths, or models.ConfigFilePath if there is none
+func Detect(fsys fs.FS) (string, error) {
+	found := []string{}
+	for _, name := range FilePaths {
+		if _, err := fs.Stat(fsys, name); err == nil {
+			found = append(found, name)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return models.ConfigFilePath, nil
+	case 1:
+		return found[0], nil
+	}
+	return "", fmt.Errorf("Multiple configuration files found: %s. Keep only one of them.", strings.Join(found, ", "))
+}
+
+// Resolve returns the configuration file read by Load: FilePath if given with
+// --config, else the file found by Detect
+func Resolve(fsys fs.FS) (string, error) {
+	if FilePath != models.ConfigFilePath {
+		return FilePath, nil
+	}
+	return Detect(fsys)
+}
+
+// LoadConfig loads and validates the configuration file of the project in
+// the current directory
 func LoadConfig() (*models.Config, error) {
-	raw, err := utils.ReadFileNoThrowOnENOENT(models.ConfigFilePath)
+	return Load(os.DirFS("."))
+}
+
+// Load loads and validates the configuration file of the project in fsys. A
+// FilePath outside the project, given with --config, is read from disk.
+func Load(fsys fs.FS) (*models.Config, error) {
+	filePath, err := Resolve(fsys)
 	if err != nil {
 		return nil, err
 	}
+	raw, err := ReadFile(fsys, filePath)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(raw, filePath)
+}
 
+// ReadFile reads the configuration file filePath of the project in fsys, from
+// disk if it is outside the project. It returns nil if the file doesn't exist.
+func ReadFile(fsys fs.FS, filePath string) ([]byte, error) {
+	var raw []byte
+	var err error
+	if name := path.Clean(filepath.ToSlash(filePath)); fs.ValidPath(name) {
+		raw, err = fs.ReadFile(fsys, name)
+	} else {
+		raw, err = os.ReadFile(filePath)
+	}
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	return raw, nil
+}
+
+// Parse validates the content of the configuration file name, in the format
+// of its extension, returning the default configuration if raw is nil
+func Parse(raw []byte, name string) (*models.Config, error) {
 	// Return default config if file doesn't exist
 	if raw == nil {
 		return defaultConfig, nil
 	}
 
-	// Parse JSONC (JSON with comments and trailing commas)
-	jsonBytes := jsonc.ToJSON(raw)
-
-	var result map[string]interface{}
-	if err := json.Unmarshal(jsonBytes, &result); err != nil {
-		return nil, fmt.Errorf("failed to parse %s: %w", models.ConfigFilePath, err)
+	result, err := decode(raw, name)
+	if err != nil {
+		return nil, err
 	}
 
 	config := &models.Config{
@@ -47,7 +115,7 @@ func LoadConfig() (*models.Config, error) {
 	// Parse exclude
 	if excludeVal, ok := result["exclude"]; ok {
 		exclude, err := utils.NormalizeToStringArray(excludeVal,
-			fmt.Sprintf("`%s` 'exclude' property must be an array of strings.", models.ConfigFilePath))
+			fmt.Sprintf("`%s` 'exclude' property must be an array of strings.", name))
 		if err != nil {
 			return nil, err
 		}
@@ -58,7 +126,7 @@ func LoadConfig() (*models.Config, error) {
 	if agentsVal, ok := result["agents"]; ok {
 		agentsMap, ok := agentsVal.(map[string]interface{})
 		if !ok {
-			return nil, fmt.Errorf("`%s` 'agents' property must be an object.", models.ConfigFilePath)
+			return nil, fmt.Errorf("`%s` 'agents' property must be an object.", name)
 		}
 
 		for agentName, agentVal := range agentsMap {
@@ -98,12 +166,60 @@ func LoadConfig() (*models.Config, error) {
 				references = refs
 			}
 
+			// Parse directoryRules
+			var directoryRules string
+			if modeVal, ok := agentConfigMap["directoryRules"]; ok {
+				mode, ok := modeVal.(string)
+				if !ok || (mode != models.DirectoryRulesNearest && mode != models.DirectoryRulesAllAncestors) {
+					return nil, fmt.Errorf("Agent '%s' 'directoryRules' must be \"%s\" or \"%s\".", agentName, models.DirectoryRulesNearest, models.DirectoryRulesAllAncestors)
+				}
+				directoryRules = mode
+			}
+
+			// Parse claudeAgent
+			var claudeAgent *models.ClaudeAgentConfig
+			if claudeAgentVal, ok := agentConfigMap["claudeAgent"]; ok {
+				var err error
+				claudeAgent, err = parseClaudeAgent(agentName, claudeAgentVal)
+				if err != nil {
+					return nil, err
+				}
+			}
+
 			config.Agents[agentName] = &models.AgentConfig{
 				RuleFilePattern: ruleFilePattern,
 				CommandGroup:    commandGroup,
 				References:      references,
+				DirectoryRules:  directoryRules,
+				ClaudeAgent:     claudeAgent,
+			}
+		}
+	}
+
+	// Parse variables
+	if variablesVal, ok := result["variables"]; ok {
+		variablesMap, ok := variablesVal.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("`%s` 'variables' property must be an object.", name)
+		}
+
+		config.Variables = make(map[string]string, len(variablesMap))
+		for variableName, value := range variablesMap {
+			str, ok := value.(string)
+			if !ok {
+				return nil, fmt.Errorf("`%s` variable '%s' must be a string.", name, variableName)
 			}
+			config.Variables[variableName] = str
+		}
+	}
+
+	// Parse usageLog
+	if usageLogVal, ok := result["usageLog"]; ok {
+		usageLog, ok := usageLogVal.(bool)
+		if !ok {
+			return nil, fmt.Errorf("`%s` 'usageLog' property must be a boolean.", name)
 		}
+		config.UsageLog = usageLog
 	}
 
 	// If no agents defined, use default
@@ -113,3 +229,70 @@ func LoadConfig() (*models.Config, error) {
 
 	return config, nil
 }
+
+// claudeAgentNamePattern is the name format Claude Code accepts for agents
+var claudeAgentNamePattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// parseClaudeAgent parses the claudeAgent block of an agent
+func parseClaudeAgent(agentName string, value interface{}) (*models.ClaudeAgentConfig, error) {
+	valueMap, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("Agent '%s' 'claudeAgent' must be an object.", agentName)
+	}
+
+	claudeAgent := &models.ClaudeAgentConfig{}
+	stringFields := map[string]*string{
+		"name":         &claudeAgent.Name,
+		"description":  &claudeAgent.Description,
+		"model":        &claudeAgent.Model,
+		"color":        &claudeAgent.Color,
+		"instructions": &claudeAgent.Instructions,
+	}
+	for field, target := range stringFields {
+		if fieldVal, ok := valueMap[field]; ok {
+			str, ok := fieldVal.(string)
+			if !ok {
+				return nil, fmt.Errorf("Agent '%s' 'claudeAgent.%s' must be a string.", agentName, field)
+			}
+			*target = str
+		}
+	}
+
+	if toolsVal, ok := valueMap["tools"]; ok {
+		tools, err := utils.NormalizeToStringArray(toolsVal,
+			fmt.Sprintf("Agent '%s' 'claudeAgent.tools' must be a string or an array of strings.", agentName))
+		if err != nil {
+			return nil, err
+		}
+		claudeAgent.Tools = tools
+	}
+
+	name := claudeAgent.Name
+	if name == "" {
+		name = agentName
+	}
+	if !claudeAgentNamePattern.MatchString(name) {
+		return nil, fmt.Errorf("Agent '%s' Claude agent name '%s' must contain only lowercase letters, digits and hyphens.", agentName, name)
+	}
+
+	return claudeAgent, nil
+}
+
+// FindAgentByCommandGroup returns the name of the agent with the given
+// commandGroup, where nil means commandGroup: null
+func FindAgentByCommandGroup(cfg *models.Config, group *string) (string, error) {
+	for agentName, agentConfig := range cfg.Agents {
+		// Compare nullable strings
+		if group == nil && agentConfig.CommandGroup == nil {
+			return agentName, nil
+		}
+		if group != nil && agentConfig.CommandGroup != nil && *group == *agentConfig.CommandGroup {
+			return agentName, nil
+		}
+	}
+
+	if group == nil {
+		return "", fmt.Errorf("No agent found with commandGroup: null")
+	}
+	return "", fmt.Errorf("No agent found with commandGroup: %s", *group)
+}
@@ -0,0 +1,121 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/dirt-rain/code-editor-agent/models"
+)
+
+const testJSONC = `{
+  "exclude": ["./node_modules/**", "./dist/**"],
+  "agents": {
+    // Default agent
+    "code-editor": {
+      "ruleFilePattern": "**/*.code-editor-agent.md",
+      "commandGroup": null,
+    },
+    "code-reviewer": {
+      "ruleFilePattern": "**/*.code-reviewer.md",
+      "commandGroup": "reviewer",
+      "references": ["code-editor"],
+    },
+  },
+}
+`
+
+const testYAML = `exclude:
+  - ./node_modules/**
+  - ./dist/**
+agents:
+  # Default agent
+  code-editor:
+    ruleFilePattern: "**/*.code-editor-agent.md"
+    commandGroup: null
+  code-reviewer:
+    ruleFilePattern: "**/*.code-reviewer.md"
+    commandGroup: reviewer
+    references: [code-editor]
+`
+
+// TOML has no null, agents without commandGroup have none
+const testTOML = `exclude = ["./node_modules/**", "./dist/**"]
+
+# Default agent
+[agents.code-editor]
+ruleFilePattern = "**/*.code-editor-agent.md"
+
+[agents.code-reviewer]
+ruleFilePattern = "**/*.code-reviewer.md"
+commandGroup = "reviewer"
+references = ["code-editor"]
+`
+
+func TestParseFormats(t *testing.T) {
+	want, err := Parse([]byte(testJSONC), models.ConfigFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		models.ConfigYAMLFilePath: testYAML,
+		models.ConfigTOMLFilePath: testTOML,
+	} {
+		got, err := Parse([]byte(content), name)
+		if err != nil {
+			t.Fatalf("Parse(%s) error = %v", name, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Parse(%s) = %#v, want %#v", name, got, want)
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	sources := map[string]string{
+		models.ConfigFilePath:     testJSONC,
+		models.ConfigYAMLFilePath: testYAML,
+		models.ConfigTOMLFilePath: testTOML,
+	}
+	want, err := Parse([]byte(testJSONC), models.ConfigFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for source, content := range sources {
+		for _, format := range Formats {
+			converted, err := Convert([]byte(content), source, format)
+			if err != nil {
+				t.Fatalf("Convert(%s, %s) error = %v", source, format, err)
+			}
+			got, err := Parse(converted, FilePathOf(format))
+			if err != nil {
+				t.Fatalf("Parse of %s converted to %s error = %v\n%s", source, format, err, converted)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("%s converted to %s = %#v, want %#v", source, format, got, want)
+			}
+		}
+	}
+}
+
+func TestDetect(t *testing.T) {
+	fsys := fstest.MapFS{}
+	if got, err := Detect(fsys); err != nil || got != models.ConfigFilePath {
+		t.Errorf("Detect() = %q, %v, want %q", got, err, models.ConfigFilePath)
+	}
+
+	fsys[models.ConfigTOMLFilePath] = &fstest.MapFile{Data: []byte(testTOML)}
+	if got, err := Detect(fsys); err != nil || got != models.ConfigTOMLFilePath {
+		t.Errorf("Detect() = %q, %v, want %q", got, err, models.ConfigTOMLFilePath)
+	}
+
+	fsys[models.ConfigYAMLFilePath] = &fstest.MapFile{Data: []byte(testYAML)}
+	_, err := Detect(fsys)
+	if err == nil || !strings.Contains(err.Error(), "Multiple configuration files found") {
+		t.Errorf("Detect() error = %v, want multiple configuration files", err)
+	}
+	if _, err := Load(fsys); err == nil {
+		t.Error("Load() error = nil, want multiple configuration files")
+	}
+}
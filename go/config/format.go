@@ -0,0 +1,302 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/dirt-rain/code-editor-agent/models"
+	"github.com/dirt-rain/code-editor-agent/toml"
+	"github.com/tidwall/jsonc"
+	"gopkg.in/yaml.v3"
+)
+
+// Configuration file formats
+const (
+	FormatJSONC = "jsonc"
+	FormatYAML  = "yaml"
+	FormatTOML  = "toml"
+)
+
+// Formats lists the configuration file formats
+var Formats = []string{FormatJSONC, FormatYAML, FormatTOML}
+
+// FormatOf returns the format of a configuration file from its extension,
+// JSONC for other extensions
+func FormatOf(name string) string {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".yaml", ".yml":
+		return FormatYAML
+	case ".toml":
+		return FormatTOML
+	}
+	return FormatJSONC
+}
+
+// FilePathOf returns the default configuration file of a format
+func FilePathOf(format string) string {
+	switch format {
+	case FormatYAML:
+		return models.ConfigYAMLFilePath
+	case FormatTOML:
+		return models.ConfigTOMLFilePath
+	}
+	return models.ConfigFilePath
+}
+
+// decode decodes the configuration file name into the values encoding/json
+// decodes from JSON, whatever its format
+func decode(raw []byte, name string) (map[string]interface{}, error) {
+	var jsonBytes []byte
+	switch FormatOf(name) {
+	case FormatYAML, FormatTOML:
+		values, err := orderedValues(raw, name)
+		if err != nil {
+			return nil, err
+		}
+		if jsonBytes, err = marshalJSON(values); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+		}
+	default:
+		// Parse JSONC (JSON with comments and trailing commas)
+		jsonBytes = jsonc.ToJSON(raw)
+	}
+
+	var result map[string]interface{}
+	if err := json.Unmarshal(jsonBytes, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	return result, nil
+}
+
+// Convert converts the content of the configuration file name to format,
+// keeping the order of the keys. Comments are not converted.
+func Convert(raw []byte, name, format string) ([]byte, error) {
+	values, err := orderedValues(raw, name)
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case FormatJSONC:
+		content, err := marshalJSON(values)
+		if err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, content, "", "  "); err != nil {
+			return nil, err
+		}
+		buf.WriteByte('\n')
+		return buf.Bytes(), nil
+
+	case FormatYAML:
+		var buf bytes.Buffer
+		encoder := yaml.NewEncoder(&buf)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(yamlNode(values)); err != nil {
+			return nil, err
+		}
+		if err := encoder.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+
+	case FormatTOML:
+		table, ok := values.(toml.Table)
+		if !ok {
+			return nil, fmt.Errorf("`%s` must contain an object.", name)
+		}
+		return toml.Marshal(table)
+	}
+	return nil, fmt.Errorf("Unknown configuration format '%s'. Expected one of: %s.", format, strings.Join(Formats, ", "))
+}
+
+// orderedValues decodes the configuration file name keeping the order of the
+// keys: objects are toml.Table, arrays []interface{}
+func orderedValues(raw []byte, name string) (interface{}, error) {
+	if FormatOf(name) == FormatTOML {
+		values, err := toml.Parse(string(raw))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+		}
+		table := tableOf(values)
+		nullCommandGroups(table)
+		return table, nil
+	}
+
+	if FormatOf(name) == FormatJSONC {
+		raw = jsonc.ToJSON(raw)
+	}
+	var node yaml.Node
+	if err := yaml.Unmarshal(raw, &node); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	if node.Kind == 0 {
+		// Empty file
+		return nil, nil
+	}
+	return nodeValues(&node)
+}
+
+// tableOf returns a parsed TOML table as toml.Table, in key order
+func tableOf(values map[string]interface{}) toml.Table {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	table := make(toml.Table, 0, len(keys))
+	for _, key := range keys {
+		table = append(table, toml.KeyValue{Key: key, Value: tomlValue(values[key])})
+	}
+	return table
+}
+
+func tomlValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		return tableOf(value)
+	case []interface{}:
+		items := make([]interface{}, len(value))
+		for i, item := range value {
+			items[i] = tomlValue(item)
+		}
+		return items
+	}
+	return value
+}
+
+// nullCommandGroups sets the commandGroup of agents without one to null, as
+// TOML has no null
+func nullCommandGroups(table toml.Table) {
+	for _, entry := range table {
+		agents, ok := entry.Value.(toml.Table)
+		if entry.Key != "agents" || !ok {
+			continue
+		}
+		for i, agent := range agents {
+			agentTable, ok := agent.Value.(toml.Table)
+			if !ok || hasKey(agentTable, "commandGroup") {
+				continue
+			}
+			agents[i].Value = append(agentTable, toml.KeyValue{Key: "commandGroup", Value: nil})
+		}
+	}
+}
+
+func hasKey(table toml.Table, key string) bool {
+	for _, entry := range table {
+		if entry.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// nodeValues returns the values of a YAML node, with objects as toml.Table
+func nodeValues(node *yaml.Node) (interface{}, error) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		return nodeValues(node.Content[0])
+	case yaml.AliasNode:
+		return nodeValues(node.Alias)
+	case yaml.MappingNode:
+		table := make(toml.Table, 0, len(node.Content)/2)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			var key string
+			if err := node.Content[i].Decode(&key); err != nil {
+				return nil, fmt.Errorf("line %d: keys must be strings", node.Content[i].Line)
+			}
+			value, err := nodeValues(node.Content[i+1])
+			if err != nil {
+				return nil, err
+			}
+			table = append(table, toml.KeyValue{Key: key, Value: value})
+		}
+		return table, nil
+	case yaml.SequenceNode:
+		items := make([]interface{}, 0, len(node.Content))
+		for _, item := range node.Content {
+			value, err := nodeValues(item)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, value)
+		}
+		return items, nil
+	}
+	var value interface{}
+	if err := node.Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// marshalJSON writes ordered values as JSON
+func marshalJSON(value interface{}) ([]byte, error) {
+	switch value := value.(type) {
+	case toml.Table:
+		var buf bytes.Buffer
+		buf.WriteByte('{')
+		for i, entry := range value {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			key, _ := json.Marshal(entry.Key)
+			item, err := marshalJSON(entry.Value)
+			if err != nil {
+				return nil, err
+			}
+			buf.Write(key)
+			buf.WriteByte(':')
+			buf.Write(item)
+		}
+		buf.WriteByte('}')
+		return buf.Bytes(), nil
+	case []interface{}:
+		var buf bytes.Buffer
+		buf.WriteByte('[')
+		for i, item := range value {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			content, err := marshalJSON(item)
+			if err != nil {
+				return nil, err
+			}
+			buf.Write(content)
+		}
+		buf.WriteByte(']')
+		return buf.Bytes(), nil
+	}
+	return json.Marshal(value)
+}
+
+// yamlNode returns the YAML node of ordered values
+func yamlNode(value interface{}) *yaml.Node {
+	switch value := value.(type) {
+	case toml.Table:
+		node := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		for _, entry := range value {
+			key := &yaml.Node{}
+			key.SetString(entry.Key)
+			node.Content = append(node.Content, key, yamlNode(entry.Value))
+		}
+		return node
+	case []interface{}:
+		node := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for _, item := range value {
+			node.Content = append(node.Content, yamlNode(item))
+		}
+		return node
+	}
+	node := &yaml.Node{}
+	if err := node.Encode(value); err != nil {
+		node.SetString(fmt.Sprint(value))
+	}
+	return node
+}
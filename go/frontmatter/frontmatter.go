@@ -0,0 +1,242 @@
+// Package frontmatter splits rule files into their front matter and body, and
+// decodes the front matter.
+//
+// YAML front matter is delimited by `---` lines, the closing one may also be
+// `...`. TOML front matter is delimited by `+++` lines. JSON front matter is an
+// object delimited by `;;;` lines, or an object starting the file and ending
+// its line. A UTF-8 byte order mark and CRLF line endings are accepted, and
+// delimiter lines may end with spaces.
+package frontmatter
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dirt-rain/code-editor-agent/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Format is the language of a front matter block
+type Format string
+
+const (
+	YAML Format = "yaml"
+	TOML Format = "toml"
+	JSON Format = "json"
+)
+
+// ErrUnclosed is returned by Split for front matter without closing delimiter
+var ErrUnclosed = errors.New("front matter closing delimiter not found")
+
+const byteOrderMark = "\ufeff"
+
+// Document is a file split into its front matter and body
+type Document struct {
+	// Format is empty for files without front matter
+	Format Format
+	// Data is the front matter between the delimiters
+	Data string
+	// Line is the line number of the first line of Data in the file
+	Line int
+	// Body is the content after the front matter, without leading newlines
+	Body string
+}
+
+// delimiters are the opening delimiter of each format and its closing ones
+var delimiters = []struct {
+	format  Format
+	open    string
+	closing []string
+}{
+	{YAML, "---", []string{"---", "..."}},
+	{TOML, "+++", []string{"+++"}},
+	{JSON, ";;;", []string{";;;"}},
+}
+
+// Split splits content into its front matter and body. Files without front
+// matter are all body; front matter without closing delimiter is ErrUnclosed.
+func Split(content []byte) (*Document, error) {
+	text := strings.TrimPrefix(string(content), byteOrderMark)
+
+	first, rest, _ := cutLine(text)
+	for _, delimiter := range delimiters {
+		if first != delimiter.open {
+			continue
+		}
+
+		for offset := 0; offset < len(rest); {
+			current, after, next := cutLine(rest[offset:])
+			for _, closing := range delimiter.closing {
+				if current == closing {
+					return &Document{
+						Format: delimiter.format,
+						Data:   rest[:offset],
+						Line:   2,
+						Body:   trimLeadingNewlines(after),
+					}, nil
+				}
+			}
+			offset += next
+		}
+		return nil, ErrUnclosed
+	}
+	if strings.HasPrefix(text, "{") {
+		return splitJSONObject(text), nil
+	}
+	return &Document{Body: text}, nil
+}
+
+// cutLine returns the first line of text without its line ending and trailing
+// spaces, the text after it, and the length of the line with its line ending
+func cutLine(text string) (line, rest string, length int) {
+	length = len(text)
+	if idx := strings.IndexByte(text, '\n'); idx >= 0 {
+		length = idx + 1
+	}
+	return strings.TrimRight(text[:length], " \t\r\n"), text[length:], length
+}
+
+func trimLeadingNewlines(text string) string {
+	for {
+		switch {
+		case strings.HasPrefix(text, "\n"):
+			text = text[1:]
+		case strings.HasPrefix(text, "\r\n"):
+			text = text[2:]
+		default:
+			return text
+		}
+	}
+}
+
+// Decode decodes the front matter into v, which is decoded as YAML so its yaml
+// struct tags apply to every format. Errors report line numbers in the file.
+func (d *Document) Decode(v interface{}) error {
+	switch d.Format {
+	case YAML:
+		if err := yaml.Unmarshal([]byte(d.Data), v); err != nil {
+			return fmt.Errorf("failed to parse YAML: %s", d.fileLines(strings.TrimPrefix(err.Error(), "yaml: ")))
+		}
+		return nil
+
+	case TOML, JSON:
+		parse := parseTOML
+		if d.Format == JSON {
+			parse = parseJSON
+		}
+		values, err := parse(d.Data)
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %w", strings.ToUpper(string(d.Format)), d.shiftLine(err))
+		}
+		if err := decodeValues(values, v); err != nil {
+			return fmt.Errorf("failed to parse %s: %w", strings.ToUpper(string(d.Format)), err)
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("unsupported front matter format %q", d.Format)
+	}
+}
+
+// decodeValues decodes values parsed from another format into v, through YAML
+// nodes
+func decodeValues(values map[string]interface{}, v interface{}) error {
+	if err := yamlNode(values).Decode(v); err != nil {
+		// The nodes have no lines
+		return fmt.Errorf("%s", yamlLinePattern.ReplaceAllString(strings.TrimPrefix(err.Error(), "yaml: "), ""))
+	}
+	return nil
+}
+
+// yamlNode returns the YAML node of a parsed value
+func yamlNode(value interface{}) *yaml.Node {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		node := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		for _, key := range keys {
+			node.Content = append(node.Content, yamlNode(key), yamlNode(value[key]))
+		}
+		return node
+	case []interface{}:
+		node := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for _, item := range value {
+			node.Content = append(node.Content, yamlNode(item))
+		}
+		return node
+	case string:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+	case int:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.Itoa(value)}
+	case float64:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!float", Value: yamlFloat(value)}
+	case bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: strconv.FormatBool(value)}
+	default:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}
+	}
+}
+
+func yamlFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return ".inf"
+	case math.IsInf(f, -1):
+		return "-.inf"
+	case math.IsNaN(f):
+		return ".nan"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+var yamlLinePattern = regexp.MustCompile(`line (\d+): `)
+
+// lineError is a syntax error at a line of the front matter
+type lineError struct {
+	line    int
+	message string
+}
+
+func (e *lineError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.line, e.message)
+}
+
+// parseTOML parses TOML front matter, with the errors as lineError
+func parseTOML(data string) (map[string]interface{}, error) {
+	values, err := toml.Parse(data)
+	var tomlErr *toml.Error
+	if errors.As(err, &tomlErr) {
+		return nil, &lineError{line: tomlErr.Line, message: tomlErr.Message}
+	}
+	return values, err
+}
+
+// shiftLine shifts the line of a lineError from the front matter to the file
+func (d *Document) shiftLine(err error) error {
+	var lineErr *lineError
+	if errors.As(err, &lineErr) {
+		return &lineError{line: d.Line + lineErr.line - 1, message: lineErr.message}
+	}
+	return err
+}
+
+// fileLines shifts the line numbers of a YAML error message from the front
+// matter to the file
+func (d *Document) fileLines(message string) string {
+	return yamlLinePattern.ReplaceAllStringFunc(message, func(match string) string {
+		line, err := strconv.Atoi(yamlLinePattern.FindStringSubmatch(match)[1])
+		if err != nil {
+			return match
+		}
+		return fmt.Sprintf("line %d: ", d.Line+line-1)
+	})
+}
@@ -0,0 +1,349 @@
+package frontmatter
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    *Document
+		wantErr error
+	}{
+		{
+			name:    "yaml",
+			content: "---\npatterns: \"**\"\n---\n\n# Rule\n",
+			want:    &Document{Format: YAML, Data: "patterns: \"**\"\n", Line: 2, Body: "# Rule\n"},
+		},
+		{
+			name:    "byte order mark and CRLF",
+			content: "\ufeff---\r\npatterns: \"**\"\r\n---\r\n\r\n# Rule\r\n",
+			want:    &Document{Format: YAML, Data: "patterns: \"**\"\r\n", Line: 2, Body: "# Rule\r\n"},
+		},
+		{
+			name:    "shorter than 8 bytes",
+			content: "---\n---",
+			want:    &Document{Format: YAML, Data: "", Line: 2, Body: ""},
+		},
+		{
+			name:    "four dashes are not a delimiter",
+			content: "---\na: 1\n----\n---\nbody",
+			want:    &Document{Format: YAML, Data: "a: 1\n----\n", Line: 2, Body: "body"},
+		},
+		{
+			name:    "horizontal rule is not front matter",
+			content: "----\n# Rule\n",
+			want:    &Document{Body: "----\n# Rule\n"},
+		},
+		{
+			name:    "dashes in a block scalar",
+			content: "---\ndescription: |\n  ---\n  text\n---\nbody",
+			want:    &Document{Format: YAML, Data: "description: |\n  ---\n  text\n", Line: 2, Body: "body"},
+		},
+		{
+			name:    "dots close yaml",
+			content: "---\na: 1\n...\nbody",
+			want:    &Document{Format: YAML, Data: "a: 1\n", Line: 2, Body: "body"},
+		},
+		{
+			name:    "trailing spaces after delimiters",
+			content: "--- \na: 1\n---\t\nbody",
+			want:    &Document{Format: YAML, Data: "a: 1\n", Line: 2, Body: "body"},
+		},
+		{
+			name:    "toml",
+			content: "+++\npatterns = \"**\"\n+++\n# Rule\n",
+			want:    &Document{Format: TOML, Data: "patterns = \"**\"\n", Line: 2, Body: "# Rule\n"},
+		},
+		{
+			name:    "json",
+			content: ";;;\n{\"patterns\": \"**\"}\n;;;\n# Rule\n",
+			want:    &Document{Format: JSON, Data: "{\"patterns\": \"**\"}\n", Line: 2, Body: "# Rule\n"},
+		},
+		{
+			name:    "json object",
+			content: "{\n  \"patterns\": \"**\"\n}  \r\n\r\n# Rule\n",
+			want:    &Document{Format: JSON, Data: "{\n  \"patterns\": \"**\"\n}", Line: 1, Body: "# Rule\n"},
+		},
+		{
+			name:    "unclosed json object is body",
+			content: "{\"patterns\": \"**\"\n",
+			want:    &Document{Body: "{\"patterns\": \"**\"\n"},
+		},
+		{
+			name:    "body starting with a brace",
+			content: "{{.FilePath}} must stay small\n",
+			want:    &Document{Body: "{{.FilePath}} must stay small\n"},
+		},
+		{
+			name:    "json object followed by text",
+			content: "{\"a\": 1} is an example\n",
+			want:    &Document{Body: "{\"a\": 1} is an example\n"},
+		},
+		{
+			name:    "json object without newline",
+			content: "{\"a\": 1}",
+			want:    &Document{Body: "{\"a\": 1}"},
+		},
+		{
+			name:    "no front matter",
+			content: "# Rule\n---\n",
+			want:    &Document{Body: "# Rule\n---\n"},
+		},
+		{
+			name:    "unclosed",
+			content: "---\na: 1\n",
+			wantErr: ErrUnclosed,
+		},
+		{
+			name:    "toml closed by yaml delimiter",
+			content: "+++\na = 1\n---\n",
+			wantErr: ErrUnclosed,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			doc, err := Split([]byte(test.content))
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("Split() error = %v, want %v", err, test.wantErr)
+			}
+			if !reflect.DeepEqual(doc, test.want) {
+				t.Errorf("Split() = %#v, want %#v", doc, test.want)
+			}
+		})
+	}
+}
+
+type testFrontMatter struct {
+	Patterns interface{} `yaml:"patterns"`
+	Priority *int        `yaml:"priority"`
+	When     interface{} `yaml:"when"`
+}
+
+func TestDecodeTOML(t *testing.T) {
+	doc, err := Split([]byte(`+++
+patterns = ["src/**", '!src/legacy/**'] # comment
+priority = 1_0
+
+[when]
+not.fileExists = ".disable"
+
+[[when.any]]
+gitBranch = "release/*"
+
+[[when.any]]
+env = { CI = "true" }
++++
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fm testFrontMatter
+	if err := doc.Decode(&fm); err != nil {
+		t.Fatal(err)
+	}
+	want := testFrontMatter{
+		Patterns: []interface{}{"src/**", "!src/legacy/**"},
+		When: map[string]interface{}{
+			"not": map[string]interface{}{"fileExists": ".disable"},
+			"any": []interface{}{
+				map[string]interface{}{"gitBranch": "release/*"},
+				map[string]interface{}{"env": map[string]interface{}{"CI": "true"}},
+			},
+		},
+	}
+	if fm.Priority == nil || *fm.Priority != 10 {
+		t.Errorf("priority = %v, want 10", fm.Priority)
+	}
+	fm.Priority = nil
+	if !reflect.DeepEqual(fm, want) {
+		t.Errorf("Decode() = %#v, want %#v", fm, want)
+	}
+}
+
+func TestDecodeJSON(t *testing.T) {
+	doc, err := Split([]byte(`{
+  "patterns": ["src/**", "!src/legacy/**"],
+  "priority": 10,
+  "when": {"not": {"fileExists": ".disable"}, "any": [{"gitBranch": "release/*"}, {"env": {"CI": "true"}}]}
+}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fm testFrontMatter
+	if err := doc.Decode(&fm); err != nil {
+		t.Fatal(err)
+	}
+	want := testFrontMatter{
+		Patterns: []interface{}{"src/**", "!src/legacy/**"},
+		When: map[string]interface{}{
+			"not": map[string]interface{}{"fileExists": ".disable"},
+			"any": []interface{}{
+				map[string]interface{}{"gitBranch": "release/*"},
+				map[string]interface{}{"env": map[string]interface{}{"CI": "true"}},
+			},
+		},
+	}
+	if fm.Priority == nil || *fm.Priority != 10 {
+		t.Errorf("priority = %v, want 10", fm.Priority)
+	}
+	fm.Priority = nil
+	if !reflect.DeepEqual(fm, want) {
+		t.Errorf("Decode() = %#v, want %#v", fm, want)
+	}
+}
+
+func TestDecodeErrorLines(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "yaml syntax",
+			content: "---\npatterns: \"**\"\npriority: a: 1\n---\n",
+			want:    "failed to parse YAML: line 3: mapping values are not allowed",
+		},
+		{
+			name:    "yaml type",
+			content: "---\n\npriority: high\n---\n",
+			want:    "line 3: cannot unmarshal",
+		},
+		{
+			name:    "toml",
+			content: "+++\npatterns = \"**\"\npriority = \n+++\n",
+			want:    "failed to parse TOML: line 3: expected a value",
+		},
+		{
+			name:    "toml multi-line string",
+			content: "+++\na = \"\"\"\n\n\"\"\"\nb = x\n+++\n",
+			want:    "failed to parse TOML: line 5: invalid value 'x'",
+		},
+		{
+			name:    "json",
+			content: ";;;\n{\n  \"patterns\": \"**\",\n  \"priority\": x\n}\n;;;\n",
+			want:    "failed to parse JSON: line 4: invalid character 'x'",
+		},
+		{
+			name:    "json type",
+			content: ";;;\n{\"priority\": \"high\"}\n;;;\n",
+			want:    "cannot unmarshal !!str `high` into int",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			doc, err := Split([]byte(test.content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var fm testFrontMatter
+			err = doc.Decode(&fm)
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("Decode() error = %v, want it to contain %q", err, test.want)
+			}
+		})
+	}
+}
+
+// FuzzSplit checks that front matter and body are parts of the content, and
+// that a document written back from its parts splits into the same parts
+func FuzzSplit(f *testing.F) {
+	for _, seed := range []string{
+		"---\npatterns: \"**\"\n---\n\n# Rule\n",
+		"\ufeff---\r\na: 1\r\n---\r\nbody",
+		"---\n---",
+		"----\n---\n",
+		"+++\na = 1\n+++\n",
+		"---\na: |\n  ---\n...\n",
+		"",
+		"---",
+		";;;\n{}\n;;;\n",
+		"{\"a\": [1, {\"b\": null}]}\nbody",
+		"{ x",
+	} {
+		f.Add([]byte(seed))
+	}
+
+	f.Fuzz(func(t *testing.T, content []byte) {
+		doc, err := Split(content)
+		if err != nil {
+			if !errors.Is(err, ErrUnclosed) {
+				t.Fatalf("Split() error = %v", err)
+			}
+			return
+		}
+		text := strings.TrimPrefix(string(content), byteOrderMark)
+		if !strings.HasSuffix(text, doc.Body) {
+			t.Fatalf("body %q is not the end of %q", doc.Body, text)
+		}
+		if doc.Format == "" {
+			if doc.Body != text {
+				t.Fatalf("body %q of a file without front matter is not %q", doc.Body, text)
+			}
+			return
+		}
+		if !strings.Contains(text, doc.Data) {
+			t.Fatalf("front matter %q is not part of %q", doc.Data, text)
+		}
+
+		var written string
+		switch {
+		case doc.Format == JSON && doc.Line == 1:
+			written = doc.Data + "\n" + doc.Body
+		case doc.Format == JSON:
+			written = ";;;\n" + doc.Data + ";;;\n" + doc.Body
+		case doc.Format == TOML:
+			written = "+++\n" + doc.Data + "+++\n" + doc.Body
+		default:
+			written = "---\n" + doc.Data + "---\n" + doc.Body
+		}
+		again, err := Split([]byte(written))
+		if err != nil {
+			t.Fatalf("Split(%q) error = %v", written, err)
+		}
+		if again.Format != doc.Format || again.Data != doc.Data || again.Body != doc.Body {
+			t.Fatalf("Split(%q) = %#v, want %#v", written, again, doc)
+		}
+	})
+}
+
+// FuzzDecode checks that decoding never panics and reports lines within the file
+func FuzzDecode(f *testing.F) {
+	for _, seed := range []string{
+		"---\npatterns: [\"**\"]\npriority: 1\n---\n",
+		"---\npriority: [1\n---\n",
+		"+++\npatterns = [\"**\"]\n[when]\nenv = { CI = \"true\" }\n+++\n",
+		"+++\n[[a]]\nb = '''\nx'''\n+++\n",
+		"+++\na = \"\\u00e9\"\n+++\n",
+		"{\"patterns\": [\"**\"], \"priority\": 1.5}\n",
+		";;;\n{\"when\": {\"env\": {\"CI\": \"true\"}}}\n;;;\n",
+	} {
+		f.Add([]byte(seed))
+	}
+
+	f.Fuzz(func(t *testing.T, content []byte) {
+		doc, err := Split(content)
+		if err != nil || doc.Format == "" {
+			return
+		}
+		var fm testFrontMatter
+		if err := doc.Decode(&fm); err != nil {
+			lines := strings.Count(string(content), "\n") + 1
+			for _, match := range yamlLinePattern.FindAllStringSubmatch(err.Error(), -1) {
+				if line, _ := strconv.Atoi(match[1]); line < 1 || line > lines {
+					t.Fatalf("line %d out of range in %v", line, err)
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,89 @@
+package frontmatter
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// splitJSONObject splits text starting with a JSON object that ends its line.
+// Text that doesn't start with such an object, like a Markdown body starting
+// with "{", has no front matter.
+func splitJSONObject(text string) *Document {
+	decoder := json.NewDecoder(strings.NewReader(text))
+	var object json.RawMessage
+	if err := decoder.Decode(&object); err != nil {
+		return &Document{Body: text}
+	}
+
+	end := int(decoder.InputOffset())
+	rest, after, length := cutLine(text[end:])
+	if rest != "" || !strings.HasSuffix(text[end:end+length], "\n") {
+		return &Document{Body: text}
+	}
+	return &Document{Format: JSON, Data: text[:end], Line: 1, Body: trimLeadingNewlines(after)}
+}
+
+// parseJSON parses a JSON object, with integers as int
+func parseJSON(data string) (map[string]interface{}, error) {
+	decoder := json.NewDecoder(strings.NewReader(data))
+	decoder.UseNumber()
+	var values map[string]interface{}
+	if err := decoder.Decode(&values); err != nil {
+		return nil, jsonLineError(err, data)
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, &lineError{line: lineAt(data, int(decoder.InputOffset())), message: "unexpected text after the object"}
+	}
+	if values == nil {
+		return nil, &lineError{line: 1, message: "the front matter must be an object"}
+	}
+	return jsonNumbers(values).(map[string]interface{}), nil
+}
+
+// jsonLineError returns the lineError of a JSON decoding error in data
+func jsonLineError(err error, data string) error {
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return &lineError{line: lineAt(data, int(syntaxErr.Offset)), message: syntaxErr.Error()}
+	}
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return &lineError{line: lineAt(data, int(typeErr.Offset)), message: "the front matter must be an object"}
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return &lineError{line: lineAt(data, len(data)), message: "unexpected end of the front matter"}
+	}
+	return err
+}
+
+// lineAt returns the line number of offset in data
+func lineAt(data string, offset int) int {
+	if offset > len(data) {
+		offset = len(data)
+	}
+	return 1 + strings.Count(data[:offset], "\n")
+}
+
+// jsonNumbers replaces the json.Number of value by int or float64
+func jsonNumbers(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, item := range value {
+			value[key] = jsonNumbers(item)
+		}
+	case []interface{}:
+		for i, item := range value {
+			value[i] = jsonNumbers(item)
+		}
+	case json.Number:
+		if n, err := strconv.Atoi(value.String()); err == nil {
+			return n
+		}
+		f, _ := value.Float64()
+		return f
+	}
+	return value
+}
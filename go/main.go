@@ -1,11 +1,19 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/dirt-rain/code-editor-agent/commands"
 	"github.com/dirt-rain/code-editor-agent/config"
+	"github.com/dirt-rain/code-editor-agent/models"
 )
 
 // Version information (set via ldflags during build)
@@ -15,85 +23,525 @@ var (
 	date    = "unknown"
 )
 
-func findAgentByCommandGroup(group *string) (string, error) {
-	cfg, err := config.LoadConfig()
-	if err != nil {
-		return "", err
-	}
+// errReported is returned for usage errors the flag package already printed
+var errReported = errors.New("usage error")
 
-	for agentName, agentConfig := range cfg.Agents {
-		// Compare nullable strings
-		if group == nil && agentConfig.CommandGroup == nil {
-			return agentName, nil
-		}
-		if group != nil && agentConfig.CommandGroup != nil && *group == *agentConfig.CommandGroup {
-			return agentName, nil
+// outputFormats are the values of --format
+var outputFormats = []string{commands.FormatText, commands.FormatJSON, commands.FormatTable, commands.FormatHTML}
+
+// globalOptions holds the flags accepted before the file path or command,
+// and after `cmd <command>`
+type globalOptions struct {
+	root   string
+	config string
+	format string
+	quiet  bool
+}
+
+func (g *globalOptions) register(flags *flag.FlagSet) {
+	flags.StringVar(&g.root, "root", g.root, "project root to run in (default: the current directory)")
+	flags.StringVar(&g.config, "config", g.config, "configuration file, relative to the project root (default: "+models.ConfigFilePath+", or its .yaml or .toml alternative)")
+	flags.StringVar(&g.format, "format", g.format, "output format of loading rules, explain, list, coverage and stats: "+strings.Join(outputFormats, ", "))
+	flags.BoolVar(&g.quiet, "quiet", g.quiet, "don't print progress messages")
+}
+
+// apply changes to the project root and selects the configuration file
+func (g *globalOptions) apply() error {
+	if !slices.Contains(outputFormats, g.format) {
+		return fmt.Errorf("Unknown format '%s'. Expected one of: %s.", g.format, strings.Join(outputFormats, ", "))
+	}
+	if g.root != "" {
+		if err := os.Chdir(g.root); err != nil {
+			return fmt.Errorf("failed to change to the project root: %w", err)
 		}
 	}
+	if g.config != "" {
+		config.FilePath = g.config
+	}
+	return nil
+}
+
+// command is a `code-editor-agent cmd <name>` command
+type command struct {
+	name    string
+	args    string // synopsis of the positional arguments
+	summary string
+	// progress is set for commands whose standard output only reports
+	// progress, which --quiet silences
+	progress bool
+	// formats are the formats supported besides text
+	formats []string
+	// setup registers the command's flags and returns the function running it
+	setup func(flags *flag.FlagSet, global *globalOptions) func(args []string) error
+}
+
+// commandList returns the commands in the order they are listed in the usage
+func commandList() []*command {
+	return []*command{
+		{name: "init", summary: "Initialize configuration", progress: true, setup: setupInit},
+		{name: "generate", summary: "Generate rule caches", progress: true, setup: setupGenerate},
+		{name: "upgrade", summary: "Merge built-in template changes into the project", progress: true, setup: setupUpgrade},
+		{name: "config", args: strings.Join(commands.ConfigActions, "|"), summary: "Convert the configuration file between JSONC, YAML and TOML", progress: true, setup: setupConfig},
+		{name: "new-rule", args: "<name>", summary: "Create a rule file", progress: true, setup: setupNewRule},
+		{name: "sync-agents", summary: "Generate .claude/agents/*.md for every agent", progress: true, setup: setupSyncAgents},
+		{name: "explain", args: "[commandGroup] <file-path>", summary: "Explain which rules apply and why", formats: []string{commands.FormatJSON}, setup: setupExplain},
+		{name: "list", args: strings.Join(commands.ListKinds, "|"), summary: "List agents, rules or tags", formats: []string{commands.FormatJSON, commands.FormatTable}, setup: setupList},
+		{name: "coverage", summary: "Report which project files are governed by which rules", formats: []string{commands.FormatJSON, commands.FormatTable, commands.FormatHTML}, setup: setupCoverage},
+		{name: "stats", summary: "Summarize the usage log of loaded rules", formats: []string{commands.FormatJSON, commands.FormatTable}, setup: setupStats},
+		{name: "export", summary: "Export rules for Cursor, Copilot, AGENTS.md or Windsurf", progress: true, setup: setupExport},
+		{name: "import", summary: "Import Cursor, Copilot or AGENTS.md rules", progress: true, setup: setupImport},
+		{name: "hook", args: "[commandGroup]", summary: "Claude Code PreToolUse hook (reads stdin)", setup: setupHook},
+		{name: "mcp", summary: "Run an MCP server over stdio", setup: setupMCP},
+		{name: "serve", summary: "Run a daemon for warm lookups", setup: setupServe},
+		{name: "completion", args: "bash|zsh|fish", summary: "Print a shell completion script", setup: setupCompletion},
+	}
+}
 
-	if group == nil {
-		return "", fmt.Errorf("No agent found with commandGroup: null")
+func findCommand(name string) (*command, bool) {
+	for _, c := range commandList() {
+		if c.name == name {
+			return c, true
+		}
 	}
-	return "", fmt.Errorf("No agent found with commandGroup: %s", *group)
+	return nil, false
 }
 
 func main() {
-	args := os.Args[1:]
+	if err := run(os.Args[1:]); err != nil {
+		switch {
+		case errors.Is(err, flag.ErrHelp):
+			return
+		case errors.Is(err, errReported):
+			os.Exit(2)
+		}
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
 
+// run parses the global flags and runs a command, or prints the rules for a file
+func run(args []string) error {
 	// Handle version flag
 	if len(args) == 1 && (args[0] == "--version" || args[0] == "-v") {
 		fmt.Printf("code-editor-agent version %s (commit: %s, built: %s)\n", version, commit, date)
-		return
+		return nil
+	}
+
+	global := &globalOptions{format: commands.FormatText}
+	flags := flag.NewFlagSet("code-editor-agent", flag.ContinueOnError)
+	global.register(flags)
+	flags.Usage = func() { printUsage(flags) }
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	rest := flags.Args()
+	// After `--`, the arguments are never a command
+	dashDash := len(rest) < len(args) && args[len(args)-len(rest)-1] == "--"
+
+	if !dashDash && len(rest) > 0 && rest[0] == "cmd" {
+		// code-editor-agent cmd <command> [args...]
+		if len(rest) < 2 {
+			printUsage(flags)
+			return errReported
+		}
+		c, ok := findCommand(rest[1])
+		if !ok {
+			return fmt.Errorf("Unknown command: %s", rest[1])
+		}
+		return runCommand(c, global, rest[2:])
 	}
 
-	if len(args) == 1 {
-		// code-editor-agent <file-path>
+	// Flags may also follow the commandGroup or the file path
+	if !dashDash && len(rest) > 0 {
+		positional, err := parseInterspersed(flags, rest[1:])
+		if err != nil {
+			return err
+		}
+		rest = append([]string{rest[0]}, positional...)
+	}
+
+	// code-editor-agent [commandGroup] [--] <file-path>
+	var group *string
+	switch len(rest) {
+	case 1:
 		// Find agent with commandGroup: null
-		agentName, err := findAgentByCommandGroup(nil)
+	case 2:
+		group = &rest[0]
+	default:
+		printUsage(flags)
+		return errReported
+	}
+	if err := global.apply(); err != nil {
+		return err
+	}
+	if global.format != commands.FormatText && global.format != commands.FormatJSON {
+		return fmt.Errorf("Loading rules doesn't support --format %s.", global.format)
+	}
+	return runLoad(group, rest[len(rest)-1], global)
+}
+
+// parseFlags parses args, reporting errors already printed by the flag package
+// as errReported
+func parseFlags(flags *flag.FlagSet, args []string) error {
+	err := flags.Parse(args)
+	if err != nil && !errors.Is(err, flag.ErrHelp) {
+		return errReported
+	}
+	return err
+}
+
+// runCommand parses the flags of a command and runs it
+func runCommand(c *command, global *globalOptions, args []string) error {
+	flags := flag.NewFlagSet("cmd "+c.name, flag.ContinueOnError)
+	runFunc := c.setup(flags, global)
+	global.register(flags)
+	flags.Usage = func() {
+		out := flags.Output()
+		fmt.Fprintf(out, "Usage: code-editor-agent cmd %s [options]", c.name)
+		if c.args != "" {
+			fmt.Fprintf(out, " %s", c.args)
+		}
+		fmt.Fprintf(out, "\n\n%s.\n\nOptions:\n", c.summary)
+		flags.PrintDefaults()
+	}
+	positional, err := parseInterspersed(flags, args)
+	if err != nil {
+		return err
+	}
+
+	if err := global.apply(); err != nil {
+		return err
+	}
+	if global.format != commands.FormatText && !slices.Contains(c.formats, global.format) {
+		return fmt.Errorf("Command '%s' doesn't support --format %s.", c.name, global.format)
+	}
+	// The changes previewed by --dry-run are the output, not progress
+	if global.quiet && c.progress && !isFlagSet(flags, "dry-run") {
+		devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
 		if err != nil {
-			fmt.Fprintln(os.Stderr, err)
-			os.Exit(1)
-		}
-		if err := commands.Load(agentName, args[0]); err != nil {
-			fmt.Fprintln(os.Stderr, err)
-			os.Exit(1)
-		}
-	} else if len(args) == 2 {
-		if args[0] == "cmd" {
-			// code-editor-agent cmd <command>
-			switch args[1] {
-			case "init":
-				if err := commands.Init(); err != nil {
-					fmt.Fprintln(os.Stderr, err)
-					os.Exit(1)
-				}
-			case "generate":
-				if err := commands.Generate(false); err != nil {
-					fmt.Fprintln(os.Stderr, err)
-					os.Exit(1)
-				}
-			default:
-				fmt.Fprintf(os.Stderr, "Unknown command: %s\n", args[1])
-				os.Exit(1)
+			return err
+		}
+		defer devNull.Close()
+		os.Stdout = devNull
+	}
+	return runFunc(positional)
+}
+
+// isFlagSet reports whether the boolean flag name is defined and set
+func isFlagSet(flags *flag.FlagSet, name string) bool {
+	f := flags.Lookup(name)
+	return f != nil && f.Value.String() == "true"
+}
+
+// parseInterspersed parses flags placed before, between or after the
+// positional arguments and returns the positional arguments. Everything after
+// `--` is positional.
+func parseInterspersed(flags *flag.FlagSet, args []string) ([]string, error) {
+	positional := []string{}
+	for {
+		if err := parseFlags(flags, args); err != nil {
+			return nil, err
+		}
+		rest := flags.Args()
+		if len(rest) == 0 {
+			return positional, nil
+		}
+		if len(rest) < len(args) && args[len(args)-len(rest)-1] == "--" {
+			return append(positional, rest...), nil
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
+// runLoad prints the rules for filePath, through the daemon if one is running
+func runLoad(group *string, filePath string, global *globalOptions) error {
+	if useDaemon(global) && commands.RequestDaemon("load", group, filePath, os.Stdout) {
+		return nil
+	}
+	agentName, err := findAgentByCommandGroup(group)
+	if err != nil {
+		return err
+	}
+	return commands.Load(agentName, filePath, global.format)
+}
+
+// useDaemon reports whether a running daemon can answer for the global options;
+// it only produces text output for the default configuration file
+func useDaemon(global *globalOptions) bool {
+	return global.format == commands.FormatText && config.FilePath == models.ConfigFilePath
+}
+
+func findAgentByCommandGroup(group *string) (string, error) {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return "", err
+	}
+	return config.FindAgentByCommandGroup(cfg, group)
+}
+
+func setupInit(flags *flag.FlagSet, global *globalOptions) func(args []string) error {
+	hook := flags.Bool("hook", false, "register the PreToolUse hook in .claude/settings.json")
+	runtime := flags.String("runtime", "", "add the file editing rules to CLAUDE.md and allow running the CLI installed with go or node")
+	dryRun := flags.Bool("dry-run", false, "show what would change instead of writing anything")
+	presets := flags.String("preset", "", "comma-separated starter rule packs: "+strings.Join(commands.PresetNames(), ", "))
+	agents := flags.String("agents", "", "comma-separated agents to create: "+strings.Join(commands.StarterAgentKeys(), ", ")+" (default: editor)")
+	interactive := flags.Bool("interactive", false, "ask for presets, agents and runtime, proposing the detected presets")
+	return func(args []string) error {
+		opts := commands.InitOptions{Hook: *hook, Runtime: *runtime, DryRun: *dryRun, Presets: splitList(*presets), Agents: splitList(*agents)}
+		if *interactive {
+			if !commands.IsTerminal(os.Stdin) {
+				return fmt.Errorf("--interactive needs a terminal. Use --preset and --agents instead.")
 			}
-		} else {
-			// code-editor-agent <commandGroup> <file-path>
-			agentName, err := findAgentByCommandGroup(&args[0])
-			if err != nil {
-				fmt.Fprintln(os.Stderr, err)
-				os.Exit(1)
+			if err := commands.PromptInit(os.Stdin, os.Stdout, &opts); err != nil {
+				return err
 			}
-			if err := commands.Load(agentName, args[1]); err != nil {
-				fmt.Fprintln(os.Stderr, err)
-				os.Exit(1)
+		}
+		return commands.Init(opts)
+	}
+}
+
+func setupGenerate(flags *flag.FlagSet, global *globalOptions) func(args []string) error {
+	force := flags.Bool("force", false, "generate the cache even if init didn't run in this directory")
+	return func(args []string) error {
+		return commands.Generate(*force)
+	}
+}
+
+func setupUpgrade(flags *flag.FlagSet, global *globalOptions) func(args []string) error {
+	dryRun := flags.Bool("dry-run", false, "show the changes without writing anything")
+	adopt := flags.Bool("adopt", false, "for files without a recorded template, keep your version and merge future template changes")
+	writeConflicts := flags.Bool("write-conflicts", false, "write files with conflicts, with conflict markers")
+	ruleFile := flags.String("rule-file", "", "path RENAME-ME.code-editor-agent.md was renamed to")
+	return func(args []string) error {
+		return commands.Upgrade(commands.UpgradeOptions{DryRun: *dryRun, Adopt: *adopt, WriteConflicts: *writeConflicts, RuleFile: *ruleFile})
+	}
+}
+
+func setupConfig(flags *flag.FlagSet, global *globalOptions) func(args []string) error {
+	to := flags.String("to", "", "format to convert to: "+strings.Join(config.Formats, ", "))
+	dryRun := flags.Bool("dry-run", false, "print the converted file without writing anything")
+	return func(args []string) error {
+		if len(args) != 1 || args[0] != "convert" || *to == "" {
+			return fmt.Errorf("Usage: code-editor-agent cmd config convert --to %s [--dry-run]", strings.Join(config.Formats, "|"))
+		}
+		return commands.ConfigConvert(commands.ConfigConvertOptions{To: *to, DryRun: *dryRun})
+	}
+}
+
+func setupNewRule(flags *flag.FlagSet, global *globalOptions) func(args []string) error {
+	agent := flags.String("agent", "", "agent of the rule (default: the agent with commandGroup: null)")
+	var patterns, tags listFlag
+	flags.Var(&patterns, "patterns", "glob of files the rule applies to, a leading ! negates it (repeatable)")
+	flags.Var(&tags, "tag", "tag other rules can reference the rule by (repeatable, or comma-separated)")
+	var priority *int
+	flags.Func("priority", "priority of the rule (default: always loaded)", func(value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("expected an integer")
+		}
+		priority = &n
+		return nil
+	})
+	return func(args []string) error {
+		if len(args) != 1 {
+			return fmt.Errorf("Usage: code-editor-agent cmd new-rule [--agent name] [--patterns glob]... [--tag tag]... [--priority n] <name>")
+		}
+		return commands.NewRule(args[0], commands.NewRuleOptions{Agent: *agent, Patterns: patterns, Tags: splitList(strings.Join(tags, ",")), Priority: priority})
+	}
+}
+
+func setupSyncAgents(flags *flag.FlagSet, global *globalOptions) func(args []string) error {
+	force := flags.Bool("force", false, "take over agent files not generated by sync-agents, keeping their body")
+	return func(args []string) error {
+		return commands.SyncAgents(*force)
+	}
+}
+
+func setupExplain(flags *flag.FlagSet, global *globalOptions) func(args []string) error {
+	return func(args []string) error {
+		var group *string
+		switch len(args) {
+		case 1:
+		case 2:
+			group = &args[0]
+		default:
+			return fmt.Errorf("Usage: code-editor-agent cmd explain [commandGroup] <file-path>")
+		}
+		if useDaemon(global) && commands.RequestDaemon("explain", group, args[len(args)-1], os.Stdout) {
+			return nil
+		}
+		agentName, err := findAgentByCommandGroup(group)
+		if err != nil {
+			return err
+		}
+		return commands.Explain(agentName, args[len(args)-1], global.format)
+	}
+}
+
+func setupList(flags *flag.FlagSet, global *globalOptions) func(args []string) error {
+	agent := flags.String("agent", "", "only list the agents, rules or tags of this agent")
+	return func(args []string) error {
+		if len(args) != 1 {
+			return fmt.Errorf("Usage: code-editor-agent cmd list %s [--agent name] [--format table|json]", strings.Join(commands.ListKinds, "|"))
+		}
+		return commands.List(args[0], *agent, global.format)
+	}
+}
+
+func setupCoverage(flags *flag.FlagSet, global *globalOptions) func(args []string) error {
+	agent := flags.String("agent", "", "agent whose rules to check (default: the agent with commandGroup: null)")
+	limit := flags.Int("limit", 20, "number of files in each file list, 0 for all")
+	return func(args []string) error {
+		if len(args) != 0 {
+			return fmt.Errorf("Usage: code-editor-agent cmd coverage [--agent name] [--limit n] [--format table|json|html]")
+		}
+		return commands.Coverage(commands.CoverageOptions{Agent: *agent, Format: global.format, Limit: *limit})
+	}
+}
+
+func setupStats(flags *flag.FlagSet, global *globalOptions) func(args []string) error {
+	agent := flags.String("agent", "", "only count loads of this agent (default: every agent)")
+	days := flags.Int("days", 30, "number of days after which a rule not loaded is unused")
+	limit := flags.Int("limit", 10, "number of rules in each list, 0 for all")
+	return func(args []string) error {
+		if len(args) != 0 || *days < 0 {
+			return fmt.Errorf("Usage: code-editor-agent cmd stats [--agent name] [--days n] [--limit n] [--format table|json]")
+		}
+		return commands.Stats(commands.StatsOptions{Agent: *agent, Format: global.format, Days: *days, Limit: *limit})
+	}
+}
+
+func setupExport(flags *flag.FlagSet, global *globalOptions) func(args []string) error {
+	to := flags.String("to", "", "target format: "+strings.Join(commands.ExportTargets, ", "))
+	agent := flags.String("agent", "", "agent to export (default: the agent with commandGroup: null)")
+	force := flags.Bool("force", false, "overwrite files that were not generated by export")
+	return func(args []string) error {
+		if *to == "" {
+			return fmt.Errorf("Usage: code-editor-agent cmd export --to %s [--agent name] [--force]", strings.Join(commands.ExportTargets, "|"))
+		}
+		return commands.Export(commands.ExportOptions{To: *to, Agent: *agent, Force: *force})
+	}
+}
+
+func setupImport(flags *flag.FlagSet, global *globalOptions) func(args []string) error {
+	from := flags.String("from", "", "source format: "+strings.Join(commands.ImportSources, ", "))
+	agent := flags.String("agent", "", "agent whose ruleFilePattern names the files (default: the agent with commandGroup: null)")
+	dryRun := flags.Bool("dry-run", false, "print the rule files instead of writing them")
+	force := flags.Bool("force", false, "overwrite existing rule files")
+	return func(args []string) error {
+		if *from == "" {
+			return fmt.Errorf("Usage: code-editor-agent cmd import --from %s [--agent name] [--dry-run] [--force]", strings.Join(commands.ImportSources, "|"))
+		}
+		return commands.Import(commands.ImportOptions{From: *from, Agent: *agent, DryRun: *dryRun, Force: *force})
+	}
+}
+
+func setupHook(flags *flag.FlagSet, global *globalOptions) func(args []string) error {
+	mode := flags.String("mode", commands.HookModeContext, "context: add rules as context, block: deny the first edit of each file with the rules")
+	return func(args []string) error {
+		// Claude Code runs hooks from the project directory, but be explicit
+		if projectDir := os.Getenv("CLAUDE_PROJECT_DIR"); projectDir != "" && global.root == "" {
+			if err := os.Chdir(projectDir); err != nil {
+				return err
 			}
 		}
-	} else {
-		fmt.Fprintln(os.Stderr, "Usage:")
-		fmt.Fprintln(os.Stderr, "  code-editor-agent <file-path>              # Use agent with commandGroup: null")
-		fmt.Fprintln(os.Stderr, "  code-editor-agent <commandGroup> <file>    # Use agent with specified commandGroup")
-		fmt.Fprintln(os.Stderr, "  code-editor-agent cmd init                 # Initialize configuration")
-		fmt.Fprintln(os.Stderr, "  code-editor-agent cmd generate             # Generate rule caches")
-		os.Exit(1)
+		var group *string
+		if len(args) > 0 {
+			group = &args[0]
+		}
+		agentName, err := findAgentByCommandGroup(group)
+		if err != nil {
+			return err
+		}
+		return commands.Hook(agentName, *mode, useDaemon(global), os.Stdin, os.Stdout)
+	}
+}
+
+func setupMCP(flags *flag.FlagSet, global *globalOptions) func(args []string) error {
+	return func(args []string) error {
+		return commands.MCP(version, os.Stdin, os.Stdout)
+	}
+}
+
+func setupServe(flags *flag.FlagSet, global *globalOptions) func(args []string) error {
+	socketPath := flags.String("socket", "", "Unix socket path (default: derived from the project directory, or $"+commands.DaemonSocketEnv+")")
+	return func(args []string) error {
+		return commands.Serve(*socketPath)
+	}
+}
+
+func setupCompletion(flags *flag.FlagSet, global *globalOptions) func(args []string) error {
+	return func(args []string) error {
+		if len(args) != 1 {
+			return fmt.Errorf("Usage: code-editor-agent cmd completion bash|zsh|fish")
+		}
+		if args[0] == completionGroups {
+			printCommandGroups(os.Stdout)
+			return nil
+		}
+		script, err := completionScript(args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Print(script)
+		return nil
+	}
+}
+
+// listFlag is a flag that can be repeated, collecting its values
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// splitList splits a comma-separated flag value
+func splitList(value string) []string {
+	values := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			values = append(values, item)
+		}
+	}
+	return values
+}
+
+func printUsage(flags *flag.FlagSet) {
+	out := flags.Output()
+	fmt.Fprintln(out, "Usage:")
+	fmt.Fprintln(out, "  code-editor-agent [options] [--] <file-path>                 # Use agent with commandGroup: null")
+	fmt.Fprintln(out, "  code-editor-agent [options] <commandGroup> [--] <file-path>  # Use agent with specified commandGroup")
+	fmt.Fprintln(out, "  code-editor-agent [options] cmd <command> [args]")
+	fmt.Fprintln(out, "\nCommands:")
+	for _, c := range commandList() {
+		fmt.Fprintf(out, "  %-12s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintln(out, "\nOptions:")
+	flags.PrintDefaults()
+	fmt.Fprintln(out, "\nRun `code-editor-agent cmd <command> --help` for the options of a command.")
+	fmt.Fprintln(out, "Use `--` before a file path that starts with - or is named cmd.")
+}
+
+// printCommandGroups prints the commandGroup of every agent, for completion
+func printCommandGroups(w io.Writer) {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return
+	}
+	groups := []string{}
+	for _, agentConfig := range cfg.Agents {
+		if agentConfig.CommandGroup != nil {
+			groups = append(groups, *agentConfig.CommandGroup)
+		}
+	}
+	sort.Strings(groups)
+	for _, group := range groups {
+		fmt.Fprintln(w, group)
 	}
 }
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dirt-rain/code-editor-agent/commands"
+	"github.com/dirt-rain/code-editor-agent/config"
+	"github.com/dirt-rain/code-editor-agent/models"
+)
+
+// captureRun runs the CLI with args and returns what it printed to stdout
+// and stderr
+func captureRun(t *testing.T, args ...string) (string, string, error) {
+	t.Helper()
+	capture := func(file **os.File) (func() string, error) {
+		r, w, err := os.Pipe()
+		if err != nil {
+			return nil, err
+		}
+		previous := *file
+		*file = w
+		output := make(chan string)
+		go func() {
+			var buf bytes.Buffer
+			io.Copy(&buf, r)
+			output <- buf.String()
+		}()
+		return func() string {
+			w.Close()
+			*file = previous
+			return <-output
+		}, nil
+	}
+	stdout, err := capture(&os.Stdout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stderr, err := capture(&os.Stderr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runErr := run(args)
+	return stdout(), stderr(), runErr
+}
+
+// newTestProject generates the cache of a project with a reviewer agent in a temporary
+// directory, which is the working directory until the end of the test
+func newTestProject(t *testing.T) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	t.Setenv(commands.DaemonDisableEnv, "1")
+
+	dir := t.TempDir()
+	files := map[string]string{
+		models.ConfigFilePath: `{"agents": {
+  "code-editor": {"ruleFilePattern": "**/*.code-editor-agent.md", "commandGroup": null},
+  "code-reviewer": {"ruleFilePattern": "**/*.code-reviewer.md", "commandGroup": "reviewer"},
+}}`,
+		"src.code-editor-agent.md":      "---\npatterns: \"src/**\"\n---\n# Source rules\n",
+		"all.code-reviewer.md":          "---\npatterns: \"**\"\n---\n# Review rules\n",
+		"reviewer.code-editor-agent.md": "---\npatterns: reviewer\n---\n# File named reviewer\n",
+	}
+	for name, content := range files {
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(target, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, _, err := captureRun(t, "--root", dir, "cmd", "generate", "--force"); err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+}
+
+func TestRunLoad(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		// want is in the output, or the error if wantErr is set
+		want    string
+		wantErr bool
+	}{
+		{name: "file", args: []string{"src/a.go"}, want: "# Source rules"},
+		{name: "group", args: []string{"reviewer", "src/a.go"}, want: "# Review rules"},
+		{name: "flag after the file", args: []string{"src/a.go", "--format", "json"}, want: `"path": "src.code-editor-agent.md"`},
+		{name: "flag after the group", args: []string{"reviewer", "--format", "json", "src/a.go"}, want: `"path": "all.code-reviewer.md"`},
+		{name: "dash dash after the group", args: []string{"reviewer", "--", "src/a.go"}, want: "# Review rules"},
+		{name: "file named like a group", args: []string{"--", "reviewer"}, want: "# File named reviewer"},
+		{name: "file named like a command", args: []string{"--", "cmd"}, want: "No additional context found for cmd."},
+		{name: "flag after dash dash", args: []string{"--", "--format"}, want: "No additional context found for --format."},
+		{name: "table format", args: []string{"src/a.go", "--format", "table"}, want: "Loading rules doesn't support --format table.", wantErr: true},
+		{name: "html format", args: []string{"--format", "html", "src/a.go"}, want: "Loading rules doesn't support --format html.", wantErr: true},
+		{name: "unknown format", args: []string{"src/a.go", "--format", "xml"}, want: "Unknown format 'xml'", wantErr: true},
+		{name: "too many paths", args: []string{"reviewer", "src/a.go", "src/b.go"}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			newTestProject(t)
+			stdout, _, err := captureRun(t, test.args...)
+			if test.wantErr {
+				if err == nil || !strings.Contains(err.Error(), test.want) {
+					t.Fatalf("run(%q) = %v, want an error containing %q", test.args, err, test.want)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("run(%q): %v", test.args, err)
+			}
+			if !strings.Contains(stdout, test.want) {
+				t.Errorf("run(%q) printed %q, want %q", test.args, stdout, test.want)
+			}
+		})
+	}
+}
+
+func TestRunCommand(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{name: "flags before the argument", args: []string{"cmd", "list", "--format", "json", "agents"}, want: `"name": "code-reviewer"`},
+		{name: "flags after the argument", args: []string{"cmd", "list", "rules", "--format", "table"}, want: "all.code-reviewer.md"},
+		{name: "global flags before cmd", args: []string{"--format", "json", "cmd", "list", "tags"}, want: "[]"},
+		{name: "completion script", args: []string{"cmd", "completion", "bash"}, want: "complete -o filenames -F _code_editor_agent code-editor-agent"},
+		{name: "completion groups", args: []string{"cmd", "completion", "groups"}, want: "reviewer\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			newTestProject(t)
+			stdout, _, err := captureRun(t, test.args...)
+			if err != nil {
+				t.Fatalf("run(%q): %v", test.args, err)
+			}
+			if !strings.Contains(stdout, test.want) {
+				t.Errorf("run(%q) printed %q, want %q", test.args, stdout, test.want)
+			}
+		})
+	}
+}
+
+func TestRunHelp(t *testing.T) {
+	tests := []struct {
+		args []string
+		want []string
+	}{
+		{args: []string{"--help"}, want: []string{"Usage:", "code-editor-agent [options] cmd <command> [args]", "-format string"}},
+		{args: []string{"cmd", "list", "--help"}, want: []string{"Usage: code-editor-agent cmd list [options] agents|rules|tags", "List agents, rules or tags."}},
+		{args: []string{"cmd", "list", "rules", "--help"}, want: []string{"Usage: code-editor-agent cmd list [options]"}},
+		{args: []string{"cmd", "new-rule", "--help"}, want: []string{"Usage: code-editor-agent cmd new-rule [options] <name>", "-patterns"}},
+	}
+
+	for _, test := range tests {
+		t.Run(strings.Join(test.args, " "), func(t *testing.T) {
+			stdout, stderr, err := captureRun(t, test.args...)
+			if !errors.Is(err, flag.ErrHelp) {
+				t.Fatalf("run(%q) = %v, want flag.ErrHelp", test.args, err)
+			}
+			if stdout != "" {
+				t.Errorf("run(%q) printed %q to stdout, want the usage on stderr", test.args, stdout)
+			}
+			for _, want := range test.want {
+				if !strings.Contains(stderr, want) {
+					t.Errorf("run(%q) printed %q, want %q", test.args, stderr, want)
+				}
+			}
+		})
+	}
+}
+
+func TestCompletionTo(t *testing.T) {
+	formats := strings.Join(config.Formats, " ")
+	targets := strings.Join(commands.ExportTargets, " ")
+	tests := []struct {
+		shell string
+		want  []string
+	}{
+		{shell: "bash", want: []string{
+			`config) COMPREPLY=($(compgen -W "` + formats + `" -- "$cur")) ;;`,
+			`export) COMPREPLY=($(compgen -W "` + targets + `" -- "$cur")) ;;`,
+		}},
+		{shell: "zsh", want: []string{
+			"config) compadd " + formats + " ;;",
+			"export) compadd " + targets + " ;;",
+		}},
+		{shell: "fish", want: []string{
+			"__fish_seen_subcommand_from config' -l to -x -a '" + formats + "'",
+			"__fish_seen_subcommand_from export' -l to -x -a '" + targets + "'",
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.shell, func(t *testing.T) {
+			script, err := completionScript(test.shell)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range test.want {
+				if !strings.Contains(script, want) {
+					t.Errorf("%s completion has no %q", test.shell, want)
+				}
+			}
+		})
+	}
+}
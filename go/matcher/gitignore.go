@@ -0,0 +1,80 @@
+package matcher
+
+import (
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// Gitignore matches paths against the patterns of the .gitignore files of a
+// project. Paths are relative to the project root and slash separated.
+type Gitignore struct {
+	rules []gitignoreRule
+}
+
+// gitignoreRule is a single pattern of a .gitignore file
+type gitignoreRule struct {
+	dir     string // directory of the .gitignore file, "" for the root
+	glob    string
+	negate  bool
+	dirOnly bool
+}
+
+// Add adds the patterns of the .gitignore file in dir ("" or "." for the root)
+func (g *Gitignore) Add(dir, content string) {
+	if dir == "." {
+		dir = ""
+	}
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		rule := gitignoreRule{dir: dir}
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimSuffix(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+
+		// Patterns with a slash are relative to the .gitignore file, others match at any depth
+		if strings.Contains(line, "/") {
+			rule.glob = strings.TrimPrefix(line, "/")
+		} else {
+			rule.glob = "**/" + line
+		}
+		g.rules = append(g.rules, rule)
+	}
+}
+
+// Ignored reports whether a file, or a directory if isDir is set, is ignored.
+// The last matching pattern wins, as in git. Callers walking the project
+// should not descend into ignored directories.
+func (g *Gitignore) Ignored(filePath string, isDir bool) bool {
+	ignored := false
+	for _, rule := range g.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		rel := filePath
+		if rule.dir != "" {
+			if !strings.HasPrefix(filePath, rule.dir+"/") {
+				continue
+			}
+			rel = filePath[len(rule.dir)+1:]
+		}
+		if ok, _ := doublestar.Match(rule.glob, rel); ok {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
@@ -0,0 +1,155 @@
+package matcher
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// Pattern is a single glob pattern, optionally negated with a leading "!"
+type Pattern struct {
+	Glob   string
+	Negate bool
+}
+
+// ParsePattern splits a raw pattern into its glob and negation flag.
+// A leading "\!" escapes a literal "!" at the start of the glob.
+func ParsePattern(raw string) Pattern {
+	if strings.HasPrefix(raw, `\!`) {
+		return Pattern{Glob: raw[1:]}
+	}
+	if strings.HasPrefix(raw, "!") {
+		return Pattern{Glob: raw[1:], Negate: true}
+	}
+	return Pattern{Glob: raw}
+}
+
+// MatchPatterns evaluates an ordered, gitignore-style pattern list against
+// filePath. Patterns are applied in order and the last matching one wins,
+// so a later "!pattern" excludes paths and a later plain pattern re-includes them.
+func MatchPatterns(patterns []string, filePath string) bool {
+	matched := false
+	for _, raw := range patterns {
+		pattern := ParsePattern(raw)
+		if ok, _ := doublestar.Match(pattern.Glob, filePath); ok {
+			matched = !pattern.Negate
+		}
+	}
+	return matched
+}
+
+// MatchAny reports whether filePath matches any of the patterns
+func MatchAny(patterns []string, filePath string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := doublestar.Match(pattern, filePath); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Match reports whether a rule with the given patterns and ignorePatterns
+// applies to filePath
+func Match(patterns, ignorePatterns []string, filePath string) bool {
+	return MatchPatterns(patterns, filePath) && !MatchAny(ignorePatterns, filePath)
+}
+
+// ValidatePatterns checks that every entry of an ordered pattern list is a
+// valid, optionally negated, glob
+func ValidatePatterns(patterns []string) error {
+	for _, raw := range patterns {
+		pattern := ParsePattern(raw)
+		if pattern.Negate && pattern.Glob == "" {
+			return fmt.Errorf("empty negated pattern %q", raw)
+		}
+		if !doublestar.ValidatePattern(pattern.Glob) {
+			return fmt.Errorf("invalid glob pattern %q", raw)
+		}
+	}
+	return nil
+}
+
+// ValidateGlobs checks that every entry is a valid plain glob
+func ValidateGlobs(globs []string) error {
+	for _, glob := range globs {
+		if !doublestar.ValidatePattern(glob) {
+			return fmt.Errorf("invalid glob pattern %q", glob)
+		}
+	}
+	return nil
+}
+
+// SplitGlobs splits a comma-separated list of globs, as written by other
+// assistants, keeping commas inside {...} alternatives and [...] classes
+func SplitGlobs(list string) []string {
+	globs := []string{}
+	for _, glob := range splitTopLevel(list) {
+		if glob = strings.TrimSpace(glob); glob != "" {
+			globs = append(globs, glob)
+		}
+	}
+	return globs
+}
+
+// ExpandBraces expands the {...} alternatives of a glob, so that
+// "src/*.{ts,tsx}" becomes "src/*.ts" and "src/*.tsx"
+func ExpandBraces(glob string) []string {
+	start, depth := -1, 0
+	for i := 0; i < len(glob); i++ {
+		switch glob[i] {
+		case '\\':
+			i++
+		case '[':
+			if end := strings.IndexByte(glob[i+1:], ']'); end >= 0 {
+				i += end + 1
+			}
+		case '{':
+			if depth == 0 {
+				start = i
+			}
+			depth++
+		case '}':
+			if depth == 0 {
+				continue
+			}
+			if depth--; depth > 0 {
+				continue
+			}
+			expanded := []string{}
+			for _, alternative := range splitTopLevel(glob[start+1 : i]) {
+				expanded = append(expanded, ExpandBraces(glob[:start]+alternative+glob[i+1:])...)
+			}
+			return expanded
+		}
+	}
+	return []string{glob}
+}
+
+// splitTopLevel splits s on the commas outside {...}, [...] and escapes
+func splitTopLevel(s string) []string {
+	parts := []string{}
+	last, depth := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '[':
+			if end := strings.IndexByte(s[i+1:], ']'); end >= 0 {
+				i += end + 1
+			}
+		case '{':
+			depth++
+		case '}':
+			if depth > 0 {
+				depth--
+			}
+		case ',':
+			if depth == 0 {
+				parts = append(parts, s[last:i])
+				last = i + 1
+			}
+		}
+	}
+	return append(parts, s[last:])
+}
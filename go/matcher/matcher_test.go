@@ -0,0 +1,116 @@
+package matcher
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePattern(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want Pattern
+	}{
+		{"src/**", Pattern{Glob: "src/**"}},
+		{"!src/gen/**", Pattern{Glob: "src/gen/**", Negate: true}},
+		{`\!important.md`, Pattern{Glob: "!important.md"}},
+		{"!!x", Pattern{Glob: "!x", Negate: true}},
+		{"a!b", Pattern{Glob: "a!b"}},
+	}
+	for _, test := range tests {
+		if got := ParsePattern(test.raw); got != test.want {
+			t.Errorf("ParsePattern(%q) = %+v, want %+v", test.raw, got, test.want)
+		}
+	}
+}
+
+func TestMatchPatterns(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		path     string
+		want     bool
+	}{
+		{"plain match", []string{"src/**/*.go"}, "src/a/b.go", true},
+		{"no match", []string{"src/**/*.go"}, "docs/a.go", false},
+		{"empty list", []string{}, "src/a.go", false},
+		{"negation alone matches nothing", []string{"!src/gen/**"}, "src/a.go", false},
+		{"later negation excludes", []string{"src/**", "!src/gen/**"}, "src/gen/a.go", false},
+		{"negation keeps other paths", []string{"src/**", "!src/gen/**"}, "src/a.go", true},
+		{"later pattern re-includes", []string{"src/**", "!src/gen/**", "src/gen/keep.go"}, "src/gen/keep.go", true},
+		{"earlier pattern is overridden", []string{"src/gen/keep.go", "!src/gen/**"}, "src/gen/keep.go", false},
+		{"escaped bang is literal", []string{`\!notes.md`}, "!notes.md", true},
+		{"escaped bang doesn't negate", []string{"**", `\!notes.md`}, "notes.md", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := MatchPatterns(test.patterns, test.path); got != test.want {
+				t.Errorf("MatchPatterns(%q, %q) = %t, want %t", test.patterns, test.path, got, test.want)
+			}
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	patterns := []string{"src/**", "!src/gen/**"}
+	ignore := []string{"**/*_test.go"}
+	for path, want := range map[string]bool{
+		"src/a.go":      true,
+		"src/a_test.go": false,
+		"src/gen/a.go":  false,
+		"docs/a.go":     false,
+	} {
+		if got := Match(patterns, ignore, path); got != want {
+			t.Errorf("Match(%q) = %t, want %t", path, got, want)
+		}
+	}
+}
+
+func TestValidatePatterns(t *testing.T) {
+	if err := ValidatePatterns([]string{"src/**", "!src/gen/**", `\!x`, "*.{ts,tsx}"}); err != nil {
+		t.Errorf("ValidatePatterns() error = %v", err)
+	}
+	for _, invalid := range []string{"!", "src/[a", "!src/{a"} {
+		if err := ValidatePatterns([]string{invalid}); err == nil {
+			t.Errorf("ValidatePatterns(%q) succeeded, want an error", invalid)
+		}
+	}
+}
+
+func TestSplitGlobs(t *testing.T) {
+	tests := []struct {
+		list string
+		want []string
+	}{
+		{"src/**/*.ts", []string{"src/**/*.ts"}},
+		{"a/**, b/**,", []string{"a/**", "b/**"}},
+		{"src/**/*.{ts,tsx},test/**", []string{"src/**/*.{ts,tsx}", "test/**"}},
+		{"{a,{b,c}}/*,d", []string{"{a,{b,c}}/*", "d"}},
+		{"[,]x,y", []string{"[,]x", "y"}},
+		{`a\,b,c`, []string{`a\,b`, "c"}},
+		{"", []string{}},
+	}
+	for _, test := range tests {
+		if got := SplitGlobs(test.list); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("SplitGlobs(%q) = %q, want %q", test.list, got, test.want)
+		}
+	}
+}
+
+func TestExpandBraces(t *testing.T) {
+	tests := []struct {
+		glob string
+		want []string
+	}{
+		{"src/**", []string{"src/**"}},
+		{"src/*.{ts,tsx}", []string{"src/*.ts", "src/*.tsx"}},
+		{"{a,b}/{c,d}", []string{"a/c", "a/d", "b/c", "b/d"}},
+		{"{a,{b,c}}.md", []string{"a.md", "b.md", "c.md"}},
+		{"[{]x", []string{"[{]x"}},
+		{`\{a,b\}`, []string{`\{a,b\}`}},
+	}
+	for _, test := range tests {
+		if got := ExpandBraces(test.glob); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ExpandBraces(%q) = %q, want %q", test.glob, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package mcp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// ProtocolVersion is the latest MCP protocol version this server implements
+const ProtocolVersion = "2025-06-18"
+
+// SupportedProtocolVersions are the MCP protocol versions this server can
+// speak, the latest first
+var SupportedProtocolVersions = []string{ProtocolVersion, "2025-03-26", "2024-11-05"}
+
+// JSON-RPC error codes
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	codeInternalError  = -32603
+)
+
+// Tool describes a tool exposed to clients
+type Tool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	InputSchema map[string]interface{} `json:"inputSchema"`
+	// Handler returns the text result of a call, or an error reported to the client as a tool error
+	Handler func(arguments json.RawMessage) (string, error) `json:"-"`
+}
+
+// Resource describes a readable resource
+type Resource struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+// Server is a minimal Model Context Protocol server over newline-delimited JSON-RPC
+type Server struct {
+	Name    string
+	Version string
+	Tools   []Tool
+	// ListResources and ReadResource are optional
+	ListResources func() ([]Resource, error)
+	ReadResource  func(uri string) (text string, mimeType string, err error)
+}
+
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Serve handles requests from r until it is closed, writing responses to w
+func (s *Server) Serve(r io.Reader, w io.Writer) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var req request
+		if err := json.Unmarshal(line, &req); err != nil {
+			if err := s.write(w, response{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &rpcError{Code: codeParseError, Message: err.Error()}}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		result, rpcErr := s.handle(&req)
+
+		// Notifications don't get a response
+		if len(req.ID) == 0 {
+			continue
+		}
+		resp := response{JSONRPC: "2.0", ID: req.ID, Result: result, Error: rpcErr}
+		if rpcErr == nil && result == nil {
+			resp.Result = struct{}{}
+		}
+		if err := s.write(w, resp); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func (s *Server) write(w io.Writer, resp response) error {
+	content, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(content, '\n'))
+	return err
+}
+
+func (s *Server) handle(req *request) (interface{}, *rpcError) {
+	if req.JSONRPC != "2.0" {
+		return nil, &rpcError{Code: codeInvalidRequest, Message: "jsonrpc must be \"2.0\""}
+	}
+
+	switch req.Method {
+	case "initialize":
+		var params struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		_ = json.Unmarshal(req.Params, &params)
+		// A version the client asked for is used if supported; otherwise the
+		// client decides whether it can use the latest one
+		version := ProtocolVersion
+		for _, supported := range SupportedProtocolVersions {
+			if params.ProtocolVersion == supported {
+				version = supported
+			}
+		}
+		capabilities := map[string]interface{}{"tools": map[string]interface{}{}}
+		if s.ListResources != nil {
+			capabilities["resources"] = map[string]interface{}{}
+		}
+		return map[string]interface{}{
+			"protocolVersion": version,
+			"capabilities":    capabilities,
+			"serverInfo":      map[string]string{"name": s.Name, "version": s.Version},
+		}, nil
+
+	case "ping":
+		return struct{}{}, nil
+
+	case "tools/list":
+		tools := s.Tools
+		if tools == nil {
+			tools = []Tool{}
+		}
+		return map[string]interface{}{"tools": tools}, nil
+
+	case "tools/call":
+		var params struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, &rpcError{Code: codeInvalidParams, Message: err.Error()}
+		}
+		for _, tool := range s.Tools {
+			if tool.Name != params.Name {
+				continue
+			}
+			arguments := params.Arguments
+			if len(arguments) == 0 {
+				arguments = json.RawMessage("{}")
+			}
+			text, err := tool.Handler(arguments)
+			if err != nil {
+				return toolResult(err.Error(), true), nil
+			}
+			return toolResult(text, false), nil
+		}
+		return nil, &rpcError{Code: codeInvalidParams, Message: fmt.Sprintf("unknown tool: %s", params.Name)}
+
+	case "resources/list":
+		if s.ListResources == nil {
+			return nil, &rpcError{Code: codeMethodNotFound, Message: "resources are not supported"}
+		}
+		resources, err := s.ListResources()
+		if err != nil {
+			return nil, &rpcError{Code: codeInternalError, Message: err.Error()}
+		}
+		if resources == nil {
+			resources = []Resource{}
+		}
+		return map[string]interface{}{"resources": resources}, nil
+
+	case "resources/read":
+		if s.ReadResource == nil {
+			return nil, &rpcError{Code: codeMethodNotFound, Message: "resources are not supported"}
+		}
+		var params struct {
+			URI string `json:"uri"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, &rpcError{Code: codeInvalidParams, Message: err.Error()}
+		}
+		text, mimeType, err := s.ReadResource(params.URI)
+		if err != nil {
+			return nil, &rpcError{Code: codeInvalidParams, Message: err.Error()}
+		}
+		return map[string]interface{}{
+			"contents": []map[string]string{{"uri": params.URI, "mimeType": mimeType, "text": text}},
+		}, nil
+
+	default:
+		if len(req.ID) == 0 {
+			// Unknown notifications (e.g. notifications/initialized) are ignored
+			return nil, nil
+		}
+		return nil, &rpcError{Code: codeMethodNotFound, Message: fmt.Sprintf("method not found: %s", req.Method)}
+	}
+}
+
+func toolResult(text string, isError bool) map[string]interface{} {
+	return map[string]interface{}{
+		"content": []map[string]string{{"type": "text", "text": text}},
+		"isError": isError,
+	}
+}
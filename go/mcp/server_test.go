@@ -0,0 +1,146 @@
+package mcp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// testServer has a tool echoing its argument and a single resource
+func testServer() *Server {
+	return &Server{
+		Name:    "test",
+		Version: "1.0.0",
+		Tools: []Tool{{
+			Name:        "echo",
+			InputSchema: map[string]interface{}{"type": "object"},
+			Handler: func(arguments json.RawMessage) (string, error) {
+				var args struct {
+					Text string `json:"text"`
+				}
+				if err := json.Unmarshal(arguments, &args); err != nil {
+					return "", err
+				}
+				if args.Text == "" {
+					return "", errors.New("'text' is required")
+				}
+				return args.Text, nil
+			},
+		}},
+		ListResources: func() ([]Resource, error) {
+			return []Resource{{URI: "file:///a.md", Name: "a.md"}}, nil
+		},
+		ReadResource: func(uri string) (string, string, error) {
+			if uri != "file:///a.md" {
+				return "", "", errors.New("unknown resource")
+			}
+			return "# A", "text/markdown", nil
+		},
+	}
+}
+
+// exchange sends the requests, one per line, as a stdio client would and
+// returns the responses, in order
+func exchange(t *testing.T, requests ...string) []map[string]interface{} {
+	t.Helper()
+	var out bytes.Buffer
+	if err := testServer().Serve(strings.NewReader(strings.Join(requests, "\n")+"\n"), &out); err != nil {
+		t.Fatalf("Serve() error = %v", err)
+	}
+	responses := []map[string]interface{}{}
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var resp map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &resp); err != nil {
+			t.Fatalf("invalid response %q: %v", line, err)
+		}
+		responses = append(responses, resp)
+	}
+	return responses
+}
+
+func TestInitialize(t *testing.T) {
+	tests := []struct {
+		requested string
+		want      string
+	}{
+		{requested: ProtocolVersion, want: ProtocolVersion},
+		{requested: "2024-11-05", want: "2024-11-05"},
+		{requested: "1999-01-01", want: ProtocolVersion},
+		{requested: "", want: ProtocolVersion},
+	}
+
+	for _, test := range tests {
+		t.Run(test.requested, func(t *testing.T) {
+			params, _ := json.Marshal(map[string]interface{}{"protocolVersion": test.requested, "capabilities": map[string]interface{}{}})
+			resp := exchange(t, `{"jsonrpc": "2.0", "id": 1, "method": "initialize", "params": `+string(params)+`}`)[0]
+			result, _ := resp["result"].(map[string]interface{})
+			if result["protocolVersion"] != test.want {
+				t.Errorf("protocolVersion = %v, want %s", result["protocolVersion"], test.want)
+			}
+			capabilities, _ := result["capabilities"].(map[string]interface{})
+			if _, ok := capabilities["resources"]; !ok {
+				t.Errorf("capabilities = %v, want resources", capabilities)
+			}
+		})
+	}
+}
+
+func TestSession(t *testing.T) {
+	responses := exchange(t,
+		`{"jsonrpc": "2.0", "id": 1, "method": "initialize", "params": {"protocolVersion": "2025-06-18"}}`,
+		`{"jsonrpc": "2.0", "method": "notifications/initialized"}`,
+		`{"jsonrpc": "2.0", "id": 2, "method": "tools/list"}`,
+		`{"jsonrpc": "2.0", "id": 3, "method": "tools/call", "params": {"name": "echo", "arguments": {"text": "hi"}}}`,
+		`{"jsonrpc": "2.0", "id": 4, "method": "tools/call", "params": {"name": "echo"}}`,
+		`{"jsonrpc": "2.0", "id": 5, "method": "tools/call", "params": {"name": "missing"}}`,
+		`{"jsonrpc": "2.0", "id": "r", "method": "resources/read", "params": {"uri": "file:///a.md"}}`,
+		`{"jsonrpc": "2.0", "id": 7, "method": "unknown"}`,
+		`not json`,
+	)
+	// Every request but the notification gets a response
+	if len(responses) != 8 {
+		t.Fatalf("got %d responses, want 8: %v", len(responses), responses)
+	}
+
+	tools := responses[1]["result"].(map[string]interface{})["tools"].([]interface{})
+	if len(tools) != 1 || tools[0].(map[string]interface{})["name"] != "echo" {
+		t.Errorf("tools/list = %v, want the echo tool", tools)
+	}
+
+	for i, want := range map[int]struct {
+		text    string
+		isError bool
+	}{2: {"hi", false}, 3: {"'text' is required", true}} {
+		result := responses[i]["result"].(map[string]interface{})
+		content := result["content"].([]interface{})[0].(map[string]interface{})
+		if content["text"] != want.text || result["isError"] != want.isError {
+			t.Errorf("tools/call %d = %v, want %q with isError %t", i, result, want.text, want.isError)
+		}
+	}
+
+	if resp := responses[5]; resp["id"] != "r" || !strings.Contains(mustJSON(t, resp["result"]), `"text":"# A"`) {
+		t.Errorf("resources/read = %v, want the resource text with the string id", resp)
+	}
+
+	// Unknown tools and methods and invalid JSON are protocol errors
+	for i, code := range map[int]float64{4: -32602, 6: -32601, 7: -32700} {
+		rpcErr, _ := responses[i]["error"].(map[string]interface{})
+		if rpcErr["code"] != code {
+			t.Errorf("response %d = %v, want error %v", i, responses[i], code)
+		}
+	}
+}
+
+func mustJSON(t *testing.T, value interface{}) string {
+	t.Helper()
+	content, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
@@ -2,7 +2,7 @@ package models
 
 // RuleCacheEntry represents a single rule in the cache
 type RuleCacheEntry struct {
-	Patterns         interface{} `json:"patterns"`         // string or []string
+	Patterns         interface{} `json:"patterns"` // string or []string
 	Path             string      `json:"path"`
 	IgnorePatterns   []string    `json:"ignorePatterns"`
 	Priority         *int        `json:"priority,omitempty"`
@@ -10,12 +10,16 @@ type RuleCacheEntry struct {
 	ReferencesIfTop  []string    `json:"referencesIfTop"`
 	ReferencesAlways []string    `json:"referencesAlways"`
 	Order            *int        `json:"order,omitempty"`
+	When             interface{} `json:"when,omitempty"`     // raw `when` condition block
+	Template         bool        `json:"template,omitempty"` // render the body with text/template
+	Scope            string      `json:"scope,omitempty"`    // "directory" for directory-scoped rules
 }
 
 // RuleWithDepth extends RuleCacheEntry with agent depth tracking
 type RuleWithDepth struct {
 	RuleCacheEntry
 	AgentDepth int
+	Section    string // heading to print instead of the whole body, if set
 }
 
 // GetPatterns returns patterns as a string slice
@@ -41,15 +45,50 @@ type AgentConfig struct {
 	RuleFilePattern string   `json:"ruleFilePattern"`
 	CommandGroup    *string  `json:"commandGroup"` // nullable string
 	References      []string `json:"references,omitempty"`
+	DirectoryRules  string   `json:"directoryRules,omitempty"` // "nearest" or "all-ancestors"
+	// ClaudeAgent describes the .claude/agents/<name>.md file generated for this agent
+	ClaudeAgent *ClaudeAgentConfig `json:"claudeAgent,omitempty"`
+}
+
+// ClaudeAgentConfig holds the fields of a generated Claude Code agent definition
+type ClaudeAgentConfig struct {
+	Name         string   `json:"name,omitempty"` // defaults to the agent name
+	Description  string   `json:"description,omitempty"`
+	Tools        []string `json:"tools,omitempty"`
+	Model        string   `json:"model,omitempty"`
+	Color        string   `json:"color,omitempty"`
+	Instructions string   `json:"instructions,omitempty"` // appended after the invocation instruction
 }
 
 // Config represents the main configuration file
 type Config struct {
-	Exclude []string                `json:"exclude"`
-	Agents  map[string]*AgentConfig `json:"agents"`
+	Exclude   []string                `json:"exclude"`
+	Agents    map[string]*AgentConfig `json:"agents"`
+	Variables map[string]string       `json:"variables,omitempty"` // available to templated rule bodies
+	UsageLog  bool                    `json:"usageLog,omitempty"`  // append every load to UsageLogFilePath
 }
 
+// Rule scopes
+const (
+	ScopeDirectory = "directory"
+)
+
+// Directory rule resolution modes
+const (
+	DirectoryRulesNearest      = "nearest"
+	DirectoryRulesAllAncestors = "all-ancestors"
+)
+
 const (
-	ConfigFilePath    = ".config/code-editor-agent.jsonc"
-	RuleCacheFilePath = ".claude/agents/code-editor/rules-cache-generated.json"
+	ConfigFilePath = ".config/code-editor-agent.jsonc"
+	// ConfigYAMLFilePath and ConfigTOMLFilePath are alternatives to ConfigFilePath
+	ConfigYAMLFilePath = ".config/code-editor-agent.yaml"
+	ConfigTOMLFilePath = ".config/code-editor-agent.toml"
+	RuleCacheFilePath  = ".claude/agents/code-editor/rules-cache-generated.json"
+	// TemplateBaseFilePath records the templates written by init, for upgrade
+	TemplateBaseFilePath = ".claude/agents/code-editor/templates-base-generated.json"
+	// UsageLogFilePath is the local log of loaded rules, written if usageLog is set
+	UsageLogFilePath = ".claude/agents/code-editor/usage-log.jsonl"
+	// HookStateDirPath holds the files shown per session by the block-mode hook
+	HookStateDirPath = ".claude/agents/code-editor/hook-sessions"
 )
@@ -0,0 +1,279 @@
+package resolver
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/dirt-rain/code-editor-agent/conditions"
+	"github.com/dirt-rain/code-editor-agent/frontmatter"
+	"github.com/dirt-rain/code-editor-agent/matcher"
+	"github.com/dirt-rain/code-editor-agent/models"
+	"github.com/dirt-rain/code-editor-agent/utils"
+)
+
+// FrontMatter represents the front matter in rule files
+type FrontMatter struct {
+	Patterns         interface{} `yaml:"patterns"`
+	IgnorePatterns   interface{} `yaml:"ignorePatterns"`
+	Priority         *int        `yaml:"priority"`
+	Tags             interface{} `yaml:"tags"`
+	ReferencesIfTop  interface{} `yaml:"referencesIfTop"`
+	ReferencesAlways interface{} `yaml:"referencesAlways"`
+	Order            *int        `yaml:"order"`
+	When             interface{} `yaml:"when"`
+	Template         bool        `yaml:"template"`
+	Scope            string      `yaml:"scope"`
+}
+
+// BuildCache scans the rule files of every agent of the project in fsys
+func BuildCache(fsys fs.FS, cfg *models.Config) (Cache, error) {
+	cache := make(Cache, len(cfg.Agents))
+	for agentName := range cfg.Agents {
+		rules, err := BuildAgentRules(fsys, cfg, agentName)
+		if err != nil {
+			return nil, err
+		}
+		cache[agentName] = rules
+	}
+	return cache, nil
+}
+
+// BuildAgentRules scans the rule files of an agent and validates their front
+// matter, returning the cache entries sorted by path
+func BuildAgentRules(fsys fs.FS, cfg *models.Config, agentName string) ([]models.RuleCacheEntry, error) {
+	agentConfig, ok := cfg.Agents[agentName]
+	if !ok {
+		return nil, fmt.Errorf("Agent '%s' not found in configuration.", agentName)
+	}
+
+	result := []models.RuleCacheEntry{}
+
+	// Find all rule files matching the pattern
+	ruleFiles, err := FindFiles(fsys, agentConfig.RuleFilePattern, cfg.Exclude)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find rule files for agent '%s': %w", agentName, err)
+	}
+
+	for _, ruleFile := range ruleFiles {
+		content, err := fs.ReadFile(fsys, ruleFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read rule file %s: %w", ruleFile, err)
+		}
+
+		// Parse front matter
+		fm, err := parseFrontMatter(content)
+		if errors.Is(err, errNoFrontMatter) && agentConfig.DirectoryRules != "" {
+			// Plain markdown files are directory rules when the agent opts in
+			fm, err = &FrontMatter{}, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse front matter in %s: %w", ruleFile, err)
+		}
+
+		// Validate scope; rules without patterns are directory-scoped if the agent opts in
+		scope := fm.Scope
+		if scope != "" && scope != models.ScopeDirectory {
+			return nil, fmt.Errorf("Rule file %s: 'scope' must be \"%s\".", ruleFile, models.ScopeDirectory)
+		}
+		if scope == "" && fm.Patterns == nil && agentConfig.DirectoryRules != "" {
+			scope = models.ScopeDirectory
+		}
+
+		if scope == models.ScopeDirectory {
+			if fm.Patterns != nil {
+				return nil, fmt.Errorf("Rule file %s: directory-scoped rules cannot have 'patterns'.", ruleFile)
+			}
+			fm.Patterns = []string{}
+		}
+
+		if fm.Patterns == nil {
+			return nil, fmt.Errorf("Rule file %s is missing 'patterns' attribute.", ruleFile)
+		}
+
+		patterns, err := utils.NormalizeToStringArray(fm.Patterns,
+			fmt.Sprintf("Rule file %s: 'patterns' must be a string or array of strings.", ruleFile))
+		if err != nil {
+			return nil, err
+		}
+		if err := matcher.ValidatePatterns(patterns); err != nil {
+			return nil, fmt.Errorf("Rule file %s: 'patterns' has %v.", ruleFile, err)
+		}
+
+		// Validate priority
+		if fm.Priority != nil && *fm.Priority < 0 {
+			return nil, fmt.Errorf("Rule file %s: 'priority' must be a non-negative number.", ruleFile)
+		}
+
+		// Validate order
+		if fm.Order != nil && *fm.Order < 0 {
+			return nil, fmt.Errorf("Rule file %s: 'order' must be a non-negative number.", ruleFile)
+		}
+
+		ignorePatterns, err := utils.NormalizeToStringArray(fm.IgnorePatterns,
+			fmt.Sprintf("Rule file %s: 'ignorePatterns' must be a string or array of strings.", ruleFile))
+		if err != nil {
+			return nil, err
+		}
+		if err := matcher.ValidateGlobs(ignorePatterns); err != nil {
+			return nil, fmt.Errorf("Rule file %s: 'ignorePatterns' has %v.", ruleFile, err)
+		}
+
+		tags, err := utils.NormalizeToStringArray(fm.Tags,
+			fmt.Sprintf("Rule file %s: 'tags' must be a string or array of strings.", ruleFile))
+		if err != nil {
+			return nil, err
+		}
+
+		referencesIfTop, err := utils.NormalizeToStringArray(fm.ReferencesIfTop,
+			fmt.Sprintf("Rule file %s: 'referencesIfTop' must be a string or array of strings.", ruleFile))
+		if err != nil {
+			return nil, err
+		}
+
+		referencesAlways, err := utils.NormalizeToStringArray(fm.ReferencesAlways,
+			fmt.Sprintf("Rule file %s: 'referencesAlways' must be a string or array of strings.", ruleFile))
+		if err != nil {
+			return nil, err
+		}
+
+		// Validate references to plain markdown documents
+		for _, ref := range append(append([]string{}, referencesIfTop...), referencesAlways...) {
+			target, _ := SplitReference(ref)
+			if IsDocumentReference(target) && !fileExists(fsys, target) {
+				return nil, fmt.Errorf("Rule file %s: referenced document %s does not exist.", ruleFile, target)
+			}
+		}
+
+		// Validate when
+		if fm.When != nil {
+			if _, err := conditions.Parse(fm.When); err != nil {
+				return nil, fmt.Errorf("Rule file %s: %v.", ruleFile, err)
+			}
+		}
+
+		// Validate template syntax early instead of at load time
+		if fm.Template {
+			if err := validateTemplate(fsys, ruleFile); err != nil {
+				return nil, fmt.Errorf("Rule file %s: invalid template: %w", ruleFile, err)
+			}
+		}
+
+		rule := models.RuleCacheEntry{
+			Patterns:         fm.Patterns,
+			Path:             ruleFile,
+			IgnorePatterns:   ignorePatterns,
+			Priority:         fm.Priority,
+			Tags:             tags,
+			ReferencesIfTop:  referencesIfTop,
+			ReferencesAlways: referencesAlways,
+			Order:            fm.Order,
+			When:             fm.When,
+			Template:         fm.Template,
+			Scope:            scope,
+		}
+		result = append(result, rule)
+	}
+
+	// Sort to prevent confusing git diffs
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Path < result[j].Path
+	})
+	return result, nil
+}
+
+// FindFiles finds all files of fsys matching the pattern, excluding patterns in exclude
+func FindFiles(fsys fs.FS, pattern string, exclude []string, opts ...doublestar.GlobOption) ([]string, error) {
+	matches, err := doublestar.Glob(fsys, pattern, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Filter out excluded patterns
+	result := []string{}
+	for _, match := range matches {
+		if !IsExcluded(match, exclude) {
+			result = append(result, match)
+		}
+	}
+
+	return result, nil
+}
+
+// IsExcluded reports whether filePath matches one of the exclude patterns
+func IsExcluded(filePath string, exclude []string) bool {
+	for _, excludePattern := range exclude {
+		// Remove leading ./ from exclude pattern
+		cleanPattern := excludePattern
+		if len(cleanPattern) > 2 && cleanPattern[0:2] == "./" {
+			cleanPattern = cleanPattern[2:]
+		}
+		if matched, _ := doublestar.Match(cleanPattern, filePath); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// fsPath converts a project-relative path to a path of the project fs.FS
+func fsPath(filePath string) string {
+	return path.Clean(filepath.ToSlash(filePath))
+}
+
+func fileExists(fsys fs.FS, filePath string) bool {
+	_, err := fs.Stat(fsys, fsPath(filePath))
+	return err == nil
+}
+
+// ExtractBody extracts the body (content after front matter) from a file
+func ExtractBody(fsys fs.FS, filePath string) (string, error) {
+	content, err := fs.ReadFile(fsys, fsPath(filePath))
+	if err != nil {
+		return "", err
+	}
+
+	doc, err := frontmatter.Split(content)
+	if err != nil {
+		// Unclosed front matter is part of the body
+		return string(content), nil
+	}
+	return doc.Body, nil
+}
+
+// FrontMatterFormat returns the front matter format of a rule file, empty for
+// files without front matter
+func FrontMatterFormat(fsys fs.FS, filePath string) (frontmatter.Format, error) {
+	content, err := fs.ReadFile(fsys, fsPath(filePath))
+	if err != nil {
+		return "", err
+	}
+	doc, err := frontmatter.Split(content)
+	if err != nil {
+		return "", err
+	}
+	return doc.Format, nil
+}
+
+// errNoFrontMatter is returned by parseFrontMatter for files without front matter
+var errNoFrontMatter = errors.New("front matter not found")
+
+// parseFrontMatter extracts the front matter from markdown content
+func parseFrontMatter(content []byte) (*FrontMatter, error) {
+	doc, err := frontmatter.Split(content)
+	if err != nil {
+		return nil, err
+	}
+	if doc.Format == "" {
+		return nil, errNoFrontMatter
+	}
+
+	var fm FrontMatter
+	if err := doc.Decode(&fm); err != nil {
+		return nil, err
+	}
+	return &fm, nil
+}
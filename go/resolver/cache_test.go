@@ -0,0 +1,98 @@
+package resolver
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FuzzFrontMatterRoundTrip writes a rule file from a pattern and a body, and
+// checks that parseFrontMatter and ExtractBody read them back
+func FuzzFrontMatterRoundTrip(f *testing.F) {
+	f.Add("src/**", "# Rule\n", false, uint8(0))
+	f.Add("**/*.ts", "---\nnot front matter\n---\n", true, uint8(0))
+	f.Add("!a/**", "+++\n", false, uint8(1))
+	f.Add("", "", true, uint8(1))
+	f.Add("a\"b", "{}\n", false, uint8(2))
+	f.Add("src/**", ";;;\n", true, uint8(3))
+
+	f.Fuzz(func(t *testing.T, pattern, body string, crlf bool, format uint8) {
+		// Line breaks in YAML block scalars are normalized
+		if !utf8.ValidString(pattern) || strings.ContainsAny(pattern, "\r\n") || strings.HasPrefix(body, "\n") || strings.HasPrefix(body, "\r\n") {
+			t.Skip()
+		}
+
+		var content string
+		switch format % 4 {
+		case 0:
+			encoded, err := yaml.Marshal(map[string]string{"patterns": pattern})
+			var decoded map[string]string
+			if err != nil || yaml.Unmarshal(encoded, &decoded) != nil || decoded["patterns"] != pattern {
+				t.Skip()
+			}
+			content = "---\n" + string(encoded) + "---\n"
+		case 1:
+			quoted := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\t", `\t`).Replace(pattern)
+			if strings.ContainsFunc(quoted, func(r rune) bool { return r < 0x20 || r == 0x7f }) {
+				t.Skip()
+			}
+			content = "+++\npatterns = \"" + quoted + "\"\n+++\n"
+		default:
+			encoded, err := json.Marshal(map[string]string{"patterns": pattern})
+			if err != nil {
+				t.Skip()
+			}
+			content = string(encoded) + "\n"
+			if format%4 == 3 {
+				content = ";;;\n" + content + ";;;\n"
+			}
+		}
+		if crlf {
+			content = strings.ReplaceAll(content, "\n", "\r\n")
+		}
+		content += body
+
+		fm, err := parseFrontMatter([]byte(content))
+		if err != nil {
+			t.Fatalf("parseFrontMatter(%q) error = %v", content, err)
+		}
+		if fm.Patterns != pattern {
+			t.Fatalf("parseFrontMatter(%q) patterns = %q, want %q", content, fm.Patterns, pattern)
+		}
+
+		extracted, err := ExtractBody(fstest.MapFS{"rule.md": {Data: []byte(content)}}, "rule.md")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if extracted != body {
+			t.Fatalf("ExtractBody(%q) = %q, want %q", content, extracted, body)
+		}
+	})
+}
+
+// FuzzExtractBody checks that ExtractBody agrees with parseFrontMatter on
+// whether a file has front matter
+func FuzzExtractBody(f *testing.F) {
+	f.Add([]byte("---\npatterns: \"**\"\n---\n# Rule\n"))
+	f.Add([]byte("\ufeff# Rule\n"))
+	f.Add([]byte("---\n"))
+
+	f.Fuzz(func(t *testing.T, content []byte) {
+		extracted, err := ExtractBody(fstest.MapFS{"rule.md": {Data: content}}, "rule.md")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := parseFrontMatter(content); errors.Is(err, errNoFrontMatter) {
+			if want := strings.TrimPrefix(string(content), "\ufeff"); extracted != want {
+				t.Fatalf("ExtractBody(%q) = %q, want the whole file", content, extracted)
+			}
+		} else if len(extracted) > len(content) {
+			t.Fatalf("ExtractBody(%q) = %q is longer than the file", content, extracted)
+		}
+	})
+}
@@ -0,0 +1,116 @@
+package resolver
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// maxIncludeDepth bounds nested includes even without a cycle
+const maxIncludeDepth = 16
+
+// templateData is the data available to templated rule bodies
+type templateData struct {
+	FilePath string            // target file path as given on the command line
+	Dir      string            // directory of the target file
+	Ext      string            // extension of the target file, including the dot
+	Agent    string            // name of the agent being loaded
+	Vars     map[string]string // variables from the config file
+}
+
+func newTemplateData(agentName, filePath string, variables map[string]string) *templateData {
+	if variables == nil {
+		variables = map[string]string{}
+	}
+	return &templateData{
+		FilePath: filePath,
+		Dir:      path.Dir(filepath.ToSlash(filePath)),
+		Ext:      path.Ext(filePath),
+		Agent:    agentName,
+		Vars:     variables,
+	}
+}
+
+// renderBody renders a rule body with text/template. name is the path of the
+// file the body came from and is used for error messages and cycle detection.
+func renderBody(fsys fs.FS, name, body string, data *templateData) (string, error) {
+	return renderWithStack(fsys, name, body, data, []string{name})
+}
+
+// validateTemplate checks that the body of a rule file parses as a template
+func validateTemplate(fsys fs.FS, ruleFile string) error {
+	body, err := ExtractBody(fsys, ruleFile)
+	if err != nil {
+		return err
+	}
+	_, err = template.New(ruleFile).Funcs(templateFuncs(fsys, nil, nil)).Parse(body)
+	return err
+}
+
+// templateFuncs returns the restricted function set available to rule bodies
+func templateFuncs(fsys fs.FS, data *templateData, stack []string) template.FuncMap {
+	return template.FuncMap{
+		"include": func(includePath string) (string, error) {
+			return renderInclude(fsys, includePath, data, stack)
+		},
+		"lower": strings.ToLower,
+		"upper": strings.ToUpper,
+	}
+}
+
+func renderWithStack(fsys fs.FS, name, body string, data *templateData, stack []string) (string, error) {
+	tmpl, err := template.New(name).Funcs(templateFuncs(fsys, data, stack)).Option("missingkey=error").Parse(body)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// renderInclude reads and renders a snippet relative to the project root
+func renderInclude(fsys fs.FS, includePath string, data *templateData, stack []string) (string, error) {
+	cleanPath, err := cleanIncludePath(includePath)
+	if err != nil {
+		return "", err
+	}
+
+	for _, p := range stack {
+		if p == cleanPath {
+			return "", fmt.Errorf("include cycle: %s -> %s", strings.Join(stack, " -> "), cleanPath)
+		}
+	}
+	if len(stack) > maxIncludeDepth {
+		return "", fmt.Errorf("includes nested deeper than %d levels", maxIncludeDepth)
+	}
+
+	body, err := ExtractBody(fsys, cleanPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to include %s: %w", cleanPath, err)
+	}
+
+	rendered, err := renderWithStack(fsys, cleanPath, body, data, append(stack[:len(stack):len(stack)], cleanPath))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(rendered, "\n"), nil
+}
+
+// cleanIncludePath normalizes an include path and rejects paths outside the project root
+func cleanIncludePath(includePath string) (string, error) {
+	slashed := filepath.ToSlash(includePath)
+	if path.IsAbs(slashed) || filepath.IsAbs(includePath) {
+		return "", fmt.Errorf("include path must be relative to the project root: %s", includePath)
+	}
+	cleanPath := path.Clean(slashed)
+	if cleanPath == ".." || strings.HasPrefix(cleanPath, "../") {
+		return "", fmt.Errorf("include path must not leave the project root: %s", includePath)
+	}
+	return cleanPath, nil
+}
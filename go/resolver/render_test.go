@@ -0,0 +1,73 @@
+package resolver
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestRenderBody(t *testing.T) {
+	fsys := fstest.MapFS{
+		"snippets/errors.md":  {Data: []byte("---\ntags: x\n---\nWrap errors in {{.Ext}} files.\n")},
+		"snippets/outer.md":   {Data: []byte("Outer: {{include \"snippets/errors.md\"}}\n")},
+		"snippets/a.md":       {Data: []byte("A {{include \"snippets/b.md\"}}")},
+		"snippets/b.md":       {Data: []byte("B {{include \"./snippets/a.md\"}}")},
+		"snippets/escape.md":  {Data: []byte("{{include \"../outside.md\"}}")},
+		"snippets/missing.md": {Data: []byte("{{include \"snippets/nope.md\"}}")},
+	}
+	data := newTemplateData("code-editor", "src/api/handler.go", map[string]string{"team": "Backend"})
+
+	tests := []struct {
+		name    string
+		body    string
+		want    string
+		wantErr string
+	}{
+		{name: "data", body: "{{.Agent}} {{.FilePath}} {{.Dir}} {{.Ext}} {{upper .Vars.team}}", want: "code-editor src/api/handler.go src/api .go BACKEND"},
+		{name: "include without front matter and trailing newline", body: "- {{include \"snippets/errors.md\"}}\n", want: "- Wrap errors in .go files.\n"},
+		{name: "nested include", body: "{{include \"snippets/outer.md\"}}", want: "Outer: Wrap errors in .go files."},
+		{name: "unclean include path", body: "{{include \"./snippets/../snippets/errors.md\"}}", want: "Wrap errors in .go files."},
+		{name: "missing variable", body: "{{.Vars.unknown}}", wantErr: "unknown"},
+		{name: "include cycle", body: "{{include \"snippets/a.md\"}}", wantErr: "include cycle: rule.md -> snippets/a.md -> snippets/b.md -> snippets/a.md"},
+		{name: "self include", body: "{{include \"rule.md\"}}", wantErr: "include cycle: rule.md -> rule.md"},
+		{name: "parent directory", body: "{{include \"../secrets.md\"}}", wantErr: "must not leave the project root"},
+		{name: "parent directory after cleaning", body: "{{include \"snippets/../../secrets.md\"}}", wantErr: "must not leave the project root"},
+		{name: "absolute path", body: "{{include \"/etc/passwd\"}}", wantErr: "must be relative to the project root"},
+		{name: "escape from an include", body: "{{include \"snippets/escape.md\"}}", wantErr: "must not leave the project root"},
+		{name: "missing include", body: "{{include \"snippets/missing.md\"}}", wantErr: "failed to include snippets/nope.md"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := renderBody(fsys, "rule.md", test.body, data)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Errorf("renderBody() = %q, %v, want error containing %q", got, err, test.wantErr)
+				}
+				return
+			}
+			if err != nil || got != test.want {
+				t.Errorf("renderBody() = %q, %v, want %q", got, err, test.want)
+			}
+		})
+	}
+}
+
+func TestRenderIncludeDepth(t *testing.T) {
+	fsys := fstest.MapFS{}
+	for i := 0; i < maxIncludeDepth+2; i++ {
+		fsys[fmt.Sprintf("%d.md", i)] = &fstest.MapFile{Data: []byte(fmt.Sprintf("{{include \"%d.md\"}}", i+1))}
+	}
+	fsys[fmt.Sprintf("%d.md", maxIncludeDepth+2)] = &fstest.MapFile{Data: []byte("end")}
+
+	_, err := renderBody(fsys, "rule.md", "{{include \"0.md\"}}", newTemplateData("code-editor", "a.go", nil))
+	if err == nil || !strings.Contains(err.Error(), "nested deeper than") {
+		t.Errorf("renderBody() error = %v, want the depth limit", err)
+	}
+
+	got, err := renderBody(fsys, "rule.md", fmt.Sprintf("{{include \"%d.md\"}}", 4), newTemplateData("code-editor", "a.go", nil))
+	if err != nil || got != "end" {
+		t.Errorf("renderBody() = %q, %v, want the end of a shallow chain", got, err)
+	}
+}
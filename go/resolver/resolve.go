@@ -0,0 +1,475 @@
+package resolver
+
+import (
+	"fmt"
+	"math"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dirt-rain/code-editor-agent/conditions"
+	"github.com/dirt-rain/code-editor-agent/matcher"
+	"github.com/dirt-rain/code-editor-agent/models"
+)
+
+// Rule statuses of a resolution
+const (
+	StatusPrinted   = "printed"
+	StatusDropped   = "dropped"
+	StatusSkipped   = "skipped"
+	StatusUnmatched = "unmatched"
+)
+
+// ruleKey identifies a rule (or one of its sections) loaded through a specific agent
+type ruleKey struct {
+	path    string
+	depth   int
+	section string
+}
+
+func keyOf(rule models.RuleWithDepth) ruleKey {
+	return ruleKey{path: rule.Path, depth: rule.AgentDepth, section: rule.Section}
+}
+
+// DisplayName returns the rule path, with the section appended if set
+func DisplayName(rule models.RuleWithDepth) string {
+	if rule.Section != "" {
+		return rule.Path + "#" + rule.Section
+	}
+	return rule.Path
+}
+
+// SplitReference splits a reference like "style#Error handling" into its
+// target and section parts
+func SplitReference(ref string) (target, section string) {
+	if idx := strings.Index(ref, "#"); idx >= 0 {
+		return ref[:idx], strings.TrimSpace(ref[idx+1:])
+	}
+	return ref, ""
+}
+
+// IsDocumentReference reports whether a reference target is a markdown
+// document path rather than a tag: it ends in .md or starts with ./, so tags
+// like team/backend keep referring to tags
+func IsDocumentReference(target string) bool {
+	return strings.HasSuffix(target, ".md") || strings.HasPrefix(target, "./")
+}
+
+// RuleResult records what happened to a single rule during resolution
+type RuleResult struct {
+	Rule   models.RuleWithDepth
+	Status string
+	Reason string
+}
+
+// Resolution is the outcome of resolving an agent's rules for a file path
+type Resolution struct {
+	AgentName string
+	Agents    []string
+	FilePath  string
+	// Candidates are all rules selected before priority filtering
+	Candidates []models.RuleWithDepth
+	// Final are the rules to print, in output order
+	Final []models.RuleWithDepth
+	// Results has one entry per rule of every loaded agent, in cache order
+	Results []RuleResult
+}
+
+// Dropped returns the candidates removed by priority filtering
+func (res *Resolution) Dropped() []models.RuleWithDepth {
+	printed := make(map[ruleKey]bool, len(res.Final))
+	for _, rule := range res.Final {
+		printed[keyOf(rule)] = true
+	}
+	dropped := []models.RuleWithDepth{}
+	for _, rule := range res.Candidates {
+		if !printed[keyOf(rule)] {
+			dropped = append(dropped, rule)
+		}
+	}
+	return dropped
+}
+
+// Explain selects the rules of agentName (and the agents it references)
+// that apply to filePath, recording the status of every rule and why
+func (r *Resolver) Explain(agentName, filePath string) (*Resolution, error) {
+	cfg := r.Config
+	agentsToLoad, allRules, err := r.AgentRules(agentName)
+	if err != nil {
+		return nil, err
+	}
+
+	// Evaluate `when` conditions; rules whose condition fails can't be loaded at all
+	skipped := make(map[ruleKey]string)
+	notes := make(map[ruleKey]string)
+	eligibleRules := []models.RuleWithDepth{}
+	for _, rule := range allRules {
+		if rule.When != nil {
+			cond, err := conditions.Parse(rule.When)
+			if err != nil {
+				return nil, fmt.Errorf("Rule file %s: %v.", rule.Path, err)
+			}
+			ok, reason := cond.Evaluate(r.Context)
+			if !ok {
+				skipped[keyOf(rule)] = "when: " + reason
+				continue
+			}
+			notes[keyOf(rule)] = "when: " + reason
+		}
+		eligibleRules = append(eligibleRules, rule)
+	}
+
+	tagMap := BuildTagMap(eligibleRules)
+
+	// With "nearest" resolution only the deepest directory containing the
+	// file contributes directory-scoped rules, per agent
+	cleanFilePath := path.Clean(filepath.ToSlash(filePath))
+	nearestDepth := make(map[int]int)
+	for _, rule := range eligibleRules {
+		if rule.Scope == models.ScopeDirectory && inDirectory(RuleDir(rule), cleanFilePath) {
+			if depth := scopeDepth(rule); depth > nearestDepth[rule.AgentDepth] {
+				nearestDepth[rule.AgentDepth] = depth
+			}
+		}
+	}
+
+	// Find top-level rules that match the file path
+	topLevelReasons := make(map[ruleKey]string)
+	topLevelRules := []models.RuleWithDepth{}
+	for _, rule := range eligibleRules {
+		if rule.Scope == models.ScopeDirectory {
+			dir := RuleDir(rule)
+			if !inDirectory(dir, cleanFilePath) {
+				continue
+			}
+			mode := directoryRulesMode(cfg, agentsToLoad[rule.AgentDepth])
+			if mode == models.DirectoryRulesNearest && scopeDepth(rule) < nearestDepth[rule.AgentDepth] {
+				notes[keyOf(rule)] = joinReasons(notes[keyOf(rule)], "shadowed by a nearer directory rule (nearest)")
+				continue
+			}
+			topLevelReasons[keyOf(rule)] = fmt.Sprintf("in directory scope of %s (%s)", dir, mode)
+			topLevelRules = append(topLevelRules, rule)
+		} else if matcher.Match(rule.GetPatterns(), rule.IgnorePatterns, filePath) {
+			topLevelReasons[keyOf(rule)] = "matched patterns"
+			topLevelRules = append(topLevelRules, rule)
+		}
+	}
+
+	// Collect all rules to load (including referenced rules)
+	collector := newRuleCollector(tagMap)
+	for _, rule := range topLevelRules {
+		collector.add(rule, true, topLevelReasons[keyOf(rule)])
+	}
+	loadReasons := collector.reasons
+	candidateRules := collector.collected()
+
+	res := &Resolution{
+		AgentName:  agentName,
+		Agents:     agentsToLoad,
+		FilePath:   filePath,
+		Candidates: append([]models.RuleWithDepth{}, candidateRules...),
+	}
+
+	// Sort by [priority ASC, order DESC, agentDepth ASC, scopeDepth ASC, filePath ASC] for priority filtering
+	sort.Slice(candidateRules, func(i, j int) bool {
+		priorityA := rulePriority(candidateRules[i])
+		priorityB := rulePriority(candidateRules[j])
+		if priorityA != priorityB {
+			return priorityA < priorityB
+		}
+
+		orderA := ruleOrder(candidateRules[i])
+		orderB := ruleOrder(candidateRules[j])
+		if orderA != orderB {
+			return orderA > orderB // DESC: higher order gets filtered out first
+		}
+
+		if candidateRules[i].AgentDepth != candidateRules[j].AgentDepth {
+			return candidateRules[i].AgentDepth < candidateRules[j].AgentDepth // ASC
+		}
+
+		if scopeDepth(candidateRules[i]) != scopeDepth(candidateRules[j]) {
+			return scopeDepth(candidateRules[i]) < scopeDepth(candidateRules[j]) // ASC: outer directories get filtered out first
+		}
+
+		if candidateRules[i].Path != candidateRules[j].Path {
+			return candidateRules[i].Path < candidateRules[j].Path
+		}
+
+		return candidateRules[i].Section < candidateRules[j].Section
+	})
+
+	// Apply priority filtering
+	dropped := make(map[ruleKey]string)
+	finalRules := []models.RuleWithDepth{}
+	totalRulesToPrint := len(candidateRules)
+
+	for _, rule := range candidateRules {
+		priority := rulePriority(rule)
+		if totalRulesToPrint > priority {
+			// Skip this rule and decrement the count
+			dropped[keyOf(rule)] = fmt.Sprintf("priority %d is lower than the %d rules left to print", priority, totalRulesToPrint)
+			totalRulesToPrint--
+		} else {
+			// Include this rule
+			finalRules = append(finalRules, rule)
+		}
+	}
+
+	// Sort final rules by [order ASC, agentDepth ASC, scopeDepth ASC, filePath ASC] for output
+	SortForOutput(finalRules)
+	res.Final = finalRules
+
+	cached := make(map[ruleKey]bool, len(allRules))
+	for _, rule := range allRules {
+		cached[keyOf(rule)] = true
+		res.Results = append(res.Results, resultOf(rule, skipped, dropped, loadReasons, notes))
+	}
+
+	// Sections and documents loaded through references come after whole rules
+	for _, rule := range res.Candidates {
+		if !cached[keyOf(rule)] {
+			res.Results = append(res.Results, resultOf(rule, skipped, dropped, loadReasons, notes))
+		}
+	}
+
+	return res, nil
+}
+
+// AgentRules returns the agents loaded for agentName (itself first, then
+// the agents it references) and all their rules with depth tracking
+func (r *Resolver) AgentRules(agentName string) ([]string, []models.RuleWithDepth, error) {
+	agentConfig, ok := r.Config.Agents[agentName]
+	if !ok {
+		return nil, nil, fmt.Errorf("Agent '%s' not found in configuration.", agentName)
+	}
+
+	// Collect all agents to load (current + references)
+	agentsToLoad := []string{agentName}
+	if agentConfig.References != nil {
+		agentsToLoad = append(agentsToLoad, agentConfig.References...)
+	}
+
+	// Load rules from all specified agents with depth tracking
+	allRules := []models.RuleWithDepth{}
+	for i, agent := range agentsToLoad {
+		if rules, ok := r.Cache[agent]; ok {
+			for _, rule := range rules {
+				allRules = append(allRules, models.RuleWithDepth{
+					RuleCacheEntry: rule,
+					AgentDepth:     i,
+				})
+			}
+		} else {
+			r.warn("No rules found for agent '%s' in cache", agent)
+		}
+	}
+	return agentsToLoad, allRules, nil
+}
+
+// BuildTagMap indexes rules by tag for quick lookup
+func BuildTagMap(rules []models.RuleWithDepth) map[string][]models.RuleWithDepth {
+	tagMap := make(map[string][]models.RuleWithDepth)
+	for _, rule := range rules {
+		for _, tag := range rule.Tags {
+			tagMap[tag] = append(tagMap[tag], rule)
+		}
+	}
+	return tagMap
+}
+
+// ruleCollector collects rules together with the rules they reference
+type ruleCollector struct {
+	tagMap  map[string][]models.RuleWithDepth
+	reasons map[ruleKey]string
+	rules   []models.RuleWithDepth
+}
+
+func newRuleCollector(tagMap map[string][]models.RuleWithDepth) *ruleCollector {
+	return &ruleCollector{tagMap: tagMap, reasons: make(map[ruleKey]string)}
+}
+
+// resolveReference returns the rules (or rule sections) a reference points to
+func (c *ruleCollector) resolveReference(ref string, from models.RuleWithDepth) []models.RuleWithDepth {
+	target, section := SplitReference(ref)
+	if IsDocumentReference(target) {
+		// Plain markdown documents are loaded like a rule without front matter
+		return []models.RuleWithDepth{{
+			RuleCacheEntry: models.RuleCacheEntry{Path: fsPath(target)},
+			AgentDepth:     from.AgentDepth,
+			Section:        section,
+		}}
+	}
+	referencedRules := []models.RuleWithDepth{}
+	for _, rule := range c.tagMap[target] {
+		rule.Section = section
+		referencedRules = append(referencedRules, rule)
+	}
+	return referencedRules
+}
+
+// add collects rule and, recursively, the rules it references
+func (c *ruleCollector) add(rule models.RuleWithDepth, isTopLevel bool, reason string) {
+	// Check if already processed
+	if _, ok := c.reasons[keyOf(rule)]; ok {
+		return
+	}
+	c.reasons[keyOf(rule)] = reason
+	c.rules = append(c.rules, rule)
+
+	// A single section is a snippet: its rule's references are not followed
+	if rule.Section != "" {
+		return
+	}
+
+	// Add referencesAlways
+	for _, ref := range rule.ReferencesAlways {
+		for _, referencedRule := range c.resolveReference(ref, rule) {
+			c.add(referencedRule, false, fmt.Sprintf("referenced by %s via referencesAlways '%s'", rule.Path, ref))
+		}
+	}
+
+	// Add referencesIfTop only if this is a top-level rule
+	if isTopLevel {
+		for _, ref := range rule.ReferencesIfTop {
+			for _, referencedRule := range c.resolveReference(ref, rule) {
+				c.add(referencedRule, false, fmt.Sprintf("referenced by %s via referencesIfTop '%s'", rule.Path, ref))
+			}
+		}
+	}
+}
+
+// collected returns the collected rules, without sections whose whole rule
+// was collected too
+func (c *ruleCollector) collected() []models.RuleWithDepth {
+	return filterRules(c.rules, func(rule models.RuleWithDepth) bool {
+		if rule.Section == "" {
+			return true
+		}
+		whole := rule
+		whole.Section = ""
+		_, loaded := c.reasons[keyOf(whole)]
+		return !loaded
+	})
+}
+
+// Flatten returns rule and the rules it references, recursively, as if it
+// were a top-level rule, in output order
+func Flatten(tagMap map[string][]models.RuleWithDepth, rule models.RuleWithDepth) []models.RuleWithDepth {
+	collector := newRuleCollector(tagMap)
+	collector.add(rule, true, "")
+	flattened := collector.collected()
+	SortForOutput(flattened)
+	return flattened
+}
+
+// SortForOutput sorts rules by [order ASC, agentDepth ASC, scopeDepth ASC, filePath ASC]
+func SortForOutput(rules []models.RuleWithDepth) {
+	sort.Slice(rules, func(i, j int) bool {
+		orderA := ruleOrder(rules[i])
+		orderB := ruleOrder(rules[j])
+		if orderA != orderB {
+			return orderA < orderB
+		}
+
+		if rules[i].AgentDepth != rules[j].AgentDepth {
+			return rules[i].AgentDepth < rules[j].AgentDepth
+		}
+
+		if scopeDepth(rules[i]) != scopeDepth(rules[j]) {
+			return scopeDepth(rules[i]) < scopeDepth(rules[j])
+		}
+
+		if rules[i].Path != rules[j].Path {
+			return rules[i].Path < rules[j].Path
+		}
+
+		return rules[i].Section < rules[j].Section
+	})
+}
+
+// resultOf builds the explain entry for a rule from the resolution bookkeeping
+func resultOf(rule models.RuleWithDepth, skipped, dropped, loadReasons, notes map[ruleKey]string) RuleResult {
+	key := keyOf(rule)
+	result := RuleResult{Rule: rule, Status: StatusUnmatched, Reason: notes[key]}
+	if reason, ok := skipped[key]; ok {
+		result.Status = StatusSkipped
+		result.Reason = reason
+	} else if reason, ok := dropped[key]; ok {
+		result.Status = StatusDropped
+		result.Reason = joinReasons(loadReasons[key], reason)
+	} else if reason, ok := loadReasons[key]; ok {
+		result.Status = StatusPrinted
+		result.Reason = joinReasons(reason, notes[key])
+	}
+	return result
+}
+
+// filterRules returns the rules for which keep returns true
+func filterRules(rules []models.RuleWithDepth, keep func(models.RuleWithDepth) bool) []models.RuleWithDepth {
+	result := []models.RuleWithDepth{}
+	for _, rule := range rules {
+		if keep(rule) {
+			result = append(result, rule)
+		}
+	}
+	return result
+}
+
+// RuleDir returns the directory a directory-scoped rule applies to
+func RuleDir(rule models.RuleWithDepth) string {
+	return path.Dir(filepath.ToSlash(rule.Path))
+}
+
+// scopeDepth returns how deep the directory of a directory-scoped rule is,
+// with the project root at 0. Pattern-based rules are always 0.
+func scopeDepth(rule models.RuleWithDepth) int {
+	if rule.Scope != models.ScopeDirectory {
+		return 0
+	}
+	dir := RuleDir(rule)
+	if dir == "." {
+		return 0
+	}
+	return strings.Count(dir, "/") + 1
+}
+
+// inDirectory reports whether filePath is inside dir
+func inDirectory(dir, filePath string) bool {
+	return dir == "." || strings.HasPrefix(filePath, dir+"/")
+}
+
+// directoryRulesMode returns the directory rule resolution mode of an agent
+func directoryRulesMode(cfg *models.Config, agentName string) string {
+	if agentConfig, ok := cfg.Agents[agentName]; ok && agentConfig.DirectoryRules != "" {
+		return agentConfig.DirectoryRules
+	}
+	return models.DirectoryRulesAllAncestors
+}
+
+// rulePriority returns the rule priority, treating omitted as infinity
+func rulePriority(rule models.RuleWithDepth) int {
+	if rule.Priority != nil {
+		return *rule.Priority
+	}
+	return math.MaxInt32
+}
+
+// ruleOrder returns the rule order, treating omitted as infinity
+func ruleOrder(rule models.RuleWithDepth) int {
+	if rule.Order != nil {
+		return *rule.Order
+	}
+	return math.MaxInt32
+}
+
+func joinReasons(a, b string) string {
+	if a == "" {
+		return b
+	}
+	if b == "" {
+		return a
+	}
+	return a + "; " + b
+}
@@ -0,0 +1,237 @@
+package resolver
+
+import (
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	"github.com/dirt-rain/code-editor-agent/models"
+)
+
+// testConfig is a configuration with the default agent, whose directory rule
+// mode is set if not empty
+func testConfig(directoryRules string) *models.Config {
+	return &models.Config{
+		Agents: map[string]*models.AgentConfig{
+			"code-editor": {RuleFilePattern: "**/*.code-editor-agent.md", DirectoryRules: directoryRules},
+		},
+	}
+}
+
+// resolvePaths builds the cache of a project of files and returns the display
+// names of the rules resolved for filePath
+func resolvePaths(t *testing.T, cfg *models.Config, files map[string]string, filePath string) []string {
+	t.Helper()
+	fsys := fstest.MapFS{}
+	for name, content := range files {
+		fsys[name] = &fstest.MapFile{Data: []byte(content)}
+	}
+	cache, err := BuildCache(fsys, cfg)
+	if err != nil {
+		t.Fatalf("BuildCache() error = %v", err)
+	}
+	res, err := New(fsys, ".", cfg, cache).Explain("code-editor", filePath)
+	if err != nil {
+		t.Fatalf("Explain() error = %v", err)
+	}
+	names := []string{}
+	for _, rule := range res.Final {
+		names = append(names, DisplayName(rule))
+	}
+	return names
+}
+
+func TestReferences(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  []string
+	}{
+		{
+			name: "tag containing a slash",
+			files: map[string]string{
+				"a.code-editor-agent.md":       "---\npatterns: \"**\"\nreferencesAlways: team/backend\n---\n# A\n",
+				"backend.code-editor-agent.md": "---\npatterns: []\ntags: team/backend\n---\n# Backend\n",
+			},
+			want: []string{"a.code-editor-agent.md", "backend.code-editor-agent.md"},
+		},
+		{
+			name: "document path",
+			files: map[string]string{
+				"a.code-editor-agent.md": "---\npatterns: \"**\"\nreferencesAlways: [docs/STYLE.md, ./docs/notes]\n---\n# A\n",
+				"docs/STYLE.md":          "# Style\n",
+				"docs/notes":             "# Notes\n",
+			},
+			want: []string{"a.code-editor-agent.md", "docs/STYLE.md", "docs/notes"},
+		},
+		{
+			name: "section of a tag",
+			files: map[string]string{
+				"a.code-editor-agent.md":     "---\npatterns: \"**\"\nreferencesIfTop: \"style#Errors\"\n---\n# A\n",
+				"style.code-editor-agent.md": "---\npatterns: []\ntags: style\n---\n# Naming\n\n# Errors\n",
+			},
+			want: []string{"a.code-editor-agent.md", "style.code-editor-agent.md#Errors"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := resolvePaths(t, testConfig(""), test.files, "src/main.go")
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("resolved %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestMissingDocumentReference(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.code-editor-agent.md": {Data: []byte("---\npatterns: \"**\"\nreferencesAlways: docs/missing.md\n---\n")},
+	}
+	if _, err := BuildCache(fsys, testConfig("")); err == nil {
+		t.Error("BuildCache() error = nil, want missing document")
+	}
+}
+
+func TestDirectoryRuleStartingWithBrace(t *testing.T) {
+	files := map[string]string{
+		"src/api.code-editor-agent.md": "{ \"status\": \"ok\" } is the health response.\n",
+	}
+	got := resolvePaths(t, testConfig(models.DirectoryRulesNearest), files, "src/main.go")
+	if want := []string{"src/api.code-editor-agent.md"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("resolved %v, want %v", got, want)
+	}
+}
+
+func TestSectionBodies(t *testing.T) {
+	files := map[string]string{
+		"a.code-editor-agent.md":     "---\npatterns: \"src/**\"\nreferencesAlways: [\"style#Errors\", \"docs/STYLE.md#Naming\", \"style#Missing\"]\n---\n# A\n",
+		"style.code-editor-agent.md": "---\npatterns: \"docs/**\"\ntags: style\nreferencesAlways: extra\n---\n# Naming\n\nShort.\n\n# Errors\n\nWrap them.\n",
+		"extra.code-editor-agent.md": "---\npatterns: []\ntags: extra\n---\n# Extra\n",
+		"docs/STYLE.md":              "# Intro\n\n# Naming\n\nDocument naming.\n",
+	}
+	fsys := fstest.MapFS{}
+	for name, content := range files {
+		fsys[name] = &fstest.MapFile{Data: []byte(content)}
+	}
+	cfg := testConfig("")
+	cache, err := BuildCache(fsys, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := New(fsys, ".", cfg, cache)
+	warnings := []string{}
+	r.Warn = func(message string) { warnings = append(warnings, message) }
+
+	// A section is a snippet: the references of its rule are not followed,
+	// and a missing section is left out with a warning
+	rules, err := r.Resolve("code-editor", "src/main.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{}
+	for _, rule := range rules {
+		got[rule.Path+"#"+rule.Section] = rule.Body
+	}
+	want := map[string]string{
+		"a.code-editor-agent.md#":           "# A\n",
+		"style.code-editor-agent.md#Errors": "# Errors\n\nWrap them.\n",
+		"docs/STYLE.md#Naming":              "# Naming\n\nDocument naming.\n",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Resolve() = %v, want %v", got, want)
+	}
+	if len(warnings) != 1 || warnings[0] != "Section 'Missing' not found in style.code-editor-agent.md" {
+		t.Errorf("warnings = %q, want the missing section", warnings)
+	}
+
+	// A section of a rule loaded whole is not printed twice
+	files["b.code-editor-agent.md"] = "---\npatterns: \"src/**\"\nreferencesAlways: style\n---\n# B\n"
+	names := resolvePaths(t, cfg, files, "src/main.go")
+	wantNames := []string{"a.code-editor-agent.md", "b.code-editor-agent.md", "docs/STYLE.md#Naming", "extra.code-editor-agent.md", "style.code-editor-agent.md"}
+	if !reflect.DeepEqual(names, wantNames) {
+		t.Errorf("resolved %v, want %v", names, wantNames)
+	}
+}
+
+func TestDirectoryRules(t *testing.T) {
+	files := map[string]string{
+		"root.code-editor-agent.md":         "# Root\n",
+		"src/src.code-editor-agent.md":      "# Src\n",
+		"src/api/api.code-editor-agent.md":  "# API\n",
+		"src/api/db.code-editor-agent.md":   "---\ntags: db\n---\n# DB\n",
+		"src/apiary/b.code-editor-agent.md": "# Bees\n",
+		"docs/docs.code-editor-agent.md":    "---\nscope: directory\n---\n# Docs\n",
+		"go.code-editor-agent.md":           "---\npatterns: \"**/*.go\"\n---\n# Go\n",
+	}
+
+	tests := []struct {
+		name     string
+		mode     string
+		filePath string
+		want     []string
+	}{
+		{
+			name:     "all ancestors",
+			mode:     models.DirectoryRulesAllAncestors,
+			filePath: "src/api/handler.go",
+			want:     []string{"go.code-editor-agent.md", "root.code-editor-agent.md", "src/src.code-editor-agent.md", "src/api/api.code-editor-agent.md", "src/api/db.code-editor-agent.md"},
+		},
+		{
+			name:     "nearest",
+			mode:     models.DirectoryRulesNearest,
+			filePath: "src/api/handler.go",
+			want:     []string{"go.code-editor-agent.md", "src/api/api.code-editor-agent.md", "src/api/db.code-editor-agent.md"},
+		},
+		{
+			name:     "nearest without a rule in the file's directory",
+			mode:     models.DirectoryRulesNearest,
+			filePath: "src/api/v1/handler.go",
+			want:     []string{"go.code-editor-agent.md", "src/api/api.code-editor-agent.md", "src/api/db.code-editor-agent.md"},
+		},
+		{
+			name:     "nearest at the root",
+			mode:     models.DirectoryRulesNearest,
+			filePath: "README.md",
+			want:     []string{"root.code-editor-agent.md"},
+		},
+		{
+			name:     "sibling directory with a common prefix",
+			mode:     models.DirectoryRulesNearest,
+			filePath: "src/apiary/hive.txt",
+			want:     []string{"src/apiary/b.code-editor-agent.md"},
+		},
+		{
+			name:     "explicit scope",
+			mode:     models.DirectoryRulesNearest,
+			filePath: "docs/guide.md",
+			want:     []string{"docs/docs.code-editor-agent.md"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := resolvePaths(t, testConfig(test.mode), files, test.filePath); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("resolved %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestDirectoryRulesWithoutMode(t *testing.T) {
+	// Only rules with scope: directory are directory rules, for all ancestors
+	files := map[string]string{
+		"root.code-editor-agent.md":        "---\nscope: directory\n---\n# Root\n",
+		"src/src.code-editor-agent.md":     "---\nscope: directory\n---\n# Src\n",
+		"src/api/api.code-editor-agent.md": "---\nscope: directory\n---\n# API\n",
+	}
+	got := resolvePaths(t, testConfig(""), files, "src/main.go")
+	if want := []string{"root.code-editor-agent.md", "src/src.code-editor-agent.md"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("resolved %v, want %v", got, want)
+	}
+
+	fsys := fstest.MapFS{"plain.code-editor-agent.md": {Data: []byte("# Plain\n")}}
+	if _, err := BuildCache(fsys, testConfig("")); err == nil {
+		t.Error("BuildCache() of a rule without front matter succeeded, want an error")
+	}
+}
@@ -0,0 +1,159 @@
+// Package resolver selects the rules that apply to a file, the way the
+// code-editor-agent command line does, for use by other Go programs.
+//
+// A Resolver reads the project through an fs.FS, with every path slash
+// separated and relative to the project root:
+//
+//	r, err := resolver.Open("/path/to/project")
+//	if err != nil {
+//		return err
+//	}
+//	rules, err := r.Resolve("code-editor", "src/main.go")
+package resolver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/dirt-rain/code-editor-agent/conditions"
+	"github.com/dirt-rain/code-editor-agent/config"
+	"github.com/dirt-rain/code-editor-agent/models"
+)
+
+// Cache maps agent names to their rules, as stored in the rule cache file
+type Cache map[string][]models.RuleCacheEntry
+
+// ResolvedRule is a rule to follow for a file
+type ResolvedRule struct {
+	Path    string
+	Section string // set if only a section of the rule was referenced
+	Agent   string // agent the rule was loaded through
+	Body    string // without front matter, rendered if the rule is a template
+}
+
+// Resolver resolves the rules of a project
+type Resolver struct {
+	FS     fs.FS
+	Config *models.Config
+	Cache  Cache
+	// Context evaluates `when` conditions
+	Context *conditions.Context
+	// Warn reports problems that don't stop resolution, like a missing
+	// section; nil discards them
+	Warn func(message string)
+}
+
+// New returns a resolver for the project in fsys, whose directory on disk is
+// root. root is only used to read the git branch for `when` conditions.
+func New(fsys fs.FS, root string, cfg *models.Config, cache Cache) *Resolver {
+	return &Resolver{FS: fsys, Config: cfg, Cache: cache, Context: conditions.NewContext(fsys, root)}
+}
+
+// Open returns a resolver for the project in the root directory, reading its
+// configuration and the rule cache written by `cmd generate`
+func Open(root string) (*Resolver, error) {
+	fsys := os.DirFS(root)
+	cfg, err := LoadConfig(fsys)
+	if err != nil {
+		return nil, err
+	}
+	cache, err := LoadCache(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return New(fsys, root, cfg, cache), nil
+}
+
+// LoadConfig loads and validates the configuration file of the project in
+// fsys, returning the default configuration if there is none
+func LoadConfig(fsys fs.FS) (*models.Config, error) {
+	filePath, err := config.Detect(fsys)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := config.ReadFile(fsys, filePath)
+	if err != nil {
+		return nil, err
+	}
+	return config.Parse(raw, filePath)
+}
+
+// LoadCache reads the rule cache file of the project in fsys
+func LoadCache(fsys fs.FS) (Cache, error) {
+	cacheContent, err := fs.ReadFile(fsys, models.RuleCacheFilePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache file: %w", err)
+	}
+
+	var cache Cache
+	if err := json.Unmarshal(cacheContent, &cache); err != nil {
+		return nil, fmt.Errorf("failed to parse cache file: %w", err)
+	}
+	return cache, nil
+}
+
+// Resolve returns the rules of agentName (and the agents it references) to
+// follow for filePath, in output order
+func (r *Resolver) Resolve(agentName, filePath string) ([]ResolvedRule, error) {
+	res, err := r.Explain(agentName, filePath)
+	if err != nil {
+		return nil, err
+	}
+	return r.Bodies(res)
+}
+
+// Bodies reads (and renders) the bodies of the rules to print of res. Rules
+// referencing a missing section are left out.
+func (r *Resolver) Bodies(res *Resolution) ([]ResolvedRule, error) {
+	data := newTemplateData(res.AgentName, res.FilePath, r.Config.Variables)
+	rules := make([]ResolvedRule, 0, len(res.Final))
+	for _, rule := range res.Final {
+		body, ok, err := r.ruleBody(rule, data)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			rules = append(rules, ResolvedRule{Path: rule.Path, Section: rule.Section, Agent: res.Agents[rule.AgentDepth], Body: body})
+		}
+	}
+	return rules, nil
+}
+
+// Body returns the body of a rule, or of its section, without rendering it.
+// It reports false if the referenced section doesn't exist.
+func (r *Resolver) Body(rule models.RuleWithDepth) (string, bool, error) {
+	return r.ruleBody(rule, nil)
+}
+
+// ruleBody returns the printable body of a rule: its body rendered with data
+// if it is a template (and data is not nil), or just the referenced section.
+// It reports false if the referenced section doesn't exist.
+func (r *Resolver) ruleBody(rule models.RuleWithDepth, data *templateData) (string, bool, error) {
+	body, err := ExtractBody(r.FS, rule.Path)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read rule file %s: %w", rule.Path, err)
+	}
+	if rule.Template && data != nil {
+		body, err = renderBody(r.FS, rule.Path, body, data)
+		if err != nil {
+			return "", false, fmt.Errorf("failed to render rule file %s: %w", rule.Path, err)
+		}
+	}
+	if rule.Section != "" {
+		section, ok := ExtractSection(body, rule.Section)
+		if !ok {
+			r.warn("Section '%s' not found in %s", rule.Section, rule.Path)
+			return "", false, nil
+		}
+		body = section
+	}
+	return body, true, nil
+}
+
+func (r *Resolver) warn(format string, args ...interface{}) {
+	if r.Warn != nil {
+		r.Warn(fmt.Sprintf(format, args...))
+	}
+}
@@ -0,0 +1,73 @@
+package resolver
+
+import (
+	"strings"
+)
+
+// ParseHeading returns the level and text of an ATX markdown heading line
+func ParseHeading(line string) (int, string, bool) {
+	trimmed := strings.TrimLeft(line, " ")
+	if len(line)-len(trimmed) > 3 {
+		return 0, "", false
+	}
+
+	level := 0
+	for level < len(trimmed) && trimmed[level] == '#' {
+		level++
+	}
+	if level == 0 || level > 6 {
+		return 0, "", false
+	}
+	if level < len(trimmed) && trimmed[level] != ' ' && trimmed[level] != '\t' {
+		return 0, "", false
+	}
+
+	// Drop the optional closing sequence of #s
+	text := strings.TrimSpace(trimmed[level:])
+	if stripped := strings.TrimRight(text, "#"); stripped != text && (stripped == "" || strings.HasSuffix(stripped, " ")) {
+		text = strings.TrimSpace(stripped)
+	}
+	return level, text, true
+}
+
+// IsFence reports whether the line opens or closes a fenced code block
+func IsFence(line string) bool {
+	trimmed := strings.TrimLeft(line, " ")
+	return strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~")
+}
+
+// ExtractSection returns the part of a markdown body under the given heading,
+// up to the next heading of the same or a higher level. Headings are compared
+// case-insensitively and headings inside fenced code blocks are ignored.
+func ExtractSection(body, heading string) (string, bool) {
+	lines := strings.Split(body, "\n")
+	inFence := false
+	start, level := -1, 0
+
+	for i, line := range lines {
+		if IsFence(line) {
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			continue
+		}
+
+		lineLevel, text, ok := ParseHeading(line)
+		if !ok {
+			continue
+		}
+		if start == -1 {
+			if strings.EqualFold(text, heading) {
+				start, level = i, lineLevel
+			}
+		} else if lineLevel <= level {
+			return strings.TrimRight(strings.Join(lines[start:i], "\n"), "\n") + "\n", true
+		}
+	}
+
+	if start == -1 {
+		return "", false
+	}
+	return strings.TrimRight(strings.Join(lines[start:], "\n"), "\n") + "\n", true
+}
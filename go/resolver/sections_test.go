@@ -0,0 +1,83 @@
+package resolver
+
+import (
+	"testing"
+)
+
+func TestParseHeading(t *testing.T) {
+	tests := []struct {
+		line  string
+		level int
+		text  string
+		ok    bool
+	}{
+		{line: "# Title", level: 1, text: "Title", ok: true},
+		{line: "### Error handling ###", level: 3, text: "Error handling", ok: true},
+		{line: "   ## Indented", level: 2, text: "Indented", ok: true},
+		{line: "## C#", level: 2, text: "C#", ok: true},
+		{line: "#", level: 1, text: "", ok: true},
+		{line: "    # Code block", ok: false},
+		{line: "#Title", ok: false},
+		{line: "####### Too deep", ok: false},
+		{line: "Title", ok: false},
+	}
+	for _, test := range tests {
+		level, text, ok := ParseHeading(test.line)
+		if level != test.level || text != test.text || ok != test.ok {
+			t.Errorf("ParseHeading(%q) = %d, %q, %t, want %d, %q, %t", test.line, level, text, ok, test.level, test.text, test.ok)
+		}
+	}
+}
+
+func TestExtractSection(t *testing.T) {
+	body := "# Style\n\nIntro.\n\n## Naming\n\nShort names.\n\n### Acronyms\n\nKeep case.\n\n```sh\n# Errors\n```\n\n## Errors\n\nWrap them.\n\n# Appendix\n"
+	tests := []struct {
+		heading string
+		want    string
+		ok      bool
+	}{
+		{heading: "Naming", want: "## Naming\n\nShort names.\n\n### Acronyms\n\nKeep case.\n\n```sh\n# Errors\n```\n", ok: true},
+		{heading: "acronyms", want: "### Acronyms\n\nKeep case.\n\n```sh\n# Errors\n```\n", ok: true},
+		{heading: "Errors", want: "## Errors\n\nWrap them.\n", ok: true},
+		{heading: "Appendix", want: "# Appendix\n", ok: true},
+		{heading: "Style", want: body[:len(body)-len("\n# Appendix\n")], ok: true},
+		{heading: "Missing", ok: false},
+	}
+	for _, test := range tests {
+		got, ok := ExtractSection(body, test.heading)
+		if got != test.want || ok != test.ok {
+			t.Errorf("ExtractSection(%q) = %q, %t, want %q, %t", test.heading, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestSplitReference(t *testing.T) {
+	tests := []struct {
+		ref, target, section string
+	}{
+		{ref: "style", target: "style"},
+		{ref: "style#Error handling", target: "style", section: "Error handling"},
+		{ref: "docs/STYLE.md# Errors ", target: "docs/STYLE.md", section: "Errors"},
+		{ref: "lang#C#", target: "lang", section: "C#"},
+	}
+	for _, test := range tests {
+		if target, section := SplitReference(test.ref); target != test.target || section != test.section {
+			t.Errorf("SplitReference(%q) = %q, %q, want %q, %q", test.ref, target, section, test.target, test.section)
+		}
+	}
+}
+
+func TestIsDocumentReference(t *testing.T) {
+	for target, want := range map[string]bool{
+		"docs/STYLE.md": true,
+		"./docs/notes":  true,
+		"README.md":     true,
+		"team/backend":  false,
+		"style":         false,
+		"notes.mdx":     false,
+	} {
+		if got := IsDocumentReference(target); got != want {
+			t.Errorf("IsDocumentReference(%q) = %t, want %t", target, got, want)
+		}
+	}
+}
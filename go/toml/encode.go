@@ -0,0 +1,224 @@
+package toml
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Table is a TOML table keeping the order of its keys
+type Table []KeyValue
+
+// KeyValue is a key of a Table and its value
+type KeyValue struct {
+	Key   string
+	Value interface{}
+}
+
+// Marshal writes table as a TOML document. Values are string, int, float64,
+// bool, Table, map[string]interface{} (written in key order) or []interface{}.
+// TOML has no null, so nil values are left out.
+func Marshal(table Table) ([]byte, error) {
+	var sb strings.Builder
+	if err := writeTable(&sb, nil, table, false); err != nil {
+		return nil, err
+	}
+	return []byte(sb.String()), nil
+}
+
+// writeTable writes the keys of table, then its sub-tables under their header
+func writeTable(sb *strings.Builder, path []string, table Table, arrayItem bool) error {
+	inline, nested := Table{}, Table{}
+	for _, entry := range table {
+		switch value := toTable(entry.Value).(type) {
+		case nil:
+		case Table:
+			nested = append(nested, KeyValue{entry.Key, value})
+		case []interface{}:
+			if isArrayOfTables(value) {
+				nested = append(nested, KeyValue{entry.Key, value})
+			} else {
+				inline = append(inline, KeyValue{entry.Key, value})
+			}
+		default:
+			inline = append(inline, KeyValue{entry.Key, value})
+		}
+	}
+
+	if len(path) > 0 && (arrayItem || len(inline) > 0 || len(nested) == 0) {
+		if sb.Len() > 0 {
+			sb.WriteString("\n")
+		}
+		if arrayItem {
+			fmt.Fprintf(sb, "[[%s]]\n", formatPath(path))
+		} else {
+			fmt.Fprintf(sb, "[%s]\n", formatPath(path))
+		}
+	}
+	for _, entry := range inline {
+		value, err := formatValue(entry.Value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", formatPath(append(path, entry.Key)), err)
+		}
+		fmt.Fprintf(sb, "%s = %s\n", formatKey(entry.Key), value)
+	}
+
+	for _, entry := range nested {
+		entryPath := append(append([]string{}, path...), entry.Key)
+		switch value := entry.Value.(type) {
+		case Table:
+			if err := writeTable(sb, entryPath, value, false); err != nil {
+				return err
+			}
+		case []interface{}:
+			for _, item := range value {
+				if err := writeTable(sb, entryPath, toTable(item).(Table), true); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// toTable returns maps as Table, and other values unchanged
+func toTable(value interface{}) interface{} {
+	values, ok := value.(map[string]interface{})
+	if !ok {
+		return value
+	}
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	table := make(Table, 0, len(keys))
+	for _, key := range keys {
+		table = append(table, KeyValue{key, values[key]})
+	}
+	return table
+}
+
+func isArrayOfTables(array []interface{}) bool {
+	if len(array) == 0 {
+		return false
+	}
+	for _, item := range array {
+		if _, ok := toTable(item).(Table); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+var bareKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+func formatKey(key string) string {
+	if bareKeyPattern.MatchString(key) {
+		return key
+	}
+	return formatString(key)
+}
+
+func formatPath(path []string) string {
+	keys := make([]string, len(path))
+	for i, key := range path {
+		keys[i] = formatKey(key)
+	}
+	return strings.Join(keys, ".")
+}
+
+// formatValue formats a value written on the line of its key
+func formatValue(value interface{}) (string, error) {
+	switch value := toTable(value).(type) {
+	case string:
+		return formatString(value), nil
+	case int:
+		return strconv.Itoa(value), nil
+	case float64:
+		return formatFloat(value), nil
+	case bool:
+		return strconv.FormatBool(value), nil
+	case []interface{}:
+		items := make([]string, 0, len(value))
+		for _, item := range value {
+			if item == nil {
+				return "", fmt.Errorf("arrays cannot contain null in TOML")
+			}
+			formatted, err := formatValue(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, formatted)
+		}
+		return "[" + strings.Join(items, ", ") + "]", nil
+	case Table:
+		items := make([]string, 0, len(value))
+		for _, entry := range value {
+			if entry.Value == nil {
+				continue
+			}
+			formatted, err := formatValue(entry.Value)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, formatKey(entry.Key)+" = "+formatted)
+		}
+		if len(items) == 0 {
+			return "{}", nil
+		}
+		return "{ " + strings.Join(items, ", ") + " }", nil
+	default:
+		return "", fmt.Errorf("unsupported value of type %T", value)
+	}
+}
+
+func formatString(s string) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			sb.WriteString(`\"`)
+		case '\\':
+			sb.WriteString(`\\`)
+		case '\b':
+			sb.WriteString(`\b`)
+		case '\t':
+			sb.WriteString(`\t`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\f':
+			sb.WriteString(`\f`)
+		case '\r':
+			sb.WriteString(`\r`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&sb, `\u%04X`, r)
+			} else {
+				sb.WriteRune(r)
+			}
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	case math.IsNaN(f):
+		return "nan"
+	}
+	formatted := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(formatted, ".eEn") {
+		formatted += ".0"
+	}
+	return formatted
+}
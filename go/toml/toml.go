@@ -0,0 +1,514 @@
+// Package toml parses and writes the subset of TOML used by front matter and
+// configuration files: key/value pairs, tables, arrays of tables, strings,
+// integers, floats, booleans, arrays and inline tables. Dates and times are not
+// supported.
+package toml
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Error is a syntax error at a line of the document
+type Error struct {
+	Line    int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+}
+
+// Parse parses a TOML document. Integers are int, floats float64, tables
+// map[string]interface{} and arrays []interface{}.
+func Parse(data string) (map[string]interface{}, error) {
+	p := &parser{data: data, line: 1, kinds: map[uintptr]tableKind{}, arraysOfTables: map[arrayKey]bool{}}
+	root := map[string]interface{}{}
+	current := root
+
+	for {
+		p.skipBlank(true)
+		if p.eof() {
+			return root, nil
+		}
+
+		switch {
+		case strings.HasPrefix(p.rest(), "[["):
+			p.pos += 2
+			keys, err := p.parseKey()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("]]"); err != nil {
+				return nil, err
+			}
+			parent, err := p.headerParent(root, keys[:len(keys)-1])
+			if err != nil {
+				return nil, err
+			}
+			last := keys[len(keys)-1]
+			array, _ := parent[last].([]interface{})
+			key := arrayKey{tableID(parent), last}
+			if _, exists := parent[last]; exists && !p.arraysOfTables[key] {
+				return nil, p.errorf("key '%s' is already defined", strings.Join(keys, "."))
+			}
+			p.arraysOfTables[key] = true
+			current = map[string]interface{}{}
+			p.kinds[tableID(current)] = headerTable
+			parent[last] = append(array, current)
+
+		case strings.HasPrefix(p.rest(), "["):
+			p.pos++
+			keys, err := p.parseKey()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			parent, err := p.headerParent(root, keys[:len(keys)-1])
+			if err != nil {
+				return nil, err
+			}
+			last := keys[len(keys)-1]
+			switch value := parent[last].(type) {
+			case nil:
+				current = map[string]interface{}{}
+				parent[last] = current
+			case map[string]interface{}:
+				// Only a table created as the parent of another one can be defined later
+				if p.kinds[tableID(value)] != implicitTable {
+					return nil, p.errorf("table '%s' is already defined", strings.Join(keys, "."))
+				}
+				current = value
+			default:
+				return nil, p.errorf("key '%s' is already defined", strings.Join(keys, "."))
+			}
+			p.kinds[tableID(current)] = headerTable
+
+		default:
+			if err := p.parseKeyValue(current); err != nil {
+				return nil, err
+			}
+		}
+
+		p.skipBlank(false)
+		if !p.eof() && p.peek() != '\n' {
+			return nil, p.errorf("expected a new line, found %q", p.peek())
+		}
+	}
+}
+
+type parser struct {
+	data string
+	pos  int
+	line int
+	// kinds records how each table was created, which decides whether it
+	// can be extended
+	kinds map[uintptr]tableKind
+	// arraysOfTables records the arrays created by [[...]] headers, the only
+	// ones more tables can be appended to
+	arraysOfTables map[arrayKey]bool
+}
+
+// tableKind is how a table was created
+type tableKind int
+
+const (
+	implicitTable tableKind = iota // as the parent of a [table] header
+	headerTable                    // by a [table] or [[table]] header
+	dottedTable                    // by a dotted key
+	inlineTable                    // by an inline table, which can't be extended
+)
+
+// arrayKey identifies an array by the table holding it and its key
+type arrayKey struct {
+	parent uintptr
+	key    string
+}
+
+// tableID identifies a table
+func tableID(table map[string]interface{}) uintptr {
+	return reflect.ValueOf(table).Pointer()
+}
+
+func (p *parser) eof() bool    { return p.pos >= len(p.data) }
+func (p *parser) rest() string { return p.data[p.pos:] }
+func (p *parser) peek() byte   { return p.data[p.pos] }
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return &Error{Line: p.line, Message: fmt.Sprintf(format, args...)}
+}
+
+// skipBlank skips spaces and comments, and new lines if newlines is set
+func (p *parser) skipBlank(newlines bool) {
+	for !p.eof() {
+		switch c := p.peek(); {
+		case c == ' ' || c == '\t' || c == '\r':
+			p.pos++
+		case c == '\n' && newlines:
+			p.pos++
+			p.line++
+		case c == '#':
+			for !p.eof() && p.peek() != '\n' {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (p *parser) expect(token string) error {
+	p.skipBlank(false)
+	if !strings.HasPrefix(p.rest(), token) {
+		return p.errorf("expected '%s'", token)
+	}
+	p.pos += len(token)
+	return nil
+}
+
+// headerParent returns the table at keys under root that a header defines a
+// table in, creating missing tables. For an array of tables, it is its last
+// table.
+func (p *parser) headerParent(root map[string]interface{}, keys []string) (map[string]interface{}, error) {
+	current := root
+	for i, key := range keys {
+		switch value := current[key].(type) {
+		case nil:
+			table := map[string]interface{}{}
+			current[key] = table
+			current = table
+		case map[string]interface{}:
+			if p.kinds[tableID(value)] == inlineTable {
+				return nil, p.errorf("key '%s' is already defined", strings.Join(keys[:i+1], "."))
+			}
+			current = value
+		case []interface{}:
+			if !p.arraysOfTables[arrayKey{tableID(current), key}] {
+				return nil, p.errorf("key '%s' is already defined", strings.Join(keys[:i+1], "."))
+			}
+			current = value[len(value)-1].(map[string]interface{})
+		default:
+			return nil, p.errorf("key '%s' is not a table", strings.Join(keys[:i+1], "."))
+		}
+	}
+	return current, nil
+}
+
+// dottedParent returns the table at the dotted keys under table, creating
+// missing tables. Dotted keys only extend the tables they created.
+func (p *parser) dottedParent(table map[string]interface{}, keys []string) (map[string]interface{}, error) {
+	current := table
+	for i, key := range keys {
+		switch value := current[key].(type) {
+		case nil:
+			table := map[string]interface{}{}
+			p.kinds[tableID(table)] = dottedTable
+			current[key] = table
+			current = table
+		case map[string]interface{}:
+			if p.kinds[tableID(value)] != dottedTable {
+				return nil, p.errorf("key '%s' is already defined", strings.Join(keys[:i+1], "."))
+			}
+			current = value
+		default:
+			return nil, p.errorf("key '%s' is already defined", strings.Join(keys[:i+1], "."))
+		}
+	}
+	return current, nil
+}
+
+// parseKey parses a dotted key of bare and quoted parts
+func (p *parser) parseKey() ([]string, error) {
+	keys := []string{}
+	for {
+		p.skipBlank(false)
+		if p.eof() {
+			return nil, p.errorf("expected a key")
+		}
+		switch c := p.peek(); {
+		case c == '"' || c == '\'':
+			key, err := p.parseString()
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, key)
+		case isBareKeyChar(c):
+			start := p.pos
+			for !p.eof() && isBareKeyChar(p.peek()) {
+				p.pos++
+			}
+			keys = append(keys, p.data[start:p.pos])
+		default:
+			return nil, p.errorf("expected a key, found %q", c)
+		}
+
+		p.skipBlank(false)
+		if p.eof() || p.peek() != '.' {
+			return keys, nil
+		}
+		p.pos++
+	}
+}
+
+func isBareKeyChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+// parseKeyValue parses `key = value` into table
+func (p *parser) parseKeyValue(table map[string]interface{}) error {
+	keys, err := p.parseKey()
+	if err != nil {
+		return err
+	}
+	if err := p.expect("="); err != nil {
+		return err
+	}
+	value, err := p.parseValue()
+	if err != nil {
+		return err
+	}
+
+	parent, err := p.dottedParent(table, keys[:len(keys)-1])
+	if err != nil {
+		return err
+	}
+	last := keys[len(keys)-1]
+	if _, exists := parent[last]; exists {
+		return p.errorf("key '%s' is already defined", strings.Join(keys, "."))
+	}
+	parent[last] = value
+	return nil
+}
+
+func (p *parser) parseValue() (interface{}, error) {
+	p.skipBlank(false)
+	if p.eof() {
+		return nil, p.errorf("expected a value")
+	}
+
+	switch c := p.peek(); {
+	case c == '"' || c == '\'':
+		return p.parseString()
+	case c == '[':
+		return p.parseArray()
+	case c == '{':
+		return p.parseInlineTable()
+	}
+
+	start := p.pos
+	for !p.eof() && !strings.ContainsRune(" \t\r\n,]}#", rune(p.peek())) {
+		p.pos++
+	}
+	token := p.data[start:p.pos]
+	switch token {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "":
+		return nil, p.errorf("expected a value, found %q", p.peek())
+	}
+	if number, ok := parseNumber(token); ok {
+		return number, nil
+	}
+	return nil, p.errorf("invalid value '%s'", token)
+}
+
+// parseNumber parses an integer, as int, or a float
+func parseNumber(token string) (interface{}, bool) {
+	number := strings.ReplaceAll(token, "_", "")
+	digits := strings.TrimLeft(number, "+-")
+	switch digits {
+	case "inf", "nan":
+		f, err := strconv.ParseFloat(number, 64)
+		return f, err == nil
+	}
+	if digits == "" || digits[0] < '0' || digits[0] > '9' {
+		return nil, false
+	}
+	if len(digits) > 1 && digits[0] == '0' && digits[1] >= '0' && digits[1] <= '9' {
+		// Leading zeros are not allowed
+		return nil, false
+	}
+	if n, err := strconv.ParseInt(number, 0, 64); err == nil {
+		return int(n), true
+	}
+	if f, err := strconv.ParseFloat(number, 64); err == nil && !strings.ContainsAny(digits, "xob") {
+		return f, true
+	}
+	return nil, false
+}
+
+func (p *parser) parseArray() ([]interface{}, error) {
+	p.pos++
+	array := []interface{}{}
+	for {
+		p.skipBlank(true)
+		if p.eof() {
+			return nil, p.errorf("unclosed array")
+		}
+		if p.peek() == ']' {
+			p.pos++
+			return array, nil
+		}
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		array = append(array, value)
+
+		p.skipBlank(true)
+		if p.eof() {
+			return nil, p.errorf("unclosed array")
+		}
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case ']':
+		default:
+			return nil, p.errorf("expected ',' or ']' in array, found %q", p.peek())
+		}
+	}
+}
+
+func (p *parser) parseInlineTable() (map[string]interface{}, error) {
+	p.pos++
+	table := map[string]interface{}{}
+	p.kinds[tableID(table)] = inlineTable
+	p.skipBlank(false)
+	if !p.eof() && p.peek() == '}' {
+		p.pos++
+		return table, nil
+	}
+	for {
+		if err := p.parseKeyValue(table); err != nil {
+			return nil, err
+		}
+		p.skipBlank(false)
+		if p.eof() {
+			return nil, p.errorf("unclosed inline table")
+		}
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case '}':
+			p.pos++
+			return table, nil
+		default:
+			return nil, p.errorf("expected ',' or '}' in inline table, found %q", p.peek())
+		}
+	}
+}
+
+// parseString parses a basic or literal string, single or multi-line
+func (p *parser) parseString() (string, error) {
+	quote := p.data[p.pos : p.pos+1]
+	multiline := strings.HasPrefix(p.rest(), strings.Repeat(quote, 3))
+	if multiline {
+		p.pos += 3
+		// A new line right after the opening delimiter is trimmed
+		if strings.HasPrefix(p.rest(), "\r\n") {
+			p.pos += 2
+			p.line++
+		} else if strings.HasPrefix(p.rest(), "\n") {
+			p.pos++
+			p.line++
+		}
+	} else {
+		p.pos++
+	}
+
+	var sb strings.Builder
+	for {
+		if p.eof() {
+			return "", p.errorf("unclosed string")
+		}
+		c := p.peek()
+		switch {
+		case multiline && strings.HasPrefix(p.rest(), strings.Repeat(quote, 3)):
+			p.pos += 3
+			// Up to two quotes may precede the closing delimiter
+			for i := 0; i < 2 && strings.HasPrefix(p.rest(), quote); i++ {
+				sb.WriteString(quote)
+				p.pos++
+			}
+			return sb.String(), nil
+		case !multiline && c == quote[0]:
+			p.pos++
+			return sb.String(), nil
+		case c == '\n':
+			if !multiline {
+				return "", p.errorf("unclosed string")
+			}
+			sb.WriteByte(c)
+			p.pos++
+			p.line++
+		case c == '\\' && quote == `"`:
+			if err := p.parseEscape(&sb, multiline); err != nil {
+				return "", err
+			}
+		default:
+			sb.WriteByte(c)
+			p.pos++
+		}
+	}
+}
+
+func (p *parser) parseEscape(sb *strings.Builder, multiline bool) error {
+	p.pos++
+	if p.eof() {
+		return p.errorf("unclosed string")
+	}
+	c := p.peek()
+	p.pos++
+	switch c {
+	case 'b':
+		sb.WriteByte('\b')
+	case 't':
+		sb.WriteByte('\t')
+	case 'n':
+		sb.WriteByte('\n')
+	case 'f':
+		sb.WriteByte('\f')
+	case 'r':
+		sb.WriteByte('\r')
+	case 'e':
+		sb.WriteByte(0x1b)
+	case '"', '\\':
+		sb.WriteByte(c)
+	case 'u', 'U':
+		size := 4
+		if c == 'U' {
+			size = 8
+		}
+		if len(p.rest()) < size {
+			return p.errorf("invalid escape sequence")
+		}
+		code, err := strconv.ParseUint(p.rest()[:size], 16, 32)
+		if err != nil || !utf8.ValidRune(rune(code)) {
+			return p.errorf("invalid escape sequence '\\%c%s'", c, p.rest()[:size])
+		}
+		sb.WriteRune(rune(code))
+		p.pos += size
+	case ' ', '\t', '\r', '\n':
+		if !multiline {
+			return p.errorf("invalid escape sequence '\\%c'", c)
+		}
+		// A line ending backslash trims the whitespace up to the next character
+		p.pos--
+		for !p.eof() && strings.ContainsRune(" \t\r\n", rune(p.peek())) {
+			if p.peek() == '\n' {
+				p.line++
+			}
+			p.pos++
+		}
+	default:
+		return p.errorf("invalid escape sequence '\\%c'", c)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package toml
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	values, err := Parse(`s = "a\tb\u00e9"
+l = 'C:\path'
+m = """
+line 1
+line \
+   2"""
+i = -0x10
+f = 1.5e3
+b = false
+empty = []
+nested = [[1, 2], ["a"],]
+"quoted key" = 1
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"s":          "a\tbé",
+		"l":          `C:\path`,
+		"m":          "line 1\nline 2",
+		"i":          -16,
+		"f":          1500.0,
+		"b":          false,
+		"empty":      []interface{}{},
+		"nested":     []interface{}{[]interface{}{1, 2}, []interface{}{"a"}},
+		"quoted key": 1,
+	}
+	if !reflect.DeepEqual(values, want) {
+		t.Errorf("Parse() = %#v, want %#v", values, want)
+	}
+
+	for _, invalid := range []string{"a = 1\na = 2", "a = 01", "a = 1 b = 2", "[t]\n[t]", "a = \"x", "a = [1", "a = 1979-05-27"} {
+		if _, err := Parse(invalid); err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", invalid)
+		}
+	}
+}
+
+func TestTableDefinitions(t *testing.T) {
+	valid := []string{
+		"[a.b]\n[a]",
+		"[[a]]\n[a.b]\n[[a]]\n[a.b]",
+		"[a]\nb.c = 1\nb.d = 2\n[a.b.e]",
+		"t = {a = 1}\nu = {b.c = 1}",
+	}
+	for _, doc := range valid {
+		if _, err := Parse(doc); err != nil {
+			t.Errorf("Parse(%q) error = %v", doc, err)
+		}
+	}
+
+	invalid := []string{
+		"t = {a = 1}\n[t]",
+		"t = {a = 1}\n[t.b]",
+		"t = {a = 1}\nt.b = 2",
+		"t = {a = 1}\n[[t]]",
+		"[[a]]\n[a]",
+		"[a]\n[[a]]",
+		"a = [{}]\n[[a]]",
+		"a = [{}]\n[a.b]",
+		"a.b = 1\n[a]",
+		"[a]\nb.c = 1\n[a.b]",
+		"[a.b]\n[a]\nb.c = 1",
+		"[[a]]\na.b = 1\n[a.a]",
+	}
+	for _, doc := range invalid {
+		if _, err := Parse(doc); err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", doc)
+		}
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	table := Table{
+		{"exclude", []interface{}{"./node_modules/**"}},
+		{"usageLog", true},
+		{"skipped", nil},
+		{"agents", Table{
+			{"code-editor", Table{
+				{"ruleFilePattern", "**/*.code-editor-agent.md"},
+				{"claudeAgent", map[string]interface{}{"tools": []interface{}{"Read", "Edit"}, "instructions": "Line 1\n\"Line 2\""}},
+			}},
+			{"code reviewer", Table{{"commandGroup", "reviewer"}, {"ratio", 1.0}}},
+		}},
+		{"rules", []interface{}{
+			map[string]interface{}{"name": "a", "when": map[string]interface{}{"env": "CI"}},
+			map[string]interface{}{"name": "b"},
+		}},
+	}
+	content, err := Marshal(table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `exclude = ["./node_modules/**"]
+usageLog = true
+
+[agents.code-editor]
+ruleFilePattern = "**/*.code-editor-agent.md"
+
+[agents.code-editor.claudeAgent]
+instructions = "Line 1\n\"Line 2\""
+tools = ["Read", "Edit"]
+
+[agents."code reviewer"]
+commandGroup = "reviewer"
+ratio = 1.0
+
+[[rules]]
+name = "a"
+
+[rules.when]
+env = "CI"
+
+[[rules]]
+name = "b"
+`
+	if string(content) != want {
+		t.Fatalf("Marshal() =\n%s\nwant:\n%s", content, want)
+	}
+
+	values, err := Parse(string(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed := values["agents"].(map[string]interface{})["code-editor"].(map[string]interface{})["claudeAgent"].(map[string]interface{})["instructions"]
+	if parsed != "Line 1\n\"Line 2\"" {
+		t.Errorf("instructions = %q after a round trip", parsed)
+	}
+	if len(values["rules"].([]interface{})) != 2 {
+		t.Errorf("rules = %#v after a round trip", values["rules"])
+	}
+}
@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	// numbered returns the lines "1" to "n", with line i replaced by
+	// replacements[i]
+	numbered := func(n int, replacements map[int]string) string {
+		l := []string{}
+		for i := 1; i <= n; i++ {
+			if replacement, ok := replacements[i]; ok {
+				l = append(l, replacement)
+			} else {
+				l = append(l, fmt.Sprint(i))
+			}
+		}
+		return lines(l...)
+	}
+
+	tests := []struct {
+		name    string
+		oldText string
+		newText string
+		want    string
+	}{
+		{name: "equal", oldText: lines("a"), newText: lines("a"), want: ""},
+		{
+			name:    "change",
+			oldText: lines("a", "b", "c"),
+			newText: lines("a", "B", "c"),
+			want:    "--- a/f\n+++ b/f\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+		},
+		{
+			name:    "new file",
+			oldText: "",
+			newText: lines("a", "b"),
+			want:    "--- a/f\n+++ b/f\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			name:    "deleted file",
+			oldText: lines("a"),
+			newText: "",
+			want:    "--- a/f\n+++ b/f\n@@ -1,1 +0,0 @@\n-a\n",
+		},
+		{
+			name:    "context is limited to 3 lines",
+			oldText: numbered(10, nil),
+			newText: numbered(10, map[int]string{5: "five"}),
+			want:    "--- a/f\n+++ b/f\n@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n",
+		},
+		{
+			name:    "distant changes get separate hunks",
+			oldText: numbered(20, nil),
+			newText: numbered(20, map[int]string{2: "two", 18: "eighteen"}),
+			want:    "--- a/f\n+++ b/f\n@@ -1,5 +1,5 @@\n 1\n-2\n+two\n 3\n 4\n 5\n@@ -15,6 +15,6 @@\n 15\n 16\n 17\n-18\n+eighteen\n 19\n 20\n",
+		},
+		{
+			name:    "close changes share a hunk",
+			oldText: numbered(12, nil),
+			newText: numbered(12, map[int]string{2: "two", 8: "eight"}),
+			want:    "--- a/f\n+++ b/f\n@@ -1,11 +1,11 @@\n 1\n-2\n+two\n 3\n 4\n 5\n 6\n 7\n-8\n+eight\n 9\n 10\n 11\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := UnifiedDiff("a/f", "b/f", test.oldText, test.newText); got != test.want {
+				t.Errorf("UnifiedDiff() =\n%s\nwant:\n%s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestUnifiedDiffInsertion(t *testing.T) {
+	got := UnifiedDiff("a/f", "b/f", lines("a", "c"), lines("a", "b", "c"))
+	if !strings.Contains(got, "@@ -1,2 +1,3 @@\n a\n+b\n c\n") {
+		t.Errorf("UnifiedDiff() =\n%s\nwant the inserted line between its context", got)
+	}
+}
@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// WritableFS is a filesystem that can also be written to. Names are slash
+// separated and relative to its root, as for fs.FS.
+type WritableFS interface {
+	fs.FS
+	WriteFile(name string, data []byte, perm fs.FileMode) error
+	MkdirAll(name string, perm fs.FileMode) error
+	Remove(name string) error
+	// AppendFile appends data to the file name, creating it if needed
+	AppendFile(name string, data []byte, perm fs.FileMode) error
+}
+
+// DirFS returns a WritableFS for the files under dir on disk
+func DirFS(dir string) WritableFS {
+	return &dirFS{FS: os.DirFS(dir), dir: dir}
+}
+
+type dirFS struct {
+	fs.FS
+	dir string
+}
+
+func (d *dirFS) path(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return filepath.Join(d.dir, filepath.FromSlash(name)), nil
+}
+
+func (d *dirFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	fullPath, err := d.path("write", name)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(fullPath, data, perm)
+}
+
+func (d *dirFS) MkdirAll(name string, perm fs.FileMode) error {
+	fullPath, err := d.path("mkdir", name)
+	if err != nil {
+		return err
+	}
+	return os.MkdirAll(fullPath, perm)
+}
+
+func (d *dirFS) Remove(name string) error {
+	fullPath, err := d.path("remove", name)
+	if err != nil {
+		return err
+	}
+	return os.Remove(fullPath)
+}
+
+func (d *dirFS) AppendFile(name string, data []byte, perm fs.FileMode) error {
+	fullPath, err := d.path("append", name)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(fullPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,101 @@
+package utils
+
+import (
+	"strings"
+)
+
+// Conflict markers written by Merge3
+const (
+	ConflictStart = "<<<<<<< yours"
+	ConflictBase  = "||||||| base"
+	ConflictSep   = "======="
+	ConflictEnd   = ">>>>>>> template"
+)
+
+// Merge3 merges the changes from base to theirs into ours, line by line. It
+// returns the merged text and the number of conflicts, which are written
+// with diff3-style markers.
+func Merge3(base, ours, theirs string) (string, int) {
+	baseLines, ourLines, theirLines := splitLines(base), splitLines(ours), splitLines(theirs)
+	ourMatches := lcsMatches(baseLines, ourLines)
+	theirMatches := lcsMatches(baseLines, theirLines)
+
+	merged := []string{}
+	conflicts := 0
+	i, o, t := 0, 0, 0
+	for {
+		// Find the next base line kept by both sides
+		j := i
+		for j < len(baseLines) && (ourMatches[j] < 0 || theirMatches[j] < 0) {
+			j++
+		}
+		ourEnd, theirEnd := len(ourLines), len(theirLines)
+		if j < len(baseLines) {
+			ourEnd, theirEnd = ourMatches[j], theirMatches[j]
+		}
+
+		baseChunk, ourChunk, theirChunk := baseLines[i:j], ourLines[o:ourEnd], theirLines[t:theirEnd]
+		switch {
+		case equalLines(ourChunk, baseChunk):
+			merged = append(merged, theirChunk...)
+		case equalLines(theirChunk, baseChunk), equalLines(ourChunk, theirChunk):
+			merged = append(merged, ourChunk...)
+		default:
+			conflicts++
+			merged = append(merged, ConflictStart)
+			merged = append(merged, ourChunk...)
+			merged = append(merged, ConflictBase)
+			merged = append(merged, baseChunk...)
+			merged = append(merged, ConflictSep)
+			merged = append(merged, theirChunk...)
+			merged = append(merged, ConflictEnd)
+		}
+
+		if j == len(baseLines) {
+			break
+		}
+		merged = append(merged, baseLines[j])
+		i, o, t = j+1, ourEnd+1, theirEnd+1
+	}
+
+	if len(merged) == 0 {
+		return "", conflicts
+	}
+	return strings.Join(merged, "\n") + "\n", conflicts
+}
+
+// lcsMatches returns, for each line of a, the index of the line of b it is
+// matched with in a longest common subsequence, or -1
+func lcsMatches(a, b []string) []int {
+	lcs := lcsTable(a, b)
+	matches := make([]int, len(a))
+	for k := range matches {
+		matches[k] = -1
+	}
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			matches[i] = j
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	return matches
+}
+
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+// lines joins lines with a trailing new line
+func lines(l ...string) string {
+	if len(l) == 0 {
+		return ""
+	}
+	return strings.Join(l, "\n") + "\n"
+}
+
+func TestMerge3(t *testing.T) {
+	base := lines("a", "b", "c", "d", "e")
+	tests := []struct {
+		name      string
+		ours      string
+		theirs    string
+		want      string
+		conflicts int
+	}{
+		{name: "unchanged", ours: base, theirs: base, want: base},
+		{name: "only ours", ours: lines("a", "B", "c", "d", "e"), theirs: base, want: lines("a", "B", "c", "d", "e")},
+		{name: "only theirs", ours: base, theirs: lines("a", "b", "c", "D", "e"), want: lines("a", "b", "c", "D", "e")},
+		{name: "both, apart", ours: lines("a", "B", "c", "d", "e"), theirs: lines("a", "b", "c", "D", "e"), want: lines("a", "B", "c", "D", "e")},
+		{name: "same change", ours: lines("a", "X", "c", "d", "e"), theirs: lines("a", "X", "c", "d", "e"), want: lines("a", "X", "c", "d", "e")},
+		{name: "insertions", ours: lines("0", "a", "b", "c", "d", "e"), theirs: lines("a", "b", "c", "d", "e", "f"), want: lines("0", "a", "b", "c", "d", "e", "f")},
+		{name: "deletions", ours: lines("a", "c", "d", "e"), theirs: lines("a", "b", "c", "e"), want: lines("a", "c", "e")},
+		{name: "theirs deletes everything", ours: base, theirs: "", want: ""},
+		{
+			name:      "conflict",
+			ours:      lines("a", "ours", "c", "d", "e"),
+			theirs:    lines("a", "theirs", "c", "d", "e"),
+			want:      lines("a", ConflictStart, "ours", ConflictBase, "b", ConflictSep, "theirs", ConflictEnd, "c", "d", "e"),
+			conflicts: 1,
+		},
+		{
+			name:      "edit against deletion",
+			ours:      lines("a", "b", "c", "d", "E"),
+			theirs:    lines("a", "b", "c", "d"),
+			want:      lines("a", "b", "c", "d", ConflictStart, "E", ConflictBase, "e", ConflictSep, ConflictEnd),
+			conflicts: 1,
+		},
+		{
+			name:      "two conflicts",
+			ours:      lines("A1", "b", "c", "d", "E1"),
+			theirs:    lines("A2", "b", "c", "d", "E2"),
+			want:      lines(ConflictStart, "A1", ConflictBase, "a", ConflictSep, "A2", ConflictEnd, "b", "c", "d", ConflictStart, "E1", ConflictBase, "e", ConflictSep, "E2", ConflictEnd),
+			conflicts: 2,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, conflicts := Merge3(base, test.ours, test.theirs)
+			if got != test.want || conflicts != test.conflicts {
+				t.Errorf("Merge3() = %q, %d conflicts, want %q, %d", got, conflicts, test.want, test.conflicts)
+			}
+		})
+	}
+}
+
+func TestMerge3EmptyBase(t *testing.T) {
+	// Files both sides created differently conflict as a whole
+	got, conflicts := Merge3("", lines("ours"), lines("theirs"))
+	if want := lines(ConflictStart, "ours", ConflictBase, ConflictSep, "theirs", ConflictEnd); got != want || conflicts != 1 {
+		t.Errorf("Merge3() = %q, %d, want %q, 1", got, conflicts, want)
+	}
+}
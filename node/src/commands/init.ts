@@ -21,7 +21,7 @@ export async function init() {
   "agents": {
     // Default agent: code-editor
     "code-editor": {
-      "ruleFilePattern": "**/*.code-editor-agent.md",
+      "ruleFilePattern": "**/{*.code-editor-agent.md,code-editor-agent.md}",
       "commandGroup": null,
     },
     // You can add more agents like this:
@@ -114,6 +114,9 @@ color: orange
 ---
 
 You must read full output of \`npx code-editor-agent "\${RELATIVE_PATH_OF_FILE_TO_EDIT_FROM_PROJECT_ROOT_EXCLUDING_LEADING_DOT_SLASH}"\` before create/update/delete any file, even if file does not exist yet.
+
+<!-- BEGIN USER SECTION: kept by code-editor-agent cmd sync-agents -->
+<!-- END USER SECTION -->
 `,
     "utf-8"
   );
@@ -22,7 +22,7 @@ const defaultConfig: Config = {
   ...DEFAULT_RAW_CONFIG,
   agents: {
     "code-editor": {
-      ruleFilePattern: "**/*.code-editor-agent.md",
+      ruleFilePattern: "**/{*.code-editor-agent.md,code-editor-agent.md}",
       commandGroup: null,
     },
   },